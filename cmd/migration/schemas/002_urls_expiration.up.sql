@@ -0,0 +1,2 @@
+-- the expiration of the links, null means the link lives forever
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ NULL;
@@ -0,0 +1,2 @@
+-- the redirect status code of the links, null means the globally configured one
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect SMALLINT NULL;
@@ -0,0 +1,12 @@
+-- the clicks table, each row is a successful redirect of a link
+CREATE TABLE IF NOT EXISTS clicks (
+	id BIGSERIAL PRIMARY KEY,
+	url_id VARCHAR(12) NOT NULL,
+	clicked_at TIMESTAMPTZ NOT NULL,
+	referrer TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	country VARCHAR(2) NOT NULL DEFAULT ''
+);
+
+-- btree index for aggregating the clicks of a link over time
+CREATE INDEX IF NOT EXISTS clicks_url_id_clicked_at_idx ON clicks (url_id, clicked_at);
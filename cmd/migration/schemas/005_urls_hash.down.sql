@@ -0,0 +1,4 @@
+-- 
+DROP INDEX IF EXISTS urls_url_hash_idx;
+
+ALTER TABLE urls DROP COLUMN IF EXISTS url_hash;
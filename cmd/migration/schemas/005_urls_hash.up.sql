@@ -0,0 +1,8 @@
+-- sha256 of the normalized url, used to return the existing link of an already shortened url
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS url_hash CHAR(64) NULL;
+
+-- best-effort backfill of the existing links (their urls are hashed as they are)
+UPDATE urls SET url_hash = encode(sha256(convert_to(url, 'UTF8')), 'hex') WHERE url_hash IS NULL;
+
+-- btree index on the url hash
+CREATE INDEX IF NOT EXISTS urls_url_hash_idx ON urls (url_hash);
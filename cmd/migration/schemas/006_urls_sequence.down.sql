@@ -0,0 +1,2 @@
+-- 
+DROP SEQUENCE IF EXISTS urls_id_seq;
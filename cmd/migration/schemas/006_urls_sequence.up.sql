@@ -0,0 +1,2 @@
+-- the sequence of the sequence key generator, starting from 62^3 so the keys have at least 4 characters
+CREATE SEQUENCE IF NOT EXISTS urls_id_seq START WITH 238328;
@@ -0,0 +1,4 @@
+-- the pre-generated keys which are not taken by any link yet
+CREATE TABLE IF NOT EXISTS key_pool (
+	id VARCHAR(12) PRIMARY KEY
+);
@@ -0,0 +1,7 @@
+-- the destinations which are never shortened nor redirected to
+CREATE TABLE IF NOT EXISTS blocked_destinations (
+	pattern TEXT PRIMARY KEY,
+	kind VARCHAR(8) NOT NULL DEFAULT 'domain' CHECK (kind IN ('domain', 'regex')),
+	reason TEXT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
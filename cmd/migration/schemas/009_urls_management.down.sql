@@ -0,0 +1,4 @@
+-- 
+ALTER TABLE urls DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE urls DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE urls DROP COLUMN IF EXISTS disabled;
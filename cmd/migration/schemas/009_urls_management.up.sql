@@ -0,0 +1,4 @@
+-- the links are disabled temporarily or deleted softly (their ids are never reused)
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NULL;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
@@ -0,0 +1,6 @@
+-- 
+ALTER TABLE urls DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS api_keys;
+
+DROP TABLE IF EXISTS tenants;
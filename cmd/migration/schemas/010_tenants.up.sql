@@ -0,0 +1,20 @@
+-- the tenants own the links, their requests are authenticated via the api keys
+CREATE TABLE IF NOT EXISTS tenants (
+	id VARCHAR(64) PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+-- only the sha256 of the api keys are stored
+CREATE TABLE IF NOT EXISTS api_keys (
+	key_hash CHAR(64) PRIMARY KEY,
+	tenant_id VARCHAR(64) NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+	name TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_tenant_id_idx ON api_keys (tenant_id);
+
+-- the tenant which has shortened the link, the existing links have no owner
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS owner_id VARCHAR(64) NULL;
@@ -0,0 +1,3 @@
+-- 
+DROP INDEX IF EXISTS urls_url_trgm_idx;
+DROP INDEX IF EXISTS urls_owner_id_created_at_idx;
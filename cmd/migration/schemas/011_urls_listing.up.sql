@@ -0,0 +1,6 @@
+-- the links of an owner are listed (and paginated) by their creation time
+CREATE INDEX IF NOT EXISTS urls_owner_id_created_at_idx ON urls (owner_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
+
+-- the trigram index serves the substring searches on the destinations
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS urls_url_trgm_idx ON urls USING GIN (url gin_trgm_ops) WHERE deleted_at IS NULL;
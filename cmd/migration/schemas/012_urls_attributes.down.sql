@@ -0,0 +1,6 @@
+-- 
+DROP TABLE IF EXISTS url_tags;
+
+ALTER TABLE urls DROP COLUMN IF EXISTS metadata;
+ALTER TABLE urls DROP COLUMN IF EXISTS title;
+ALTER TABLE urls DROP COLUMN IF EXISTS campaign;
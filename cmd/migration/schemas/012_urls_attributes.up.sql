@@ -0,0 +1,14 @@
+-- the descriptive attributes of the links, the metadata is an arbitrary json object
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS campaign TEXT NULL;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT NULL;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS metadata JSONB NULL;
+
+-- the free-form tags of the links
+CREATE TABLE IF NOT EXISTS url_tags (
+	url_id VARCHAR(12) NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+	tag VARCHAR(64) NOT NULL,
+	PRIMARY KEY (url_id, tag)
+);
+
+-- btree index for finding the links carrying a tag
+CREATE INDEX IF NOT EXISTS url_tags_tag_url_id_idx ON url_tags (tag, url_id);
@@ -0,0 +1,23 @@
+-- 
+DROP INDEX IF EXISTS clicks_domain_url_id_clicked_at_idx;
+CREATE INDEX IF NOT EXISTS clicks_url_id_clicked_at_idx ON clicks (url_id, clicked_at);
+ALTER TABLE clicks DROP COLUMN IF EXISTS domain;
+
+DROP INDEX IF EXISTS url_tags_tag_domain_url_id_idx;
+CREATE INDEX IF NOT EXISTS url_tags_tag_url_id_idx ON url_tags (tag, url_id);
+
+-- the links of the branded domains are dropped, their ids may collide with the default domain
+DELETE FROM urls WHERE domain <> '';
+
+ALTER TABLE url_tags DROP CONSTRAINT IF EXISTS url_tags_domain_url_id_fkey;
+ALTER TABLE url_tags DROP CONSTRAINT IF EXISTS url_tags_pkey;
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_domain_id_key;
+
+ALTER TABLE urls ADD CONSTRAINT urls_id_key UNIQUE (id);
+ALTER TABLE url_tags ADD CONSTRAINT url_tags_pkey PRIMARY KEY (url_id, tag);
+ALTER TABLE url_tags ADD CONSTRAINT url_tags_url_id_fkey FOREIGN KEY (url_id) REFERENCES urls (id) ON DELETE CASCADE;
+
+ALTER TABLE url_tags DROP COLUMN IF EXISTS domain;
+ALTER TABLE urls DROP COLUMN IF EXISTS domain;
+
+DROP TABLE IF EXISTS domains;
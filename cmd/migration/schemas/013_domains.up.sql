@@ -0,0 +1,34 @@
+-- the branded short domains, the links of the default domain (the base address) have an empty domain
+CREATE TABLE IF NOT EXISTS domains (
+	host VARCHAR(253) PRIMARY KEY,
+	owner_id VARCHAR(64) NULL,
+	fallback_url TEXT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS domains_owner_id_idx ON domains (owner_id);
+
+-- the ids are unique per domain, so the tags refer to the links by both
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE url_tags ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+
+-- the new constraints are dropped as well (the foreign key first), so the migration can be replayed
+ALTER TABLE url_tags DROP CONSTRAINT IF EXISTS url_tags_domain_url_id_fkey;
+ALTER TABLE url_tags DROP CONSTRAINT IF EXISTS url_tags_url_id_fkey;
+ALTER TABLE url_tags DROP CONSTRAINT IF EXISTS url_tags_pkey;
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_domain_id_key;
+ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_id_key;
+
+ALTER TABLE urls ADD CONSTRAINT urls_domain_id_key UNIQUE (domain, id);
+ALTER TABLE url_tags ADD CONSTRAINT url_tags_pkey PRIMARY KEY (domain, url_id, tag);
+ALTER TABLE url_tags ADD CONSTRAINT url_tags_domain_url_id_fkey
+	FOREIGN KEY (domain, url_id) REFERENCES urls (domain, id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS url_tags_tag_url_id_idx;
+CREATE INDEX IF NOT EXISTS url_tags_tag_domain_url_id_idx ON url_tags (tag, domain, url_id);
+
+-- the clicks are aggregated per domain as well
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS clicks_url_id_clicked_at_idx;
+CREATE INDEX IF NOT EXISTS clicks_domain_url_id_clicked_at_idx ON clicks (domain, url_id, clicked_at);
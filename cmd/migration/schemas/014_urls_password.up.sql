@@ -0,0 +1,2 @@
+-- the bcrypt hash of the password of the protected links
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash TEXT NULL;
@@ -0,0 +1,6 @@
+-- the owners not fitting the narrower column (the subjects of the tokens) are dropped, their links are kept without owner
+UPDATE domains SET owner_id = NULL WHERE length(owner_id) > 64;
+UPDATE urls SET owner_id = NULL WHERE length(owner_id) > 64;
+
+ALTER TABLE domains ALTER COLUMN owner_id TYPE VARCHAR(64);
+ALTER TABLE urls ALTER COLUMN owner_id TYPE VARCHAR(64);
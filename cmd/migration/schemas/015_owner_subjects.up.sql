@@ -0,0 +1,3 @@
+-- the subjects of the tokens are namespaced by their issuer, so they're longer than the tenant ids
+ALTER TABLE urls ALTER COLUMN owner_id TYPE VARCHAR(255);
+ALTER TABLE domains ALTER COLUMN owner_id TYPE VARCHAR(255);
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"github.com/mohammadne/fesghel/internal/analytics"
+	"github.com/mohammadne/fesghel/internal/api/http"
+	"github.com/mohammadne/fesghel/internal/ratelimit"
+	"github.com/mohammadne/fesghel/internal/tenants"
 	"github.com/mohammadne/fesghel/internal/urls"
 	"github.com/mohammadne/fesghel/pkg/observability/logger"
 )
 
 type Config struct {
-	URLs   *urls.Config   `required:"true"`
-	Logger *logger.Config `required:"true"`
+	HTTP      *http.Config      `required:"true"`
+	URLs      *urls.Config      `required:"true"`
+	Analytics *analytics.Config `required:"true"`
+	Tenants   *tenants.Config   `required:"true"`
+	RateLimit *ratelimit.Config `required:"true" split_words:"true"`
+	Logger    *logger.Config    `required:"true"`
 }
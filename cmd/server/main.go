@@ -11,9 +11,12 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/mohammadne/fesghel/cmd"
+	"github.com/mohammadne/fesghel/internal/analytics"
 	"github.com/mohammadne/fesghel/internal/api/http"
 	"github.com/mohammadne/fesghel/internal/config"
 	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/ratelimit"
+	"github.com/mohammadne/fesghel/internal/tenants"
 	"github.com/mohammadne/fesghel/internal/urls"
 	"github.com/mohammadne/fesghel/pkg/observability/logger"
 )
@@ -48,13 +51,31 @@ func main() {
 		log.Fatalf("failed to initialize urls: \n%v", err)
 	}
 
+	analytics, err := analytics.NewService(cfg.Analytics, logger)
+	if err != nil {
+		log.Fatalf("failed to initialize analytics: \n%v", err)
+	}
+
+	tenants, err := tenants.NewService(cfg.Tenants, logger)
+	if err != nil {
+		log.Fatalf("failed to initialize tenants: \n%v", err)
+	}
+
+	limiter, err := ratelimit.NewService(cfg.RateLimit, logger)
+	if err != nil {
+		log.Fatalf("failed to initialize ratelimit: \n%v", err)
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 	var wg sync.WaitGroup
 
 	wg.Add(1)
-	go http.New(logger, urls).Serve(ctx, &wg, *monitorPort, *requestPort)
+	go http.New(cfg.HTTP, logger, urls, analytics, tenants, limiter).Serve(ctx, &wg, *monitorPort, *requestPort)
 
 	<-ctx.Done()
 	wg.Wait()
+
+	// the http servers have been shutdown, so no more clicks will be recorded
+	analytics.Close()
 }
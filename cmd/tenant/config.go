@@ -0,0 +1,7 @@
+package main
+
+import "github.com/mohammadne/fesghel/internal/tenants"
+
+type Config struct {
+	Tenants *tenants.Config `required:"true"`
+}
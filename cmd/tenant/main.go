@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/config"
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/tenants"
+)
+
+func main() {
+	action := flag.String("action", "", "Either 'CREATE' (a tenant alongside its first api key) or 'ISSUE' (a new api key)")
+	tenantID := flag.String("tenant", "", "The tenant id, it's generated on creation when not given")
+	name := flag.String("name", "", "The name of the tenant on creation")
+	keyName := flag.String("key-name", "default", "The name of the issued api key")
+	environmentRaw := flag.String("environment", "", "The environment (default: local)")
+	flag.Parse() // Parse the command-line flags
+
+	entities.LoadEnvironment(*environmentRaw)
+	var cfg Config
+	if err := config.Load(&cfg); err != nil {
+		log.Panicf("failed to load config: \n%v", err)
+	}
+
+	service, err := tenants.NewService(cfg.Tenants, zap.NewNop())
+	if err != nil {
+		log.Fatalf("failed to initialize tenants: \n%v", err)
+	}
+
+	ctx := context.Background()
+	tenant := entities.Tenant{ID: *tenantID, Name: *name}
+
+	switch strings.ToUpper(*action) {
+	case "CREATE":
+		if tenant, err = service.CreateTenant(ctx, tenant); err != nil {
+			log.Fatalf("error creating the tenant\n%v", err)
+		}
+		log.Printf("tenant %s has been created", tenant.ID)
+	case "ISSUE":
+		if len(tenant.ID) == 0 {
+			log.Fatalf("the tenant should be given to issue an api key")
+		}
+	default:
+		log.Fatalf("invalid action %q, either 'CREATE' or 'ISSUE'", *action)
+	}
+
+	key, err := service.IssueKey(ctx, tenant.ID, *keyName)
+	if err != nil {
+		log.Fatalf("error issuing the api key\n%v", err)
+	}
+
+	// the key is never stored, so it's printed once alone to be piped into the secret stores
+	log.Println("api key has been issued, keep it safe since it can't be retrieved again")
+	fmt.Println(key)
+}
@@ -30,6 +30,9 @@ let generatedIds = [];
 const MONITORING_URL = 'http://localhost:8001';
 const REQUEST_URL = 'http://localhost:8002/api/v1';
 
+// The api key of a tenant, issued via: go run ./cmd/tenant -action CREATE
+const API_KEY = __ENV.API_KEY || '';
+
 // Function for shortening a URL and collecting IDs
 function shortenUrl() {
     const shortenPayload = JSON.stringify({
@@ -39,6 +42,7 @@ function shortenUrl() {
     const headers = {
         headers: {
             'Content-Type': 'application/json',
+            'X-API-Key': API_KEY,
         },
     };
 
@@ -56,7 +60,7 @@ function shortenUrl() {
 
 // Function for getting a shortened URL using an ID
 function getShortenedUrl(id) {
-    const getRes = http.get(`${REQUEST_URL}/shorten/${id}`);
+    const getRes = http.get(`${REQUEST_URL}/shorten/${id}`, { headers: { 'X-API-Key': API_KEY } });
 
     check(getRes, {
         'get status is 200': (r) => r.status === 200,
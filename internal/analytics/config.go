@@ -0,0 +1,13 @@
+package analytics
+
+import (
+	"github.com/mohammadne/fesghel/internal/entities"
+	postgres_pkg "github.com/mohammadne/fesghel/pkg/databases/postgres"
+)
+
+type Config struct {
+	Postgres      *postgres_pkg.Config `required:"true"`
+	BufferSize    int                  `default:"10000" split_words:"true"`
+	BatchSize     int                  `default:"500" split_words:"true"`
+	FlushInterval entities.Interval    `default:"5s" split_words:"true"`
+}
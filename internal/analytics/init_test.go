@@ -0,0 +1,56 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	postgres_pkg "github.com/mohammadne/fesghel/pkg/databases/postgres"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+var (
+	mockDatabase     sqlmock.Sqlmock
+	postgresInstance Postgres
+)
+
+func TestMain(m *testing.M) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not start sqlmock: %v\n", err)
+		os.Exit(1) // Exit with a non-zero status code
+	}
+	defer sqlDB.Close()
+	mockDatabase = mock
+	sqlxDB := sqlx.NewDb(sqlDB, "sqlmock")
+
+	vectors := postgres_pkg.Vectors{
+		Counter:   metrics_pkg.RegisterCounterNoop(),
+		Histogram: metrics_pkg.RegisterHistogramNoop(),
+	}
+
+	postgresInstance = &postgres{
+		instance: &postgres_pkg.Postgres{DB: sqlxDB, Vectors: &vectors},
+	}
+
+	m.Run()
+}
+
+type mockPostgres struct{ mock.Mock }
+
+func (m *mockPostgres) insert(ctx context.Context, clicks []entities.Click) (err error) {
+	// the batch is reused by the worker, so a copy is recorded
+	args := m.Called(ctx, append([]entities.Click(nil), clicks...))
+	return args.Error(0)
+}
+
+func (m *mockPostgres) stats(ctx context.Context, domain, id string) (stats entities.Stats, err error) {
+	args := m.Called(ctx, domain, id)
+	return args.Get(0).(entities.Stats), args.Error(1)
+}
@@ -0,0 +1,122 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	postgres_pkg "github.com/mohammadne/fesghel/pkg/databases/postgres"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+type Postgres interface {
+	insert(ctx context.Context, clicks []entities.Click) (err error)
+	stats(ctx context.Context, domain, id string) (stats entities.Stats, err error)
+}
+
+type postgres struct {
+	instance *postgres_pkg.Postgres
+}
+
+func NewPostgres(cfg *postgres_pkg.Config) (Postgres, error) {
+	instance, err := postgres_pkg.Open(cfg, entities.Namespace, entities.System)
+	if err != nil {
+		return nil, err
+	}
+	return &postgres{instance: instance}, nil
+}
+
+var (
+	errInsertingClicks = errors.New("error inserting clicks")
+)
+
+const (
+	queryInsert = `
+	INSERT INTO clicks (url_id, clicked_at, referrer, user_agent, country, domain)
+	VALUES `
+	clickColumns = 6
+)
+
+// insert stores all of the clicks via a single multi-row statement
+func (s *postgres) insert(ctx context.Context, clicks []entities.Click) (err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("clicks", "insert", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("clicks", "insert", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "clicks", "insert")
+	}(time.Now())
+
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(queryInsert)
+	args := make([]any, 0, len(clicks)*clickColumns)
+
+	for index, click := range clicks {
+		if index > 0 {
+			query.WriteString(", ")
+		}
+		base := index * clickColumns
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4, base+5, base+6)
+		args = append(args, click.ID, click.Timestamp, click.Referrer, click.UserAgent, click.Country, click.Domain)
+	}
+
+	if _, err = s.instance.ExecContext(ctx, query.String(), args...); err != nil {
+		return errors.Join(errInsertingClicks, err)
+	}
+
+	return nil
+}
+
+var (
+	errRetrievingStats = errors.New("error retrieving stats")
+)
+
+const (
+	queryStats = `
+	SELECT date_trunc('day', clicked_at AT TIME ZONE 'UTC') AS day, COUNT(*) AS clicks
+	FROM clicks
+	WHERE domain = $1 AND url_id = $2
+	GROUP BY day
+	ORDER BY day`
+)
+
+func (s *postgres) stats(ctx context.Context, domain, id string) (stats entities.Stats, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("clicks", "stats", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("clicks", "stats", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "clicks", "stats")
+	}(time.Now())
+
+	rows, err := s.instance.QueryContext(ctx, queryStats, domain, id)
+	if err != nil {
+		return entities.Stats{}, errors.Join(errRetrievingStats, err)
+	}
+	defer rows.Close()
+
+	stats.Days = make([]entities.DailyClicks, 0)
+	for rows.Next() {
+		var day entities.DailyClicks
+		if err = rows.Scan(&day.Day, &day.Clicks); err != nil {
+			return entities.Stats{}, errors.Join(errRetrievingStats, err)
+		}
+		stats.Total += day.Clicks
+		stats.Days = append(stats.Days, day)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entities.Stats{}, errors.Join(errRetrievingStats, err)
+	}
+
+	return stats, nil
+}
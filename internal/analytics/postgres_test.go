@@ -0,0 +1,72 @@
+package analytics
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestPostgresInsert(t *testing.T) {
+	timestamp := time.Now()
+	clicks := []entities.Click{
+		{ID: "abc", Timestamp: timestamp, Referrer: "https://google.com", UserAgent: "curl", Country: "IR"},
+		{ID: "def", Domain: "go.acme.com", Timestamp: timestamp},
+	}
+
+	t.Run("multi-row insert", func(t *testing.T) {
+		mockDatabase.
+			ExpectExec(regexp.QuoteMeta(queryInsert+"($1, $2, $3, $4, $5, $6), ($7, $8, $9, $10, $11, $12)")).
+			WithArgs("abc", timestamp, "https://google.com", "curl", "IR", "", "def", timestamp, "", "", "", "go.acme.com").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+
+		if err := postgresInstance.insert(context.TODO(), clicks); err != nil {
+			t.Errorf("expect no errors %v", err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		if err := postgresInstance.insert(context.TODO(), nil); err != nil {
+			t.Errorf("expect no errors %v", err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestPostgresStats(t *testing.T) {
+	var (
+		sampleID = "abc"
+		today    = time.Now().UTC().Truncate(24 * time.Hour)
+	)
+
+	mockDatabase.
+		ExpectQuery(regexp.QuoteMeta(queryStats)).
+		WithArgs("", sampleID).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "clicks"}).
+			AddRow(today.Add(-24*time.Hour), 3).
+			AddRow(today, 4))
+
+	stats, err := postgresInstance.stats(context.TODO(), "", sampleID)
+	if err != nil {
+		t.Errorf("expect no errors %v", err)
+	}
+
+	if stats.Total != 7 || len(stats.Days) != 2 || stats.Days[1].Clicks != 4 {
+		t.Errorf("invalid stats has been returned %+v", stats)
+	}
+
+	if err := mockDatabase.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
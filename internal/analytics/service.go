@@ -0,0 +1,159 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+type Service interface {
+	// Record queues the click to be stored asynchronously, it never blocks the caller
+	Record(click entities.Click)
+
+	// Stats returns the total and per-day clicks of the given shortened id on the domain
+	Stats(ctx context.Context, domain, id string) (entities.Stats, error)
+
+	// Close flushes the queued clicks and stops the background worker
+	Close()
+}
+
+type service struct {
+	config   *Config
+	logger   *zap.Logger
+	metrics  *metrics
+	postgres Postgres
+
+	clicks chan entities.Click
+	done   chan struct{}
+	once   sync.Once
+}
+
+// maxBatchSize keeps a batch insert below the 65535 parameters limit of postgres
+const maxBatchSize = 65535 / clickColumns
+
+const flushTimeout = 10 * time.Second
+
+func NewService(cfg *Config, l *zap.Logger) (Service, error) {
+	metrics, err := newMetrics()
+	if err != nil {
+		l.Panic("error registering analytics metrics", zap.Error(err))
+	}
+
+	postgres, err := NewPostgres(cfg.Postgres)
+	if err != nil {
+		l.Panic("error loading Postgres instance", zap.Error(err))
+	}
+
+	return newService(cfg, l, metrics, postgres), nil
+}
+
+func newService(cfg *Config, l *zap.Logger, m *metrics, p Postgres) *service {
+	if cfg.BatchSize <= 0 || cfg.BatchSize > maxBatchSize {
+		cfg.BatchSize = maxBatchSize
+	}
+
+	svc := &service{
+		config:   cfg,
+		logger:   l,
+		metrics:  m,
+		postgres: p,
+		clicks:   make(chan entities.Click, cfg.BufferSize),
+		done:     make(chan struct{}),
+	}
+
+	go svc.worker()
+	return svc
+}
+
+func (s *service) Record(click entities.Click) {
+	select {
+	case s.clicks <- click:
+		s.metrics.Counter.IncrementVector("record", metrics_pkg.StatusSuccess)
+	default:
+		// the buffer is full, dropping the click is preferred to slowing down the redirects
+		s.metrics.Counter.IncrementVector("record", metrics_pkg.StatusFailure)
+	}
+}
+
+func (s *service) Close() {
+	s.once.Do(func() {
+		close(s.clicks)
+		<-s.done
+	})
+}
+
+// worker batches the queued clicks, a batch is flushed either when it's full or periodically
+func (s *service) worker() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.FlushInterval.Duration())
+	defer ticker.Stop()
+
+	batch := make([]entities.Click, 0, s.config.BatchSize)
+	for {
+		select {
+		case click, ok := <-s.clicks:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+
+			batch = append(batch, click)
+			if len(batch) >= s.config.BatchSize {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			s.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (s *service) flush(batch []entities.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	if err := s.postgres.insert(ctx, batch); err != nil {
+		s.logger.Error("error storing clicks", zap.Int("count", len(batch)), zap.Error(err))
+		s.metrics.Counter.IncrementVector("flush", metrics_pkg.StatusFailure)
+		return
+	}
+
+	s.metrics.Histogram.ObserveResponseTime(start, "flush")
+	s.metrics.Counter.IncrementVector("flush", metrics_pkg.StatusSuccess)
+}
+
+var (
+	ErrRetrievingStats = errors.New("error retrieving stats from database")
+)
+
+func (s *service) Stats(ctx context.Context, domain, id string) (stats entities.Stats, err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "stats")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("stats", status)
+	}(time.Now())
+
+	stats, err = s.postgres.stats(ctx, domain, id)
+	if err != nil {
+		return entities.Stats{}, errors.Join(ErrRetrievingStats, err)
+	}
+
+	return stats, nil
+}
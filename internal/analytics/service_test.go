@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func newServiceInstance(cfg *Config) (*service, *mockPostgres) {
+	postgresMock := new(mockPostgres)
+	return newService(cfg, zap.NewNop(), newMetricsNoop(), postgresMock), postgresMock
+}
+
+func TestServiceRecord(t *testing.T) {
+	click := entities.Click{ID: "abc", Timestamp: time.Now()}
+
+	t.Run("flush on close", func(t *testing.T) {
+		svc, postgresMock := newServiceInstance(&Config{BufferSize: 10, BatchSize: 10, FlushInterval: entities.Interval(time.Hour)})
+
+		postgresMock.
+			On("insert", mock.Anything, []entities.Click{click, click}).
+			Return(nil).Once()
+
+		svc.Record(click)
+		svc.Record(click)
+		svc.Close()
+
+		postgresMock.AssertExpectations(t)
+	})
+
+	t.Run("flush on full batch", func(t *testing.T) {
+		svc, postgresMock := newServiceInstance(&Config{BufferSize: 10, BatchSize: 2, FlushInterval: entities.Interval(time.Hour)})
+
+		postgresMock.
+			On("insert", mock.Anything, []entities.Click{click, click}).
+			Return(nil).Twice()
+
+		for range 4 {
+			svc.Record(click)
+		}
+		svc.Close()
+
+		postgresMock.AssertExpectations(t)
+	})
+
+	t.Run("flush periodically", func(t *testing.T) {
+		svc, postgresMock := newServiceInstance(&Config{BufferSize: 10, BatchSize: 10, FlushInterval: entities.Interval(10 * time.Millisecond)})
+		defer svc.Close()
+
+		flushed := make(chan struct{})
+		postgresMock.
+			On("insert", mock.Anything, []entities.Click{click}).
+			Run(func(mock.Arguments) { close(flushed) }).
+			Return(nil).Once()
+
+		svc.Record(click)
+
+		select {
+		case <-flushed:
+		case <-time.After(time.Second):
+			t.Error("expect the click to be flushed periodically")
+		}
+	})
+}
+
+func TestServiceStats(t *testing.T) {
+	svc, postgresMock := newServiceInstance(&Config{BufferSize: 1, BatchSize: 1, FlushInterval: entities.Interval(time.Hour)})
+	defer svc.Close()
+
+	t.Run("success", func(t *testing.T) {
+		expected := entities.Stats{Total: 1, Days: []entities.DailyClicks{{Day: time.Now(), Clicks: 1}}}
+		postgresMock.On("stats", mock.Anything, "", "abc").Return(expected, nil).Once()
+
+		stats, err := svc.Stats(context.TODO(), "", "abc")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, stats)
+	})
+
+	t.Run("postgres error", func(t *testing.T) {
+		postgresMock.On("stats", mock.Anything, "", "def").Return(entities.Stats{}, errRetrievingStats).Once()
+
+		_, err := svc.Stats(context.TODO(), "", "def")
+		if !errors.Is(err, ErrRetrievingStats) {
+			t.Errorf("expect ErrRetrievingStats error %v", err)
+		}
+	})
+
+	postgresMock.AssertExpectations(t)
+}
@@ -0,0 +1,18 @@
+package http
+
+import (
+	"time"
+
+	"github.com/mohammadne/fesghel/pkg/jwt"
+)
+
+type Config struct {
+	// RedirectStatus is the global redirect used for the links not having their own
+	RedirectStatus int `default:"301" split_words:"true"`
+	// RedirectMaxAge is how long clients may cache the permanent redirects
+	RedirectMaxAge time.Duration `default:"24h" split_words:"true"`
+	// CountryHeader is set by the edge (e.g. CDN) to the client's country code
+	CountryHeader string `default:"CF-IPCountry" split_words:"true"`
+	// JWT accepts the bearer tokens alongside the api keys, only when its key set is given
+	JWT *jwt.Config `required:"false"`
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/api/http/i18n"
+	"github.com/mohammadne/fesghel/internal/api/http/middlewares"
+	"github.com/mohammadne/fesghel/internal/api/http/models"
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/urls"
+)
+
+func NewDomains(r fiber.Router, logger *zap.Logger, i18n i18n.I18N, urls urls.Service) {
+	handler := &domains{
+		logger: logger,
+		i18n:   i18n,
+		urls:   urls,
+	}
+
+	read := middlewares.RequireScope(i18n, entities.ScopeLinksRead)
+	write := middlewares.RequireScope(i18n, entities.ScopeLinksWrite)
+	// the registered domains are treated as ours (e.g. refused as destinations), so only the admins register them
+	admin := middlewares.RequireScope(i18n, entities.ScopeAdmin)
+
+	g := r.Group("domains")
+	g.Post("/", admin, handler.createDomain)
+	g.Get("/", read, handler.listDomains)
+	g.Patch("/:host", write, handler.updateDomain)
+	g.Delete("/:host", write, handler.deleteDomain)
+}
+
+type domains struct {
+	logger *zap.Logger
+	i18n   i18n.I18N
+	urls   urls.Service
+}
+
+// createDomain registers the domain for the given owner (e.g. the tenant), it's owned by the admin otherwise
+func (d *domains) createDomain(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	request := models.DomainRequest{}
+	if err := c.Bind().Body(&request); err != nil || len(request.Host) == 0 {
+		response.Message = d.i18n.Translate("domains.error_request", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	ownerID := principal.Subject
+	if len(request.OwnerID) != 0 {
+		ownerID = request.OwnerID
+	}
+
+	domain, err := d.urls.CreateDomain(c.Context(), entities.Domain{
+		Host:        request.Host,
+		OwnerID:     ownerID,
+		FallbackURL: entities.URL(request.FallbackURL),
+	})
+	if err != nil {
+		return d.writeError(c, response, language, err)
+	}
+
+	response.Request = toDomainResponse(domain)
+	response.Message = d.i18n.Translate("domains.created", language)
+	return response.Write(c, fiber.StatusCreated)
+}
+
+// listDomains lists the caller's domains, the admins are given all of the domains
+func (d *domains) listDomains(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	ownerID := principal.Subject
+	if principal.Has(entities.ScopeAdmin) {
+		ownerID = ""
+	}
+
+	registered, err := d.urls.Domains(c.Context(), ownerID)
+	if err != nil {
+		return d.writeError(c, response, language, err)
+	}
+
+	domains := make([]models.DomainResponse, 0, len(registered))
+	for _, domain := range registered {
+		domains = append(domains, toDomainResponse(domain))
+	}
+
+	response.Request = domains
+	response.Message = d.i18n.Translate("domains.listed", language)
+	return response.Write(c, fiber.StatusOK)
+}
+
+// updateDomain replaces the fallback url of the domain, it's removed when not given
+func (d *domains) updateDomain(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	host, err := d.authorize(c, principal)
+	if err != nil {
+		return d.writeError(c, response, language, err)
+	}
+
+	request := models.DomainRequest{}
+	if err := c.Bind().Body(&request); err != nil {
+		response.Message = d.i18n.Translate("domains.error_request", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	domain := entities.Domain{Host: host, FallbackURL: entities.URL(request.FallbackURL)}
+	if err := d.urls.UpdateDomain(c.Context(), domain); err != nil {
+		return d.writeError(c, response, language, err)
+	}
+
+	response.Message = d.i18n.Translate("domains.updated", language)
+	return response.Write(c, fiber.StatusOK)
+}
+
+func (d *domains) deleteDomain(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	host, err := d.authorize(c, principal)
+	if err != nil {
+		return d.writeError(c, response, language, err)
+	}
+
+	if err := d.urls.DeleteDomain(c.Context(), host); err != nil {
+		return d.writeError(c, response, language, err)
+	}
+
+	response.Message = d.i18n.Translate("domains.deleted", language)
+	return response.Write(c, fiber.StatusOK)
+}
+
+// authorize checks the ownership of the domain given by the path, then returns its (normalized) host.
+// The admins manage the domains of all owners.
+func (d *domains) authorize(c fiber.Ctx, principal entities.Principal) (string, error) {
+	host, err := d.urls.NormalizeDomain(c.Params("host"))
+	if err != nil {
+		return "", err
+	}
+
+	if len(host) == 0 {
+		return "", urls.ErrDomainNotExists // the default domain can't be managed
+	}
+
+	if principal.Has(entities.ScopeAdmin) {
+		return host, nil
+	}
+	return host, d.urls.AuthorizeDomain(c.Context(), host, principal.Subject)
+}
+
+// writeError writes the error of managing the domains, the fallback urls are validated the same as the destinations
+func (d *domains) writeError(c fiber.Ctx, response *models.Response, language entities.Language, err error) error {
+	status, key := fiber.StatusInternalServerError, "domains.error"
+	switch {
+	case errors.Is(err, urls.ErrDomainInvalid):
+		status, key = fiber.StatusBadRequest, "domains.invalid_host"
+	case errors.Is(err, urls.ErrDomainNotExists):
+		status, key = fiber.StatusNotFound, "domains.not_exists"
+	case errors.Is(err, urls.ErrDomainNotOwner):
+		status, key = fiber.StatusForbidden, "domains.not_owner"
+	case errors.Is(err, urls.ErrDomainExists):
+		status, key = fiber.StatusConflict, "domains.exists"
+	case errors.Is(err, urls.ErrDomainInUse):
+		status, key = fiber.StatusConflict, "domains.in_use"
+	case errors.Is(err, urls.ErrFallbackURLSelf):
+		status, key = fiber.StatusBadRequest, "domains.invalid_fallback_url"
+	default:
+		if fallbackStatus, _ := shortenError(err); fallbackStatus != fiber.StatusInternalServerError {
+			status, key = fallbackStatus, "domains.invalid_fallback_url"
+		} else {
+			d.logger.Error("error managing the domains", zap.Error(err))
+		}
+	}
+
+	response.Message = d.i18n.Translate(key, language)
+	return response.Write(c, status)
+}
+
+func toDomainResponse(domain entities.Domain) models.DomainResponse {
+	return models.DomainResponse{Host: domain.Host, FallbackURL: domain.FallbackURL, CreatedAt: domain.CreatedAt}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"math"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/ratelimit"
+)
+
+//go:embed templates/password.html
+var templates embed.FS
+
+var passwordTemplate = template.Must(template.ParseFS(templates, "templates/password.html"))
+
+// passwordPage is the data of the password form, all of the texts are localized
+type passwordPage struct {
+	Language  entities.Language
+	Direction string
+	Title     string
+	Prompt    string
+	Submit    string
+	Error     string
+}
+
+// pageLanguage is the language of the pages rendered for the browsers, the language header
+// is preferred (the same as the api) then the best match of the Accept-Language is used.
+func pageLanguage(c fiber.Ctx) entities.Language {
+	if header := c.Get("language"); len(header) != 0 {
+		return entities.ToLanguage(header)
+	}
+	return entities.ToLanguage(c.AcceptsLanguages(string(entities.LanguageEnglish), string(entities.LanguagePersian)))
+}
+
+// passwordForm renders the form asking for the password of the protected link, the error is a translation key
+func (r *route) passwordForm(c fiber.Ctx, status int, errorKey string) error {
+	language := pageLanguage(c)
+	page := passwordPage{
+		Language:  language,
+		Direction: r.i18n.Translate("password.direction", language),
+		Title:     r.i18n.Translate("password.title", language),
+		Prompt:    r.i18n.Translate("password.prompt", language),
+		Submit:    r.i18n.Translate("password.submit", language),
+	}
+	if len(errorKey) != 0 {
+		page.Error = r.i18n.Translate(errorKey, language)
+	}
+
+	var body bytes.Buffer
+	if err := passwordTemplate.Execute(&body, page); err != nil {
+		r.logger.Error("error rendering the password form", zap.Error(err))
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
+
+	c.Set(fiber.HeaderCacheControl, noCache)
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(status).Send(body.Bytes())
+}
+
+// allowAttempt counts the password attempt of the client on the link, it returns the status and the translation
+// key of the form when the attempt is refused. Unlike the other budgets the attempts are refused when the limiter
+// fails, since the passwords could be brute forced meanwhile.
+func (r *route) allowAttempt(c fiber.Ctx, domain, id string) (int, string) {
+	subject := "ip:" + c.IP() + ":" + domain + "/" + id
+	result, err := r.limiter.Allow(c.Context(), ratelimit.BudgetPassword, subject, 1)
+	if err != nil {
+		r.logger.Error("error rate limiting the password attempt", zap.Error(err))
+		return fiber.StatusServiceUnavailable, "password.unavailable"
+	}
+
+	if !result.Allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(result.Reset.Seconds()))))
+		return fiber.StatusTooManyRequests, "password.too_many_attempts"
+	}
+	return 0, ""
+}
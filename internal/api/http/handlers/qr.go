@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/api/http/models"
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/pkg/qrcode"
+)
+
+const (
+	qrFormatPNG = "png"
+	qrFormatSVG = "svg"
+
+	qrDefaultSize   = 256 // pixels
+	qrMaxSize       = 4096
+	qrDefaultMargin = 4 // modules, the quiet zone recommended by the standard
+	qrMaxMargin     = 32
+
+	// the rendered codes never change for the same options, only the link may stop existing
+	qrCacheControl = "private, max-age=86400"
+)
+
+// qrOptions are the rendering options given as the query parameters
+type qrOptions struct {
+	format string
+	size   int
+	level  qrcode.Level
+	margin int
+}
+
+// toQROptions parses the format (png or svg), size (in pixels), level (L, M, Q or H) and margin (in modules)
+func toQROptions(c fiber.Ctx) (options qrOptions, ok bool) {
+	options = qrOptions{format: qrFormatPNG, size: qrDefaultSize, level: qrcode.LevelMedium, margin: qrDefaultMargin}
+
+	if format := strings.ToLower(c.Query("format")); len(format) != 0 {
+		if format != qrFormatPNG && format != qrFormatSVG {
+			return qrOptions{}, false
+		}
+		options.format = format
+	}
+
+	if rawLevel := c.Query("level"); len(rawLevel) != 0 {
+		if options.level, ok = qrcode.ToLevel(rawLevel); !ok {
+			return qrOptions{}, false
+		}
+	}
+
+	var err error
+	if rawSize := c.Query("size"); len(rawSize) != 0 {
+		if options.size, err = strconv.Atoi(rawSize); err != nil || options.size <= 0 || options.size > qrMaxSize {
+			return qrOptions{}, false
+		}
+	}
+
+	if rawMargin := c.Query("margin"); len(rawMargin) != 0 {
+		if options.margin, err = strconv.Atoi(rawMargin); err != nil || options.margin < 0 || options.margin > qrMaxMargin {
+			return qrOptions{}, false
+		}
+	}
+
+	return options, true
+}
+
+// etag identifies the rendered code, since it's determined by the content and the options
+func (o qrOptions) etag(content string) string {
+	hash := sha256.Sum256(fmt.Appendf(nil, "%s|%s|%d|%d|%d", content, o.format, o.size, o.level, o.margin))
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header holds the etag (the weak comparison is used)
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// qrCode renders the qr code of the short url of the link
+func (s *shorten) qrCode(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+
+	id := c.Params("id")
+	if len(id) == 0 {
+		response.Message = s.i18n.Translate("shorten.qr_code.id_not_given", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	options, ok := toQROptions(c)
+	if !ok {
+		response.Message = s.i18n.Translate("shorten.qr_code.invalid_options", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	domain, err := s.urls.NormalizeDomain(c.Query("domain"))
+	if err != nil {
+		response.Message = s.i18n.Translate("shorten.retrieve_url.not_exists", language)
+		return response.Write(c, fiber.StatusNotFound)
+	}
+
+	if _, err := s.urls.Retrieve(c.Context(), domain, id); err != nil {
+		return s.writeRetrieveError(c, response, language, id, err)
+	}
+
+	content := s.urls.ShortURL(domain, id)
+	etag := options.etag(content)
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderCacheControl, qrCacheControl)
+
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	code, err := qrcode.Encode(content, options.level)
+	if err != nil {
+		s.logger.Error("error encoding the qr code", zap.String("id", id), zap.Error(err))
+		response.Message = s.i18n.Translate("shorten.qr_code.error", language)
+		return response.Write(c, fiber.StatusInternalServerError)
+	}
+
+	if options.format == qrFormatSVG {
+		c.Set(fiber.HeaderContentType, "image/svg+xml")
+		return c.Status(fiber.StatusOK).Send(code.SVG(options.size, options.margin))
+	}
+
+	image, err := code.PNG(options.size, options.margin)
+	if err != nil {
+		response.Message = s.i18n.Translate("shorten.qr_code.size_too_small", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	c.Set(fiber.HeaderContentType, "image/png")
+	return c.Status(fiber.StatusOK).Send(image)
+}
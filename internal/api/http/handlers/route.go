@@ -2,42 +2,161 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"go.uber.org/zap"
 
+	"github.com/mohammadne/fesghel/internal/analytics"
+	"github.com/mohammadne/fesghel/internal/api/http/i18n"
+	"github.com/mohammadne/fesghel/internal/api/http/middlewares"
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/ratelimit"
 	"github.com/mohammadne/fesghel/internal/urls"
 )
 
-func NewRoute(r fiber.Router, logger *zap.Logger, urls urls.Service) {
+func NewRoute(r fiber.Router, logger *zap.Logger, i18n i18n.I18N, redirect entities.Redirect, maxAge time.Duration,
+	countryHeader string, urls urls.Service, analytics analytics.Service, limiter ratelimit.Service) {
 	handler := &route{
-		logger: logger,
-		urls:   urls,
+		logger:        logger,
+		i18n:          i18n,
+		redirect:      redirect,
+		maxAge:        maxAge,
+		countryHeader: countryHeader,
+		urls:          urls,
+		analytics:     analytics,
+		limiter:       limiter,
 	}
 
-	r.Get("/:id", handler.moveURL)
+	limit := middlewares.RateLimit(logger, i18n, limiter, ratelimit.BudgetRedirect)
+	r.Get("/:id", limit, handler.moveURL)
+	r.Post("/:id", limit, handler.unlockURL)
 }
 
 type route struct {
-	logger *zap.Logger
-	urls   urls.Service
+	logger        *zap.Logger
+	i18n          i18n.I18N
+	redirect      entities.Redirect
+	maxAge        time.Duration
+	countryHeader string
+	urls          urls.Service
+	analytics     analytics.Service
+	limiter       ratelimit.Service
 }
 
+// noCache keeps the responses which should always reach the server out of the caches
+const noCache = "private, no-cache, no-store, must-revalidate"
+
 func (r *route) moveURL(c fiber.Ctx) error {
 	id := c.Params("id")
 	if len(id) == 0 {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 
-	url, err := r.urls.Retrieve(c.Context(), id)
+	// the links are namespaced by the domain the request has been sent to
+	domain := r.urls.ResolveHost(c.Host())
+
+	link, err := r.urls.Retrieve(c.Context(), domain.Host, id)
+	if err != nil {
+		return r.writeError(c, domain, err)
+	}
+
+	if link.Protected() {
+		return r.passwordForm(c, fiber.StatusOK, "")
+	}
+
+	r.recordClick(c, link)
+
+	redirect := link.Redirect
+	if redirect == entities.RedirectDefault {
+		redirect = r.redirect
+	}
+
+	c.Set(fiber.HeaderCacheControl, r.cacheControl(link, redirect))
+	return c.Redirect().Status(int(redirect)).To(string(link.URL))
+}
+
+// unlockURL is the submission of the password form, the protected link is redirected to once the password matches
+func (r *route) unlockURL(c fiber.Ctx) error {
+	id := c.Params("id")
+	if len(id) == 0 {
+		return c.SendStatus(fiber.StatusBadRequest)
+	}
+
+	domain := r.urls.ResolveHost(c.Host())
+	if status, errorKey := r.allowAttempt(c, domain.Host, id); len(errorKey) != 0 {
+		return r.passwordForm(c, status, errorKey)
+	}
+
+	link, err := r.urls.Unlock(c.Context(), domain.Host, id, c.FormValue("password"))
 	if err != nil {
-		if errors.Is(err, urls.ErrIDNotExists) {
-			return c.SendStatus(fiber.StatusNotFound)
+		if errors.Is(err, urls.ErrPasswordIncorrect) {
+			return r.passwordForm(c, fiber.StatusUnauthorized, "password.incorrect")
 		}
+		return r.writeError(c, domain, err)
+	}
+
+	r.recordClick(c, link)
+
+	// the browsers follow the see other by a GET, so the password is never sent to the destination
+	c.Set(fiber.HeaderCacheControl, noCache)
+	return c.Redirect().Status(fiber.StatusSeeOther).To(string(link.URL))
+}
+
+// writeError writes the error of retrieving the link, the unknown ids are redirected to the domain's fallback url
+func (r *route) writeError(c fiber.Ctx, domain entities.Domain, err error) error {
+	switch {
+	case errors.Is(err, urls.ErrShortenIDNotExists):
+		if len(domain.FallbackURL) != 0 {
+			c.Set(fiber.HeaderCacheControl, noCache)
+			return c.Redirect().Status(fiber.StatusFound).To(string(domain.FallbackURL))
+		}
+		return c.SendStatus(fiber.StatusNotFound)
+	case errors.Is(err, urls.ErrShortenIDExpired), errors.Is(err, urls.ErrShortenIDDisabled):
+		return c.SendStatus(fiber.StatusGone)
+	case errors.Is(err, urls.ErrDestinationBlocked):
+		return c.SendStatus(fiber.StatusForbidden)
+	default:
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
+}
+
+// recordClick hands the click over to the analytics, the values are copied
+// since fiber reuses the request buffers after the handler returns.
+func (r *route) recordClick(c fiber.Ctx, link entities.Link) {
+	country := strings.ToUpper(c.Get(r.countryHeader))
+	if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+		country = ""
+	}
 
-	return c.Status(fiber.StatusMovedPermanently).JSON(map[string]string{
-		"url": string(url),
+	r.analytics.Record(entities.Click{
+		ID:        strings.Clone(link.ID),
+		Domain:    link.Domain,
+		Timestamp: time.Now(),
+		Referrer:  strings.Clone(c.Get(fiber.HeaderReferer)),
+		UserAgent: strings.Clone(c.Get(fiber.HeaderUserAgent)),
+		Country:   country,
 	})
 }
+
+// cacheControl lets the clients cache the permanent redirects (never beyond the link's expiration),
+// the temporary ones should always reach the server.
+func (r *route) cacheControl(link entities.Link, redirect entities.Redirect) string {
+	if !redirect.Permanent() {
+		return noCache
+	}
+
+	maxAge := r.maxAge
+	if link.ExpiresAt != nil {
+		if remaining := time.Until(*link.ExpiresAt); remaining < maxAge {
+			maxAge = remaining
+		}
+	}
+
+	if seconds := int64(maxAge.Seconds()); seconds > 0 {
+		return fmt.Sprintf("public, max-age=%d", seconds)
+	}
+	return noCache
+}
@@ -1,38 +1,60 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"go.uber.org/zap"
 
+	"github.com/mohammadne/fesghel/internal/analytics"
 	"github.com/mohammadne/fesghel/internal/api/http/i18n"
+	"github.com/mohammadne/fesghel/internal/api/http/middlewares"
 	"github.com/mohammadne/fesghel/internal/api/http/models"
 	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/ratelimit"
 	"github.com/mohammadne/fesghel/internal/urls"
 )
 
-func NewShorten(r fiber.Router, logger *zap.Logger, i18n i18n.I18N, urls urls.Service) {
+func NewShorten(r fiber.Router, logger *zap.Logger, i18n i18n.I18N, urls urls.Service, analytics analytics.Service,
+	limiter ratelimit.Service) {
 	handler := &shorten{
-		logger: logger,
-		i18n:   i18n,
-		urls:   urls,
+		logger:    logger,
+		i18n:      i18n,
+		urls:      urls,
+		analytics: analytics,
 	}
 
+	read := middlewares.RequireScope(i18n, entities.ScopeLinksRead)
+	write := middlewares.RequireScope(i18n, entities.ScopeLinksWrite)
+	limit := middlewares.RateLimit(logger, i18n, limiter, ratelimit.BudgetShorten)
+	limitBatch := middlewares.RateLimitCost(logger, i18n, limiter, ratelimit.BudgetShorten, batchCost)
+
 	g := r.Group("shorten")
-	g.Post("/", handler.shortenURL)
-	g.Get("/:id", handler.retrieveURL)
+	g.Post("/", write, limit, handler.shortenURL)
+	g.Post("/batch", write, limitBatch, handler.shortenBatch)
+	g.Get("/", read, handler.listURLs)
+	g.Get("/:id", read, handler.retrieveURL)
+	g.Get("/:id/stats", read, handler.stats)
+	g.Get("/:id/qr", read, handler.qrCode)
+	g.Put("/:id", write, handler.updateURL)
+	g.Patch("/:id", write, handler.patchURL)
+	g.Delete("/:id", write, handler.deleteURL)
 }
 
 type shorten struct {
-	logger *zap.Logger
-	i18n   i18n.I18N
-	urls   urls.Service
+	logger    *zap.Logger
+	i18n      i18n.I18N
+	urls      urls.Service
+	analytics analytics.Service
 }
 
 func (s *shorten) shortenURL(c fiber.Ctx) error {
 	response := &models.Response{}
 	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
 
 	request := models.ShortenRequest{}
 	if err := c.Bind().Body(&request); err != nil {
@@ -40,21 +62,296 @@ func (s *shorten) shortenURL(c fiber.Ctx) error {
 		return response.Write(c, fiber.StatusBadRequest)
 	}
 
-	id, err := s.urls.Shorten(c.Context(), entities.URL(request.URL))
+	link, ok := toLink(request)
+	if !ok {
+		response.Message = s.i18n.Translate("shorten.shorten_url.invalid_expiration", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+	link.OwnerID = principal.Subject
+
+	domain, err := s.urls.NormalizeDomain(link.Domain)
 	if err != nil {
-		s.logger.Error("error retreiving the data", zap.Error(err))
-		response.Message = s.i18n.Translate("shorten.shorten_url.error_shorten", language)
-		return response.Write(c, fiber.StatusInternalServerError)
+		response.Message = s.i18n.Translate("shorten.shorten_url.invalid_domain", language)
+		return response.Write(c, fiber.StatusBadRequest)
 	}
+	link.Domain = domain
 
-	response.Request = models.ShortenURLResponse{ID: id}
+	id, err := s.urls.Shorten(c.Context(), link)
+	if err != nil {
+		status, key := shortenError(err)
+		if status == fiber.StatusInternalServerError {
+			s.logger.Error("error retreiving the data", zap.Error(err))
+		}
+		response.Message = s.i18n.Translate(key, language)
+		return response.Write(c, status)
+	}
+
+	response.Request = models.ShortenURLResponse{ID: id, Domain: domain, ShortURL: s.urls.ShortURL(domain, id)}
 	response.Message = s.i18n.Translate("shorten.shorten_url.success", language)
 	return response.Write(c, fiber.StatusCreated)
 }
 
+// batchCost charges the batch by the number of its links, so the batches share the budget of the single links.
+// The malformed batches cost a single unit, since they're refused by the handler anyway.
+func batchCost(c fiber.Ctx) int {
+	var links []json.RawMessage
+	if err := json.Unmarshal(c.Body(), &links); err != nil {
+		return 1
+	}
+	return len(links)
+}
+
+func (s *shorten) shortenBatch(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	request := models.ShortenBatchRequest{}
+	if err := c.Bind().Body(&request); err != nil || len(request) == 0 {
+		response.Message = s.i18n.Translate("shorten.shorten_batch.error_request", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	items := make([]models.ShortenBatchItemResponse, len(request))
+	links := make([]entities.Link, 0, len(request))
+	indexes := make([]int, 0, len(request)) // the index of each link within the request
+
+	for index, itemRequest := range request {
+		items[index].Index = index
+
+		link, ok := toLink(itemRequest)
+		if !ok {
+			items[index].Status = fiber.StatusBadRequest
+			items[index].Error = s.i18n.Translate("shorten.shorten_url.invalid_expiration", language)
+			continue
+		}
+		link.OwnerID = principal.Subject
+
+		domain, err := s.urls.NormalizeDomain(link.Domain)
+		if err != nil {
+			items[index].Status = fiber.StatusBadRequest
+			items[index].Error = s.i18n.Translate("shorten.shorten_url.invalid_domain", language)
+			continue
+		}
+		link.Domain = domain
+
+		links = append(links, link)
+		indexes = append(indexes, index)
+	}
+
+	results, err := s.urls.ShortenBatch(c.Context(), links)
+	if err != nil {
+		if errors.Is(err, urls.ErrBatchTooLarge) {
+			response.Message = s.i18n.Translate("shorten.shorten_batch.too_large", language)
+			return response.Write(c, fiber.StatusRequestEntityTooLarge)
+		}
+
+		s.logger.Error("error shortening the batch", zap.Error(err))
+		response.Message = s.i18n.Translate("shorten.shorten_batch.error", language)
+		return response.Write(c, fiber.StatusInternalServerError)
+	}
+
+	for resultIndex, result := range results {
+		item := &items[indexes[resultIndex]]
+		if result.Err != nil {
+			status, key := shortenError(result.Err)
+			if status == fiber.StatusInternalServerError {
+				s.logger.Error("error shortening a link of the batch", zap.Int("index", item.Index), zap.Error(result.Err))
+			}
+			item.Status, item.Error = status, s.i18n.Translate(key, language)
+			continue
+		}
+		domain := links[resultIndex].Domain
+		item.Status, item.ID, item.Domain, item.ShortURL = fiber.StatusCreated, result.ID, domain, s.urls.ShortURL(domain, result.ID)
+	}
+
+	status := fiber.StatusCreated
+	for _, item := range items {
+		if item.Status != fiber.StatusCreated {
+			status = fiber.StatusMultiStatus // the partial failures are reported per item
+			break
+		}
+	}
+
+	response.Request = items
+	response.Message = s.i18n.Translate("shorten.shorten_batch.success", language)
+	return response.Write(c, status)
+}
+
+// toLink converts the request into a link, false is returned when the expiration is given ambiguously
+func toLink(request models.ShortenRequest) (entities.Link, bool) {
+	link := entities.Link{
+		ID:        request.Alias,
+		Domain:    request.Domain,
+		URL:       entities.URL(request.URL),
+		ExpiresAt: request.ExpiresAt,
+		Redirect:  entities.Redirect(request.Redirect),
+
+		Deduplicate: request.Deduplicate,
+		Password:    request.Password,
+
+		Campaign: request.Campaign,
+		Title:    request.Title,
+		Tags:     request.Tags,
+		Metadata: request.Metadata,
+	}
+
+	if request.TTL != 0 {
+		if request.ExpiresAt != nil || request.TTL < 0 {
+			return entities.Link{}, false
+		}
+		expiresAt := time.Now().Add(time.Duration(request.TTL) * time.Second)
+		link.ExpiresAt = &expiresAt
+	}
+
+	return link, true
+}
+
+// shortenError returns the http status and the translation key of the shortening error
+func shortenError(err error) (int, string) {
+	switch {
+	case errors.Is(err, entities.ErrURLEmpty):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_url.empty"
+	case errors.Is(err, entities.ErrURLTooLong):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_url.too_long"
+	case errors.Is(err, entities.ErrURLMalformed):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_url.malformed"
+	case errors.Is(err, entities.ErrURLSchemeNotAllowed):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_url.scheme_not_allowed"
+	case errors.Is(err, entities.ErrURLHostInvalid):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_url.invalid_host"
+	case errors.Is(err, urls.ErrDestinationSelf):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_url.self"
+	case errors.Is(err, urls.ErrDestinationBlocked):
+		return fiber.StatusForbidden, "shorten.shorten_url.blocked_destination"
+	case errors.Is(err, urls.ErrRedirectInvalid):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_redirect"
+	case errors.Is(err, urls.ErrExpirationInvalid):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_expiration"
+	case errors.Is(err, urls.ErrAliasInvalid):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_alias"
+	case errors.Is(err, urls.ErrAliasReserved):
+		return fiber.StatusBadRequest, "shorten.shorten_url.reserved_alias"
+	case errors.Is(err, urls.ErrAliasAlreadyExists):
+		return fiber.StatusConflict, "shorten.shorten_url.alias_exists"
+	case errors.Is(err, urls.ErrPasswordInvalid):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_password"
+	case errors.Is(err, urls.ErrDomainInvalid):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_domain"
+	case errors.Is(err, urls.ErrDomainNotExists):
+		return fiber.StatusBadRequest, "shorten.shorten_url.domain_not_exists"
+	case errors.Is(err, urls.ErrDomainNotOwner):
+		return fiber.StatusForbidden, "shorten.shorten_url.domain_not_owner"
+	case errors.Is(err, urls.ErrTagInvalid):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_attributes.tag"
+	case errors.Is(err, urls.ErrTooManyTags):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_attributes.too_many_tags"
+	case errors.Is(err, urls.ErrCampaignTooLong):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_attributes.campaign_too_long"
+	case errors.Is(err, urls.ErrTitleTooLong):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_attributes.title_too_long"
+	case errors.Is(err, urls.ErrMetadataInvalid):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_attributes.metadata"
+	case errors.Is(err, urls.ErrMetadataTooLarge):
+		return fiber.StatusBadRequest, "shorten.shorten_url.invalid_attributes.metadata_too_large"
+	default:
+		return fiber.StatusInternalServerError, "shorten.shorten_url.error_shorten"
+	}
+}
+
+func (s *shorten) listURLs(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	filter, ok := toListFilter(c)
+	if !ok {
+		response.Message = s.i18n.Translate("shorten.list_urls.invalid_filter", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+	filter.OwnerID = principal.Subject
+
+	page, err := s.urls.List(c.Context(), filter)
+	if err != nil {
+		if errors.Is(err, urls.ErrListFilterInvalid) || errors.Is(err, urls.ErrTagInvalid) {
+			response.Message = s.i18n.Translate("shorten.list_urls.invalid_filter", language)
+			return response.Write(c, fiber.StatusBadRequest)
+		}
+
+		if errors.Is(err, urls.ErrCursorInvalid) {
+			response.Message = s.i18n.Translate("shorten.list_urls.invalid_cursor", language)
+			return response.Write(c, fiber.StatusBadRequest)
+		}
+
+		s.logger.Error("error listing the urls", zap.Error(err))
+		response.Message = s.i18n.Translate("shorten.list_urls.error", language)
+		return response.Write(c, fiber.StatusInternalServerError)
+	}
+
+	response.Request = toListURLsResponse(page)
+	response.Message = s.i18n.Translate("shorten.list_urls.success", language)
+	return response.Write(c, fiber.StatusOK)
+}
+
+func toListURLsResponse(page urls.ListPage) models.ListURLsResponse {
+	now := time.Now()
+	links := make([]models.ListedURLResponse, 0, len(page.Links))
+	for _, link := range page.Links {
+		links = append(links, models.ListedURLResponse{
+			ID:        link.ID,
+			Domain:    link.Domain,
+			URL:       link.URL,
+			ExpiresAt: link.ExpiresAt,
+			Redirect:  int(link.Redirect),
+			Status:    link.Status(now),
+			CreatedAt: link.CreatedAt,
+		})
+	}
+
+	return models.ListURLsResponse{Links: links, NextCursor: page.NextCursor}
+}
+
+// toListFilter parses the query parameters of listing the links, the dates are formatted as RFC 3339
+func toListFilter(c fiber.Ctx) (filter urls.ListFilter, ok bool) {
+	filter.Destination = c.Query("q")
+	filter.Tag = c.Query("tag")
+	filter.Cursor = c.Query("cursor")
+
+	if filter.Sort, ok = urls.ToListSort(c.Query("sort")); !ok {
+		return urls.ListFilter{}, false
+	}
+
+	if rawStatus := c.Query("status"); len(rawStatus) != 0 {
+		if filter.Status, ok = entities.ToLinkStatus(rawStatus); !ok {
+			return urls.ListFilter{}, false
+		}
+	}
+
+	if rawLimit := c.Query("limit"); len(rawLimit) != 0 {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit <= 0 {
+			return urls.ListFilter{}, false
+		}
+		filter.Limit = limit
+	}
+
+	for parameter, target := range map[string]**time.Time{"from": &filter.CreatedAfter, "to": &filter.CreatedBefore} {
+		if rawTime := c.Query(parameter); len(rawTime) != 0 {
+			parsed, err := time.Parse(time.RFC3339, rawTime)
+			if err != nil {
+				return urls.ListFilter{}, false
+			}
+			*target = &parsed
+		}
+	}
+
+	return filter, true
+}
+
 func (s *shorten) retrieveURL(c fiber.Ctx) error {
 	response := &models.Response{}
 	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
 
 	id := c.Params("id")
 	if len(id) == 0 {
@@ -62,20 +359,229 @@ func (s *shorten) retrieveURL(c fiber.Ctx) error {
 		return response.Write(c, fiber.StatusBadRequest)
 	}
 
-	url, err := s.urls.Retrieve(c.Context(), id)
+	domain, err := s.urls.NormalizeDomain(c.Query("domain"))
 	if err != nil {
-		if errors.Is(err, urls.ErrIDNotExists) {
-			s.logger.Error("error id not exists", zap.String("id", id))
-			response.Message = s.i18n.Translate("shorten.retrieve_url.not_exists", language)
-			return response.Write(c, fiber.StatusNotFound)
-		}
+		response.Message = s.i18n.Translate("shorten.retrieve_url.not_exists", language)
+		return response.Write(c, fiber.StatusNotFound)
+	}
 
-		s.logger.Error("error retreiving the url", zap.Error(err))
-		response.Message = s.i18n.Translate("shorten.retrieve_url.error", language)
-		return response.Write(c, fiber.StatusInternalServerError)
+	link, err := s.urls.Retrieve(c.Context(), domain, id)
+	if err != nil {
+		return s.writeRetrieveError(c, response, language, id, err)
+	}
+
+	owned := principal.Has(entities.ScopeAdmin) || (len(link.OwnerID) != 0 && link.OwnerID == principal.Subject)
+	if link.Protected() && !owned {
+		// the destination of the protected links is only given to the ones knowing the password
+		response.Message = s.i18n.Translate("shorten.retrieve_url.protected", language)
+		return response.Write(c, fiber.StatusForbidden)
 	}
 
-	response.Request = models.RetrieveURLResponse{URL: url}
+	retrieved := models.RetrieveURLResponse{URL: link.URL, Protected: link.Protected()}
+	if owned {
+		retrieved.Campaign, retrieved.Title = link.Campaign, link.Title
+		retrieved.Tags, retrieved.Metadata = link.Tags, link.Metadata
+	}
+
+	response.Request = retrieved
 	response.Message = s.i18n.Translate("shorten.retrieve_url.success", language)
 	return response.Write(c, fiber.StatusOK)
 }
+
+// writeRetrieveError writes the error of retrieving a link
+func (s *shorten) writeRetrieveError(c fiber.Ctx, response *models.Response, language entities.Language, id string, err error) error {
+	if errors.Is(err, urls.ErrShortenIDNotExists) {
+		s.logger.Error("error id not exists", zap.String("id", id))
+		response.Message = s.i18n.Translate("shorten.retrieve_url.not_exists", language)
+		return response.Write(c, fiber.StatusNotFound)
+	}
+
+	if errors.Is(err, urls.ErrShortenIDExpired) {
+		response.Message = s.i18n.Translate("shorten.retrieve_url.expired", language)
+		return response.Write(c, fiber.StatusGone)
+	}
+
+	if errors.Is(err, urls.ErrShortenIDDisabled) {
+		response.Message = s.i18n.Translate("shorten.retrieve_url.disabled", language)
+		return response.Write(c, fiber.StatusGone)
+	}
+
+	if errors.Is(err, urls.ErrDestinationBlocked) {
+		response.Message = s.i18n.Translate("shorten.retrieve_url.blocked_destination", language)
+		return response.Write(c, fiber.StatusForbidden)
+	}
+
+	s.logger.Error("error retreiving the url", zap.Error(err))
+	response.Message = s.i18n.Translate("shorten.retrieve_url.error", language)
+	return response.Write(c, fiber.StatusInternalServerError)
+}
+
+func (s *shorten) stats(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	id := c.Params("id")
+	if len(id) == 0 {
+		response.Message = s.i18n.Translate("shorten.stats.id_not_given", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	domain, err := s.authorize(c, principal, id)
+	if err != nil {
+		return s.writeManageError(c, response, language, err)
+	}
+
+	stats, err := s.analytics.Stats(c.Context(), domain, id)
+	if err != nil {
+		s.logger.Error("error retreiving the stats", zap.Error(err))
+		response.Message = s.i18n.Translate("shorten.stats.error", language)
+		return response.Write(c, fiber.StatusInternalServerError)
+	}
+
+	days := make([]models.DailyStatsResponse, 0, len(stats.Days))
+	for _, day := range stats.Days {
+		days = append(days, models.DailyStatsResponse{Day: day.Day.Format(time.DateOnly), Clicks: day.Clicks})
+	}
+
+	response.Request = models.StatsResponse{Total: stats.Total, Days: days}
+	response.Message = s.i18n.Translate("shorten.stats.success", language)
+	return response.Write(c, fiber.StatusOK)
+}
+
+func (s *shorten) updateURL(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	id := c.Params("id")
+	if len(id) == 0 {
+		response.Message = s.i18n.Translate("shorten.manage_url.id_not_given", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	domain, err := s.authorize(c, principal, id)
+	if err != nil {
+		return s.writeManageError(c, response, language, err)
+	}
+
+	request := models.UpdateRequest{}
+	if err := c.Bind().Body(&request); err != nil {
+		response.Message = s.i18n.Translate("shorten.manage_url.error_request", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	link, ok := toLink(models.ShortenRequest{
+		URL:       request.URL,
+		ExpiresAt: request.ExpiresAt,
+		TTL:       request.TTL,
+		Redirect:  request.Redirect,
+	})
+	if !ok {
+		response.Message = s.i18n.Translate("shorten.shorten_url.invalid_expiration", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+	link.ID, link.Domain = id, domain
+
+	if err := s.urls.Update(c.Context(), link); err != nil {
+		return s.writeManageError(c, response, language, err)
+	}
+
+	response.Message = s.i18n.Translate("shorten.manage_url.updated", language)
+	return response.Write(c, fiber.StatusOK)
+}
+
+func (s *shorten) patchURL(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	id := c.Params("id")
+	if len(id) == 0 {
+		response.Message = s.i18n.Translate("shorten.manage_url.id_not_given", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	domain, err := s.authorize(c, principal, id)
+	if err != nil {
+		return s.writeManageError(c, response, language, err)
+	}
+
+	request := models.PatchRequest{}
+	if err := c.Bind().Body(&request); err != nil || request.Disabled == nil {
+		response.Message = s.i18n.Translate("shorten.manage_url.error_request", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	change, message := s.urls.Enable, "shorten.manage_url.enabled"
+	if *request.Disabled {
+		change, message = s.urls.Disable, "shorten.manage_url.disabled"
+	}
+
+	if err := change(c.Context(), domain, id); err != nil {
+		return s.writeManageError(c, response, language, err)
+	}
+
+	response.Message = s.i18n.Translate(message, language)
+	return response.Write(c, fiber.StatusOK)
+}
+
+func (s *shorten) deleteURL(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	id := c.Params("id")
+	if len(id) == 0 {
+		response.Message = s.i18n.Translate("shorten.manage_url.id_not_given", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	domain, err := s.authorize(c, principal, id)
+	if err != nil {
+		return s.writeManageError(c, response, language, err)
+	}
+
+	if err := s.urls.Delete(c.Context(), domain, id); err != nil {
+		return s.writeManageError(c, response, language, err)
+	}
+
+	response.Message = s.i18n.Translate("shorten.manage_url.deleted", language)
+	return response.Write(c, fiber.StatusOK)
+}
+
+// authorize checks the ownership of the link on the domain given by the query, then returns the (normalized) domain.
+// The admins manage the links of all owners.
+func (s *shorten) authorize(c fiber.Ctx, principal entities.Principal, id string) (string, error) {
+	domain, err := s.urls.NormalizeDomain(c.Query("domain"))
+	if err != nil {
+		return "", urls.ErrShortenIDNotExists // the links of the invalid domains never exist
+	}
+
+	if principal.Has(entities.ScopeAdmin) {
+		return domain, nil
+	}
+	return domain, s.urls.Authorize(c.Context(), domain, id, principal.Subject)
+}
+
+// writeManageError writes the error of changing a link, the validation errors are the same as shortening
+func (s *shorten) writeManageError(c fiber.Ctx, response *models.Response, language entities.Language, err error) error {
+	if errors.Is(err, urls.ErrShortenIDNotExists) {
+		response.Message = s.i18n.Translate("shorten.manage_url.not_exists", language)
+		return response.Write(c, fiber.StatusNotFound)
+	}
+
+	if errors.Is(err, urls.ErrNotOwner) {
+		response.Message = s.i18n.Translate("shorten.manage_url.not_owner", language)
+		return response.Write(c, fiber.StatusForbidden)
+	}
+
+	status, key := shortenError(err)
+	if status == fiber.StatusInternalServerError {
+		s.logger.Error("error changing the url", zap.Error(err))
+		key = "shorten.manage_url.error"
+	}
+
+	response.Message = s.i18n.Translate(key, language)
+	return response.Write(c, status)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/api/http/i18n"
+	"github.com/mohammadne/fesghel/internal/api/http/middlewares"
+	"github.com/mohammadne/fesghel/internal/api/http/models"
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/urls"
+)
+
+func NewTags(r fiber.Router, logger *zap.Logger, i18n i18n.I18N, urls urls.Service) {
+	handler := &tags{
+		logger: logger,
+		i18n:   i18n,
+		urls:   urls,
+	}
+
+	read := middlewares.RequireScope(i18n, entities.ScopeLinksRead)
+
+	g := r.Group("tags")
+	g.Get("/:tag/links", read, handler.links)
+}
+
+type tags struct {
+	logger *zap.Logger
+	i18n   i18n.I18N
+	urls   urls.Service
+}
+
+// links lists the caller's links carrying the tag, it's paginated and filtered the same as listing the links
+func (t *tags) links(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+	principal, _ := c.Locals("principal").(entities.Principal)
+
+	tag, err := urls.NormalizeTag(c.Params("tag"))
+	if err != nil {
+		response.Message = t.i18n.Translate("tags.links.invalid_tag", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+
+	filter, ok := toListFilter(c)
+	if !ok {
+		response.Message = t.i18n.Translate("tags.links.invalid_filter", language)
+		return response.Write(c, fiber.StatusBadRequest)
+	}
+	filter.OwnerID, filter.Tag = principal.Subject, tag
+
+	page, err := t.urls.List(c.Context(), filter)
+	if err != nil {
+		if errors.Is(err, urls.ErrListFilterInvalid) {
+			response.Message = t.i18n.Translate("tags.links.invalid_filter", language)
+			return response.Write(c, fiber.StatusBadRequest)
+		}
+
+		if errors.Is(err, urls.ErrCursorInvalid) {
+			response.Message = t.i18n.Translate("tags.links.invalid_cursor", language)
+			return response.Write(c, fiber.StatusBadRequest)
+		}
+
+		t.logger.Error("error listing the links of the tag", zap.Error(err))
+		response.Message = t.i18n.Translate("tags.links.error", language)
+		return response.Write(c, fiber.StatusInternalServerError)
+	}
+
+	response.Request = toListURLsResponse(page)
+	response.Message = t.i18n.Translate("tags.links.success", language)
+	return response.Write(c, fiber.StatusOK)
+}
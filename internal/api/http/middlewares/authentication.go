@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/api/http/i18n"
+	"github.com/mohammadne/fesghel/internal/api/http/models"
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/tenants"
+	"github.com/mohammadne/fesghel/pkg/jwt"
+)
+
+// HeaderAPIKey is the header carrying the api key of the tenant
+const HeaderAPIKey = "X-API-Key"
+
+// maxSubjectLength is the length of the owner_id column, since the subject owns the shortened links
+const maxSubjectLength = 255
+
+// tokenSubjectPrefix namespaces the subjects of the tokens, the tenant ids can't contain its colon so they never collide
+const tokenSubjectPrefix = "jwt:"
+
+// apiKeyScopes are granted to the tenants authenticated via their api keys
+var apiKeyScopes = []entities.Scope{entities.ScopeLinksRead, entities.ScopeLinksWrite}
+
+// NewAuthentication authenticates the requests either via the api keys or the bearer tokens (when the verifier
+// is given), then attaches the principal to the context. It should be used after the language middleware.
+func NewAuthentication(router fiber.Router, logger *zap.Logger, i18n i18n.I18N, tenants tenants.Service, verifier *jwt.Verifier) {
+	middleware := &authentication{
+		logger:   logger,
+		i18n:     i18n,
+		tenants:  tenants,
+		verifier: verifier,
+	}
+
+	router.Use(middleware.authenticate)
+}
+
+type authentication struct {
+	logger   *zap.Logger
+	i18n     i18n.I18N
+	tenants  tenants.Service
+	verifier *jwt.Verifier // nil when the bearer tokens are not accepted
+}
+
+func (a *authentication) authenticate(c fiber.Ctx) error {
+	response := &models.Response{}
+	language, _ := c.Locals("language").(entities.Language)
+
+	var principal entities.Principal
+	var key string
+
+	if token, ok := bearerToken(c.Get(fiber.HeaderAuthorization)); ok && a.verifier != nil {
+		principal, key = a.fromToken(c, token)
+	} else if apiKey := c.Get(HeaderAPIKey); len(apiKey) != 0 {
+		principal, key = a.fromAPIKey(c, apiKey)
+	} else {
+		key = "authentication.credentials_not_given"
+	}
+
+	if len(key) != 0 {
+		status := fiber.StatusUnauthorized
+		if key == "authentication.error" {
+			status = fiber.StatusInternalServerError
+		}
+		response.Message = a.i18n.Translate(key, language)
+		return response.Write(c, status)
+	}
+
+	c.Locals("principal", principal)
+	return c.Next()
+}
+
+// fromAPIKey returns the principal of the tenant, otherwise the translation key of the failure
+func (a *authentication) fromAPIKey(c fiber.Ctx, apiKey string) (entities.Principal, string) {
+	tenant, err := a.tenants.Authenticate(c.Context(), apiKey)
+	if err != nil {
+		if errors.Is(err, tenants.ErrAPIKeyInvalid) {
+			return entities.Principal{}, "authentication.invalid_key"
+		}
+
+		a.logger.Error("error authenticating the api key", zap.Error(err))
+		return entities.Principal{}, "authentication.error"
+	}
+
+	return entities.Principal{Subject: tenant.ID, Scopes: apiKeyScopes}, ""
+}
+
+// fromToken returns the principal of the token's claims, otherwise the translation key of the failure
+func (a *authentication) fromToken(c fiber.Ctx, token string) (entities.Principal, string) {
+	claims, err := a.verifier.Verify(c.Context(), token)
+	if err != nil {
+		if errors.Is(err, jwt.ErrLoadingKeySet) {
+			a.logger.Error("error loading the key set", zap.Error(err))
+		}
+		return entities.Principal{}, "authentication.invalid_token"
+	}
+
+	if len(claims.Subject) == 0 {
+		return entities.Principal{}, "authentication.invalid_token"
+	}
+
+	// the subjects are only unique within their issuer
+	subject := tokenSubjectPrefix + claims.Issuer + "|" + claims.Subject
+	if len(subject) > maxSubjectLength {
+		return entities.Principal{}, "authentication.invalid_token"
+	}
+
+	return entities.Principal{Subject: subject, Scopes: entities.ToScopes(claims.Scopes())}, ""
+}
+
+func bearerToken(authorization string) (string, bool) {
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return strings.Clone(token), len(token) != 0
+}
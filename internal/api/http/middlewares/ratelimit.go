@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/api/http/i18n"
+	"github.com/mohammadne/fesghel/internal/api/http/models"
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/ratelimit"
+)
+
+// the rate limit headers of the IETF draft (draft-ietf-httpapi-ratelimit-headers)
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+// RateLimit returns a route handler counting the requests against the budget, the requests are keyed by
+// the principal when they're authenticated and by the client ip otherwise. The requests are let through
+// when the limiter fails, so an unavailable redis doesn't take the endpoints down.
+func RateLimit(logger *zap.Logger, i18n i18n.I18N, limiter ratelimit.Service, budget ratelimit.Budget) fiber.Handler {
+	return RateLimitCost(logger, i18n, limiter, budget, nil)
+}
+
+// RateLimitCost is the same as RateLimit, but each request costs as many units as the cost function returns
+// (e.g. the number of the links of a batch). A nil cost function counts each request as a single unit.
+func RateLimitCost(logger *zap.Logger, i18n i18n.I18N, limiter ratelimit.Service, budget ratelimit.Budget,
+	cost func(c fiber.Ctx) int) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		subject := "ip:" + c.IP()
+		if principal, ok := c.Locals("principal").(entities.Principal); ok && len(principal.Subject) != 0 {
+			subject = "principal:" + principal.Subject
+		}
+
+		units := 1
+		if cost != nil {
+			units = cost(c)
+		}
+
+		result, err := limiter.Allow(c.Context(), budget, subject, units)
+		if err != nil {
+			logger.Error("error rate limiting the request", zap.String("budget", string(budget)), zap.Error(err))
+			return c.Next()
+		}
+
+		if result.Limit == 0 { // unlimited
+			return c.Next()
+		}
+
+		reset := strconv.Itoa(seconds(result.Reset))
+		c.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+		c.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+		c.Set(HeaderRateLimitReset, reset)
+
+		if result.Allowed {
+			return c.Next()
+		}
+
+		c.Set(fiber.HeaderRetryAfter, reset)
+
+		language, ok := c.Locals("language").(entities.Language)
+		if !ok {
+			language = entities.ToLanguage(c.Get("language"))
+		}
+		response := &models.Response{Message: i18n.Translate("rate_limit.exceeded", language)}
+		return response.Write(c, fiber.StatusTooManyRequests)
+	}
+}
+
+// seconds rounds the duration up to the whole seconds, since the headers can't carry the fractions
+func seconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
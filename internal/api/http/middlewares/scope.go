@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/mohammadne/fesghel/internal/api/http/i18n"
+	"github.com/mohammadne/fesghel/internal/api/http/models"
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+// RequireScope returns a route handler rejecting the principals not having the scope,
+// it should be registered before the route's own handler.
+func RequireScope(i18n i18n.I18N, scope entities.Scope) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		principal, _ := c.Locals("principal").(entities.Principal)
+		if principal.Has(scope) {
+			return c.Next()
+		}
+
+		language, _ := c.Locals("language").(entities.Language)
+		response := &models.Response{Message: i18n.Translate("authorization.insufficient_scope", language)}
+		return response.Write(c, fiber.StatusForbidden)
+	}
+}
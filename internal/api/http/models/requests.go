@@ -1,5 +1,59 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL   string `json:"url"`
+	Alias string `json:"alias,omitempty"`
+
+	// the host of a registered domain the link is served on, the default domain is used when not given
+	Domain string `json:"domain,omitempty"`
+
+	// the expiration can be given either as an absolute time or as a ttl in seconds
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       int64      `json:"ttl,omitempty"`
+
+	// one of 301, 302, 307 or 308, the globally configured one is used when not given
+	Redirect int `json:"redirect,omitempty"`
+
+	// returns the existing id when the url has already been shortened
+	Deduplicate bool `json:"dedupe,omitempty"`
+
+	// the link only redirects after the password is given, the protected links are never deduplicated
+	Password string `json:"password,omitempty"`
+
+	// the descriptive attributes, the metadata should be a json object
+	Campaign string          `json:"campaign,omitempty"`
+	Title    string          `json:"title,omitempty"`
+	Tags     []string        `json:"tags,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// ShortenBatchRequest is an array of links to be shortened at once
+type ShortenBatchRequest []ShortenRequest
+
+// UpdateRequest replaces the attributes of an existing link, the omitted ones are cleared
+type UpdateRequest struct {
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       int64      `json:"ttl,omitempty"`
+	Redirect  int        `json:"redirect,omitempty"`
+}
+
+// PatchRequest disables or enables an existing link
+type PatchRequest struct {
+	Disabled *bool `json:"disabled"`
+}
+
+// DomainRequest registers a branded domain, or replaces the fallback url of an existing one
+type DomainRequest struct {
+	Host string `json:"host,omitempty"`
+	// OwnerID is the owner the domain is registered for (e.g. the tenant id), only used on registering
+	OwnerID string `json:"owner_id,omitempty"`
+
+	// the unknown ids of the domain are redirected to it, they're not found when it's not given
+	FallbackURL string `json:"fallback_url,omitempty"`
 }
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/gofiber/fiber/v3"
+
 	"github.com/mohammadne/fesghel/internal/entities"
 )
 
@@ -17,9 +21,60 @@ func (response *Response) Write(ctx fiber.Ctx, statusCode int) error {
 }
 
 type ShortenURLResponse struct {
-	ID string `json:"id"`
+	ID       string `json:"id"`
+	Domain   string `json:"domain,omitempty"`
+	ShortURL string `json:"short_url"`
+}
+
+// ShortenBatchItemResponse is the result of shortening a link of the batch,
+// either the id or the (localized) error is given.
+type ShortenBatchItemResponse struct {
+	Index    int    `json:"index"`
+	Status   int    `json:"status"`
+	ID       string `json:"id,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	ShortURL string `json:"short_url,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
+// RetrieveURLResponse is the link, its attributes are only given to its owner
 type RetrieveURLResponse struct {
-	URL entities.URL `json:"url"`
+	URL       entities.URL    `json:"url"`
+	Protected bool            `json:"protected,omitempty"`
+	Campaign  string          `json:"campaign,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	Tags      []string        `json:"tags,omitempty"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+}
+
+// ListURLsResponse is a page of the links, the next page is retrieved by giving the next cursor
+type ListURLsResponse struct {
+	Links      []ListedURLResponse `json:"links"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type ListedURLResponse struct {
+	ID        string              `json:"id"`
+	Domain    string              `json:"domain,omitempty"`
+	URL       entities.URL        `json:"url"`
+	ExpiresAt *time.Time          `json:"expires_at,omitempty"`
+	Redirect  int                 `json:"redirect,omitempty"`
+	Status    entities.LinkStatus `json:"status"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+type StatsResponse struct {
+	Total int64                `json:"total"`
+	Days  []DailyStatsResponse `json:"days"`
+}
+
+type DailyStatsResponse struct {
+	Day    string `json:"day"` // formatted as 2006-01-02
+	Clicks int64  `json:"clicks"`
+}
+
+type DomainResponse struct {
+	Host        string       `json:"host"`
+	FallbackURL entities.URL `json:"fallback_url,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
 }
@@ -11,10 +11,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	"github.com/mohammadne/fesghel/internal/analytics"
 	"github.com/mohammadne/fesghel/internal/api/http/handlers"
 	"github.com/mohammadne/fesghel/internal/api/http/i18n"
 	"github.com/mohammadne/fesghel/internal/api/http/middlewares"
+	"github.com/mohammadne/fesghel/internal/entities"
+	"github.com/mohammadne/fesghel/internal/ratelimit"
+	"github.com/mohammadne/fesghel/internal/tenants"
 	"github.com/mohammadne/fesghel/internal/urls"
+	"github.com/mohammadne/fesghel/pkg/jwt"
 )
 
 type Server struct {
@@ -24,9 +29,15 @@ type Server struct {
 	requestApp *fiber.App
 }
 
-func New(log *zap.Logger, urls urls.Service) *Server {
+func New(cfg *Config, log *zap.Logger, urls urls.Service, analytics analytics.Service,
+	tenants tenants.Service, limiter ratelimit.Service) *Server {
 	server := &Server{logger: log}
 
+	redirect, ok := entities.ToRedirect(cfg.RedirectStatus)
+	if !ok {
+		log.Fatal("invalid redirect status", zap.Int("status", cfg.RedirectStatus))
+	}
+
 	{ // monitoring handlers
 		server.monitorApp = fiber.New(fiber.Config{})
 
@@ -34,6 +45,14 @@ func New(log *zap.Logger, urls urls.Service) *Server {
 		handlers.NewHealthz(server.monitorApp, log)
 	}
 
+	var verifier *jwt.Verifier
+	if cfg.JWT.Enabled() {
+		var err error
+		if verifier, err = jwt.NewVerifier(cfg.JWT); err != nil {
+			log.Fatal("failed to load the json web key set", zap.Error(err))
+		}
+	}
+
 	i18n, err := i18n.New(log)
 	if err != nil {
 		log.Fatal("failed to load i18n", zap.Error(err))
@@ -44,10 +63,12 @@ func New(log *zap.Logger, urls urls.Service) *Server {
 
 		apiGroup := server.requestApp.Group("api/v1")
 		middlewares.NewLanguage(apiGroup, log)
-		handlers.NewShorten(apiGroup, log, i18n, urls)
-
-		handlers.NewRoute(server.requestApp, log, urls)
+		middlewares.NewAuthentication(apiGroup, log, i18n, tenants, verifier)
+		handlers.NewShorten(apiGroup, log, i18n, urls, analytics, limiter)
+		handlers.NewTags(apiGroup, log, i18n, urls)
+		handlers.NewDomains(apiGroup, log, i18n, urls)
 
+		handlers.NewRoute(server.requestApp, log, i18n, redirect, cfg.RedirectMaxAge, cfg.CountryHeader, urls, analytics, limiter)
 	}
 
 	return server
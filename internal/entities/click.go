@@ -0,0 +1,24 @@
+package entities
+
+import "time"
+
+// Click is a single successful redirect of a shortened link
+type Click struct {
+	ID        string // the shortened id of the link
+	Domain    string // the domain of the link, empty for the default one
+	Timestamp time.Time
+	Referrer  string
+	UserAgent string
+	Country   string // ISO 3166-1 alpha-2 code, empty when unknown
+}
+
+// Stats is the aggregated clicks of a shortened link
+type Stats struct {
+	Total int64
+	Days  []DailyClicks
+}
+
+type DailyClicks struct {
+	Day    time.Time
+	Clicks int64
+}
@@ -0,0 +1,11 @@
+package entities
+
+import "time"
+
+// Domain is a branded short domain, its links are served when the requests are sent to its host
+type Domain struct {
+	Host        string // normalized (lowercased and in its ascii form) with the port when it's not the default one
+	OwnerID     string // the tenant which has registered the domain
+	FallbackURL URL    // optional, the unknown ids of the domain are redirected to it
+	CreatedAt   time.Time
+}
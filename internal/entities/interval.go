@@ -0,0 +1,31 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrIntervalInvalid = errors.New("error interval should be a positive duration (e.g. 10s)")
+
+// Interval is the period of a background job, it's validated on loading the config since the tickers panic
+// on the non-positive durations.
+type Interval time.Duration
+
+// Decode implements envconfig.Decoder
+func (i *Interval) Decode(value string) error {
+	duration, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return errors.Join(ErrIntervalInvalid, err)
+	}
+	if duration <= 0 {
+		return ErrIntervalInvalid
+	}
+
+	*i = Interval(duration)
+	return nil
+}
+
+func (i Interval) Duration() time.Duration {
+	return time.Duration(i)
+}
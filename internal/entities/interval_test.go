@@ -0,0 +1,21 @@
+package entities
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIntervalDecode(t *testing.T) {
+	var interval Interval
+	if err := interval.Decode(" 10s "); err != nil || interval.Duration() != 10*time.Second {
+		t.Errorf("expect the interval to be decoded as 10s, got %v and %v", interval.Duration(), err)
+	}
+
+	for _, value := range []string{"", "0", "0s", "-1m", "ten seconds"} {
+		var interval Interval
+		if err := interval.Decode(value); !errors.Is(err, ErrIntervalInvalid) {
+			t.Errorf("expect ErrIntervalInvalid error for %q: %v", value, err)
+		}
+	}
+}
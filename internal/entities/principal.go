@@ -0,0 +1,36 @@
+package entities
+
+import "slices"
+
+// Scope is a permission granted to the principal
+type Scope string
+
+const (
+	ScopeLinksRead  Scope = "links:read"
+	ScopeLinksWrite Scope = "links:write"
+	// ScopeAdmin grants all of the scopes and manages the links of all owners
+	ScopeAdmin Scope = "admin"
+)
+
+// Principal is the authenticated caller, its subject owns the links it shortens
+type Principal struct {
+	Subject string
+	Scopes  []Scope
+}
+
+// Has reports whether the principal has been granted the scope
+func (p Principal) Has(scope Scope) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// ToScopes returns the known scopes of the raw ones, the unknown scopes are ignored
+func ToScopes(rawScopes []string) []Scope {
+	scopes := make([]Scope, 0, len(rawScopes))
+	for _, rawScope := range rawScopes {
+		switch scope := Scope(rawScope); scope {
+		case ScopeLinksRead, ScopeLinksWrite, ScopeAdmin:
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,30 @@
+package entities
+
+import "net/http"
+
+// Redirect is the http status code used while redirecting to a link
+type Redirect int
+
+const (
+	RedirectMovedPermanently  Redirect = http.StatusMovedPermanently
+	RedirectFound             Redirect = http.StatusFound
+	RedirectTemporaryRedirect Redirect = http.StatusTemporaryRedirect
+	RedirectPermanentRedirect Redirect = http.StatusPermanentRedirect
+
+	// RedirectDefault means the link follows the globally configured redirect
+	RedirectDefault Redirect = 0
+)
+
+func ToRedirect(rawRedirect int) (Redirect, bool) {
+	switch redirect := Redirect(rawRedirect); redirect {
+	case RedirectMovedPermanently, RedirectFound, RedirectTemporaryRedirect, RedirectPermanentRedirect:
+		return redirect, true
+	default:
+		return RedirectDefault, false
+	}
+}
+
+// Permanent reports whether clients are allowed to cache the redirect
+func (r Redirect) Permanent() bool {
+	return r == RedirectMovedPermanently || r == RedirectPermanentRedirect
+}
@@ -0,0 +1,7 @@
+package entities
+
+// Tenant owns the links, each of the requests is authenticated as a tenant via its api keys
+type Tenant struct {
+	ID   string
+	Name string
+}
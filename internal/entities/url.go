@@ -1,3 +1,186 @@
 package entities
 
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+// URL is the destination of a link, see Normalize for the rules it should satisfy
 type URL string
+
+// URLPolicy is the set of rules a url should satisfy before being shortened
+type URLPolicy struct {
+	Schemes       []string // the allowed schemes (lowercased), e.g. http and https
+	MaxLength     int      // the maximum length of the normalized url, unlimited when it's zero
+	StripFragment bool     // removes the fragment (the part after #) of the url
+}
+
+var (
+	ErrURLEmpty            = errors.New("error url is empty")
+	ErrURLTooLong          = errors.New("error url exceeds the maximum length")
+	ErrURLMalformed        = errors.New("error url is malformed")
+	ErrURLSchemeNotAllowed = errors.New("error url scheme is not allowed")
+	ErrURLHostInvalid      = errors.New("error url host is invalid")
+)
+
+// defaultPorts are stripped from the urls since they are implied by the scheme
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// maxHostLength is the maximum length of a domain name (in its ascii form)
+const maxHostLength = 253
+
+// Normalize validates the url against the policy and returns its normalized form:
+// the scheme and host are lowercased, international hosts are converted to punycode
+// and the default ports (and fragment when asked for) are stripped.
+func (u URL) Normalize(policy URLPolicy) (URL, error) {
+	rawURL := strings.TrimSpace(string(u))
+	if len(rawURL) == 0 {
+		return "", ErrURLEmpty
+	}
+
+	if policy.MaxLength > 0 && len(rawURL) > policy.MaxLength {
+		return "", ErrURLTooLong
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Opaque != "" {
+		return "", ErrURLMalformed
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if !slices.Contains(policy.Schemes, parsed.Scheme) {
+		return "", ErrURLSchemeNotAllowed
+	}
+
+	host, err := normalizeHost(parsed.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	port := parsed.Port()
+	if port == "" || port == defaultPorts[parsed.Scheme] {
+		port = ""
+	} else if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return "", ErrURLHostInvalid
+	}
+
+	if port != "" {
+		parsed.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		parsed.Host = "[" + host + "]" // IPv6
+	} else {
+		parsed.Host = host
+	}
+
+	if policy.StripFragment {
+		parsed.Fragment, parsed.RawFragment = "", ""
+	}
+
+	normalized := parsed.String()
+	if policy.MaxLength > 0 && len(normalized) > policy.MaxLength {
+		return "", ErrURLTooLong
+	}
+
+	return URL(normalized), nil
+}
+
+// normalizeHost lowercases the host and converts it to its ascii (punycode) form
+func normalizeHost(host string) (string, error) {
+	if len(host) == 0 {
+		return "", ErrURLHostInvalid
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil || len(ascii) > maxHostLength {
+		return "", ErrURLHostInvalid
+	}
+
+	for _, label := range strings.Split(ascii, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return "", ErrURLHostInvalid
+		}
+	}
+
+	return ascii, nil
+}
+
+// Link is a url alongside the attributes it has been shortened with
+type Link struct {
+	ID        string // optional, the custom alias chosen by the caller
+	Domain    string // the host of the branded domain, empty for the default one (the base address)
+	URL       URL
+	ExpiresAt *time.Time // optional, the link lives forever when it's nil
+	Redirect  Redirect   // optional, the global redirect is used when it's RedirectDefault
+	Disabled  bool       // the disabled links don't redirect until they're enabled again
+	OwnerID   string     // the tenant which has shortened the link, empty for the links having no owner
+	CreatedAt time.Time  // set once the link is stored
+
+	// the attributes describing the link, they don't change how it redirects
+	Campaign string
+	Title    string
+	Tags     []string        // normalized (lowercased, unique and sorted)
+	Metadata json.RawMessage // an arbitrary json object, nil when not given
+
+	// Deduplicate asks for the existing id of an already shortened url, only used while shortening
+	Deduplicate bool
+
+	// Password protects the link, it's only given in plain while shortening and stored as PasswordHash
+	Password     string
+	PasswordHash string // the bcrypt hash of the password, empty for the links which are not protected
+}
+
+// Protected reports whether the link only redirects after its password is given
+func (l *Link) Protected() bool {
+	return len(l.PasswordHash) != 0
+}
+
+// Expired reports whether the link has been expired at the given time
+func (l *Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// LinkStatus is the state of a link, derived from its expiration and whether it's disabled
+type LinkStatus string
+
+const (
+	LinkStatusActive   LinkStatus = "active"
+	LinkStatusDisabled LinkStatus = "disabled"
+	LinkStatusExpired  LinkStatus = "expired"
+)
+
+func ToLinkStatus(rawStatus string) (LinkStatus, bool) {
+	switch status := LinkStatus(rawStatus); status {
+	case LinkStatusActive, LinkStatusDisabled, LinkStatusExpired:
+		return status, true
+	default:
+		return "", false
+	}
+}
+
+// Status returns the state of the link at the given time, the expiration takes precedence over disabling
+func (l *Link) Status(now time.Time) LinkStatus {
+	switch {
+	case l.Expired(now):
+		return LinkStatusExpired
+	case l.Disabled:
+		return LinkStatusDisabled
+	default:
+		return LinkStatusActive
+	}
+}
@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestURLNormalize(t *testing.T) {
+	policy := URLPolicy{Schemes: []string{"http", "https"}, MaxLength: 64}
+
+	t.Run("normalized", func(t *testing.T) {
+		cases := map[string]string{
+			"https://example.com/path":            "https://example.com/path",
+			"  HTTPS://Example.COM/Path?q=A  ":    "https://example.com/Path?q=A",
+			"http://example.com:80/":              "http://example.com/",
+			"https://example.com:443/":            "https://example.com/",
+			"https://example.com:8443/":           "https://example.com:8443/",
+			"https://bücher.example/":             "https://xn--bcher-kva.example/",
+			"https://example.com/page#section":    "https://example.com/page#section",
+			"http://[2001:DB8::1]:8080/":          "http://[2001:db8::1]:8080/",
+			"http://[2001:db8::1]:80/":            "http://[2001:db8::1]/",
+			"https://user@example.com./?a=1&b=2":  "https://user@example.com/?a=1&b=2",
+			"https://192.168.1.1/admin":           "https://192.168.1.1/admin",
+			"https://sub-domain.example.co.uk/x/": "https://sub-domain.example.co.uk/x/",
+		}
+
+		for rawURL, expected := range cases {
+			normalized, err := URL(rawURL).Normalize(policy)
+			if err != nil {
+				t.Errorf("expect no errors for %q, %v", rawURL, err)
+				continue
+			}
+
+			if normalized != URL(expected) {
+				t.Errorf("expect %q to be normalized into %q, got %q", rawURL, expected, normalized)
+			}
+		}
+	})
+
+	t.Run("strip fragment", func(t *testing.T) {
+		policy := URLPolicy{Schemes: []string{"https"}, StripFragment: true}
+
+		normalized, err := URL("https://example.com/page#section").Normalize(policy)
+		if err != nil || normalized != "https://example.com/page" {
+			t.Errorf("expect the fragment to be stripped, got %q (%v)", normalized, err)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		cases := map[string]error{
+			"":    ErrURLEmpty,
+			"   ": ErrURLEmpty,
+			"https://example.com/" + strings.Repeat("a", 64): ErrURLTooLong,
+			"https://exa mple.com":                           ErrURLMalformed,
+			"mailto:someone@example.com":                     ErrURLMalformed,
+			"ftp://example.com/file":                         ErrURLSchemeNotAllowed,
+			"javascript://alert(1)":                          ErrURLSchemeNotAllowed,
+			"example.com/path":                               ErrURLSchemeNotAllowed,
+			"https://":                                       ErrURLHostInvalid,
+			"https:///path":                                  ErrURLHostInvalid,
+			"https://exa_mple.com":                           ErrURLHostInvalid,
+			"https://example..com":                           ErrURLHostInvalid,
+			"https://-example.com":                           ErrURLHostInvalid,
+			"https://example.com:99999":                      ErrURLHostInvalid,
+		}
+
+		for rawURL, expected := range cases {
+			_, err := URL(rawURL).Normalize(policy)
+			if !errors.Is(err, expected) {
+				t.Errorf("expect %v error for %q, got %v", expected, rawURL, err)
+			}
+		}
+	})
+}
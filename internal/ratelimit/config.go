@@ -0,0 +1,24 @@
+package ratelimit
+
+import (
+	"time"
+
+	redis_pkg "github.com/mohammadne/fesghel/pkg/databases/redis"
+)
+
+type Config struct {
+	// Enabled limits the shorten and redirect requests, the password attempts are limited regardless of it
+	Enabled  bool              `default:"false"`
+	Redis    *redis_pkg.Config `required:"true"`
+	Shorten  *Limit            `required:"true"`
+	Redirect *Limit            `required:"true"`
+	// Password limits the attempts of unlocking the protected links, it should be much lower than the others.
+	// It's always enforced (and can't be unlimited), the protected links could be brute forced otherwise.
+	Password *Limit `required:"true"`
+}
+
+// Limit is the number of requests allowed within the sliding window, a zero limit is unlimited
+type Limit struct {
+	Requests int           `default:"60"`
+	Window   time.Duration `default:"1m"`
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	redis_pkg "github.com/mohammadne/fesghel/pkg/databases/redis"
+)
+
+var (
+	miniredisInstance *miniredis.Miniredis
+	redisInstance     Redis
+)
+
+func TestMain(m *testing.M) {
+	var err error
+
+	miniredisInstance, err = miniredis.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not start miniredis: %v\n", err)
+		os.Exit(1) // Exit with a non-zero status code
+	}
+	defer miniredisInstance.Close()
+
+	cfg := redis_pkg.Config{Address: miniredisInstance.Addr(), Timeout: time.Second * 2}
+	redisInstance, err = NewRedis(&cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not open redis: %v\n", err)
+		os.Exit(1) // Exit with a non-zero status code
+	}
+
+	m.Run()
+}
+
+func newServiceInstance(limits map[Budget]Limit) (*service, *mockRedis) {
+	redisMock := new(mockRedis)
+	return &service{config: &Config{}, logger: zap.NewNop(), metrics: newMetricsNoop(), redis: redisMock, limits: limits}, redisMock
+}
+
+type mockRedis struct{ mock.Mock }
+
+func (m *mockRedis) allow(ctx context.Context, key string, limit Limit, cost int, now time.Time) (result Result, err error) {
+	args := m.Called(ctx, key, limit, cost, now)
+	return args.Get(0).(Result), args.Error(1)
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	redis_pkg "github.com/mohammadne/fesghel/pkg/databases/redis"
+)
+
+type Redis interface {
+	allow(ctx context.Context, key string, limit Limit, cost int, now time.Time) (result Result, err error)
+}
+
+type redis struct {
+	instance *redis_pkg.Redis
+}
+
+func NewRedis(cfg *redis_pkg.Config) (Redis, error) {
+	instance, err := redis_pkg.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &redis{instance: instance}, nil
+}
+
+var errAllowingOnRedis = errors.New("error allowing the request on redis")
+
+// scriptAllow is a sliding window log, the sorted set holds a member per allowed unit scored by its time.
+// KEYS: window, ARGV: now (ms), window (ms), limit, the member of the request and its cost (at most the limit).
+// It returns whether the request is allowed, the remaining units and the time until the cost of the request
+// (or a single unit once it's allowed) is available again (ms).
+var scriptAllow = redis_pkg.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local cost = tonumber(ARGV[5])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+local leaving = 0
+if count + cost <= limit then
+	for index = 1, cost do
+		redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. index)
+	end
+	redis.call('PEXPIRE', KEYS[1], window)
+	count = count + cost
+	allowed = 1
+else
+	leaving = count + cost - limit - 1
+end
+
+local oldest = redis.call('ZRANGE', KEYS[1], leaving, leaving, 'WITHSCORES')
+local reset = window
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}`)
+
+func (r *redis) allow(ctx context.Context, key string, limit Limit, cost int, now time.Time) (Result, error) {
+	// the member is randomized, so the requests of the same millisecond are all counted
+	member := fmt.Sprintf("%d-%d", now.UnixMilli(), rand.Uint32())
+
+	values, err := scriptAllow.Run(ctx, r.instance, []string{key},
+		now.UnixMilli(), limit.Window.Milliseconds(), limit.Requests, member, cost).Int64Slice()
+	if err != nil {
+		return Result{}, errors.Join(errAllowingOnRedis, err)
+	}
+	if len(values) != 3 {
+		return Result{}, fmt.Errorf("%w: unexpected result %v", errAllowingOnRedis, values)
+	}
+
+	return Result{
+		Allowed:   values[0] == 1,
+		Limit:     limit.Requests,
+		Remaining: int(values[1]),
+		Reset:     time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRedisAllow(t *testing.T) {
+	var (
+		limit = Limit{Requests: 2, Window: time.Minute}
+		now   = time.Now()
+	)
+
+	t.Run("rejects the requests exceeding the limit", func(t *testing.T) {
+		key := "ratelimit:test:exceeding"
+
+		for index, remaining := range []int{1, 0} {
+			result, err := redisInstance.allow(context.TODO(), key, limit, 1, now.Add(time.Duration(index)*time.Second))
+			if err != nil || !result.Allowed || result.Remaining != remaining {
+				t.Errorf("expect request %d to be allowed with %d remaining, got %+v and %v", index, remaining, result, err)
+			}
+		}
+
+		result, err := redisInstance.allow(context.TODO(), key, limit, 1, now.Add(2*time.Second))
+		if err != nil || result.Allowed {
+			t.Errorf("expect the request to be rejected, got %+v and %v", result, err)
+		}
+
+		// the first request leaves the window after 58 seconds
+		if result.Reset != 58*time.Second {
+			t.Errorf("expect the reset to be 58s, got %v", result.Reset)
+		}
+	})
+
+	t.Run("allows again when the window slides", func(t *testing.T) {
+		key := "ratelimit:test:sliding"
+
+		for index := range 2 {
+			if _, err := redisInstance.allow(context.TODO(), key, limit, 1, now.Add(time.Duration(index)*time.Second)); err != nil {
+				t.Errorf("expect no errors %v", err)
+			}
+		}
+
+		result, err := redisInstance.allow(context.TODO(), key, limit, 1, now.Add(time.Minute+time.Millisecond))
+		if err != nil || !result.Allowed || result.Remaining != 0 {
+			t.Errorf("expect the request to be allowed with 0 remaining, got %+v and %v", result, err)
+		}
+	})
+
+	t.Run("counts the cost of the request", func(t *testing.T) {
+		var (
+			key   = "ratelimit:test:cost"
+			limit = Limit{Requests: 10, Window: time.Minute}
+		)
+
+		result, err := redisInstance.allow(context.TODO(), key, limit, 6, now)
+		if err != nil || !result.Allowed || result.Remaining != 4 {
+			t.Errorf("expect the request to be allowed with 4 remaining, got %+v and %v", result, err)
+		}
+
+		result, err = redisInstance.allow(context.TODO(), key, limit, 3, now.Add(time.Second))
+		if err != nil || !result.Allowed || result.Remaining != 1 {
+			t.Errorf("expect the request to be allowed with 1 remaining, got %+v and %v", result, err)
+		}
+
+		// the 5 units are available once the first request leaves the window
+		result, err = redisInstance.allow(context.TODO(), key, limit, 5, now.Add(2*time.Second))
+		if err != nil || result.Allowed || result.Remaining != 1 || result.Reset != 58*time.Second {
+			t.Errorf("expect the request to be rejected until the first request leaves, got %+v and %v", result, err)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+type Service interface {
+	// Allow counts the request of the subject (e.g. the principal or the client ip) against the budget, the request
+	// costs as many units as the items it carries (e.g. the links of a batch) and at least one.
+	Allow(ctx context.Context, budget Budget, subject string, cost int) (Result, error)
+}
+
+// Budget names the endpoints sharing a limit, each budget is counted separately
+type Budget string
+
+const (
+	BudgetShorten  Budget = "shorten"
+	BudgetRedirect Budget = "redirect"
+	// BudgetPassword counts the password attempts of the protected links, per client and link
+	BudgetPassword Budget = "password"
+)
+
+// Result is the state of the subject's window after the request, the Limit is zero when it's unlimited
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration // until a request is available again
+}
+
+type service struct {
+	config  *Config
+	logger  *zap.Logger
+	metrics *metrics
+	redis   Redis
+	limits  map[Budget]Limit
+}
+
+func NewService(cfg *Config, l *zap.Logger) (Service, error) {
+	metrics, err := newMetrics()
+	if err != nil {
+		l.Panic("error registering ratelimit metrics", zap.Error(err))
+	}
+
+	svc := &service{config: cfg, logger: l, metrics: metrics, limits: cfg.limits()}
+
+	if password, ok := svc.limits[BudgetPassword]; !ok || password.Requests <= 0 || password.Window <= 0 {
+		l.Panic("the password limit should be positive, the protected links could be brute forced otherwise")
+	}
+
+	if cfg.Redis == nil {
+		l.Panic("the redis config is required by the rate limiting")
+	}
+
+	svc.redis, err = NewRedis(cfg.Redis)
+	if err != nil {
+		l.Panic("error initializing Redis instance", zap.Error(err))
+	}
+
+	return svc, nil
+}
+
+// limits returns the limits of the budgets, only the password budget is limited when the rate limiting is disabled
+func (cfg *Config) limits() map[Budget]Limit {
+	limits := make(map[Budget]Limit, 3)
+	if cfg.Shorten != nil && cfg.Enabled {
+		limits[BudgetShorten] = *cfg.Shorten
+	}
+	if cfg.Redirect != nil && cfg.Enabled {
+		limits[BudgetRedirect] = *cfg.Redirect
+	}
+	if cfg.Password != nil {
+		limits[BudgetPassword] = *cfg.Password
+	}
+	return limits
+}
+
+const keyPrefix = "ratelimit:"
+
+var ErrRateLimiting = errors.New("error rate limiting the request")
+
+func (s *service) Allow(ctx context.Context, budget Budget, subject string, cost int) (result Result, err error) {
+	limit, ok := s.limits[budget]
+	if !ok || limit.Requests <= 0 || limit.Window <= 0 {
+		return Result{Allowed: true}, nil
+	}
+
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "allow")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("allow", status)
+	}(time.Now())
+
+	// a request costing more than the whole budget consumes all of it, so it's allowed once the window is empty
+	cost = min(max(cost, 1), limit.Requests)
+
+	result, err = s.redis.allow(ctx, keyPrefix+string(budget)+":"+subject, limit, cost, time.Now())
+	if err != nil {
+		return Result{}, errors.Join(ErrRateLimiting, err)
+	}
+
+	if !result.Allowed {
+		s.metrics.Rejections.IncrementVector(string(budget))
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAllow(t *testing.T) {
+	var (
+		limit = Limit{Requests: 10, Window: time.Minute}
+	)
+
+	t.Run("unlimited budget", func(t *testing.T) {
+		serviceInstance, redisMock := newServiceInstance(map[Budget]Limit{BudgetShorten: limit})
+
+		result, err := serviceInstance.Allow(context.TODO(), BudgetRedirect, "ip:127.0.0.1", 1)
+		if err != nil || !result.Allowed || result.Limit != 0 {
+			t.Errorf("expect the unlimited request to be allowed, got %+v and %v", result, err)
+		}
+
+		redisMock.AssertNotCalled(t, "allow", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("counted on the budget of the subject", func(t *testing.T) {
+		serviceInstance, redisMock := newServiceInstance(map[Budget]Limit{BudgetShorten: limit})
+		redisMock.On("allow", mock.Anything, "ratelimit:shorten:acme", limit, 1, mock.Anything).
+			Return(Result{Allowed: false, Limit: 10, Reset: time.Second}, nil).Once()
+
+		result, err := serviceInstance.Allow(context.TODO(), BudgetShorten, "acme", 1)
+		if err != nil || result.Allowed {
+			t.Errorf("expect the request to be rejected, got %+v and %v", result, err)
+		}
+
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("cost is bounded by the limit", func(t *testing.T) {
+		serviceInstance, redisMock := newServiceInstance(map[Budget]Limit{BudgetShorten: limit})
+		redisMock.On("allow", mock.Anything, "ratelimit:shorten:acme", limit, 10, mock.Anything).
+			Return(Result{Allowed: true, Limit: 10}, nil).Once()
+
+		if _, err := serviceInstance.Allow(context.TODO(), BudgetShorten, "acme", 1000); err != nil {
+			t.Errorf("expect no errors %v", err)
+		}
+
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("redis failure", func(t *testing.T) {
+		serviceInstance, redisMock := newServiceInstance(map[Budget]Limit{BudgetShorten: limit})
+		redisMock.On("allow", mock.Anything, mock.Anything, limit, 1, mock.Anything).
+			Return(Result{}, errors.New("connection refused")).Once()
+
+		_, err := serviceInstance.Allow(context.TODO(), BudgetShorten, "acme", 1)
+		if !errors.Is(err, ErrRateLimiting) {
+			t.Errorf("expect ErrRateLimiting error %v", err)
+		}
+	})
+}
+
+func TestConfigLimits(t *testing.T) {
+	limit := &Limit{Requests: 10, Window: time.Minute}
+	cfg := &Config{Enabled: false, Shorten: limit, Redirect: limit, Password: limit}
+
+	limits := cfg.limits()
+	if _, ok := limits[BudgetPassword]; !ok || len(limits) != 1 {
+		t.Errorf("expect only the password attempts to be limited when disabled, got %v", limits)
+	}
+
+	cfg.Enabled = true
+	if limits := cfg.limits(); len(limits) != 3 {
+		t.Errorf("expect all of the budgets to be limited when enabled, got %v", limits)
+	}
+}
@@ -0,0 +1,9 @@
+package tenants
+
+import (
+	postgres_pkg "github.com/mohammadne/fesghel/pkg/databases/postgres"
+)
+
+type Config struct {
+	Postgres *postgres_pkg.Config `required:"true"`
+}
@@ -0,0 +1,67 @@
+package tenants
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	postgres_pkg "github.com/mohammadne/fesghel/pkg/databases/postgres"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+var (
+	mockDatabase     sqlmock.Sqlmock
+	postgresInstance Postgres
+)
+
+func TestMain(m *testing.M) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not start sqlmock: %v\n", err)
+		os.Exit(1) // Exit with a non-zero status code
+	}
+	defer sqlDB.Close()
+	mockDatabase = mock
+	sqlxDB := sqlx.NewDb(sqlDB, "sqlmock")
+
+	vectors := postgres_pkg.Vectors{
+		Counter:   metrics_pkg.RegisterCounterNoop(),
+		Histogram: metrics_pkg.RegisterHistogramNoop(),
+	}
+
+	postgresInstance = &postgres{
+		instance: &postgres_pkg.Postgres{DB: sqlxDB, Vectors: &vectors},
+	}
+
+	m.Run()
+}
+
+func newServiceInstance() (*service, *mockPostgres) {
+	postgresMock := new(mockPostgres)
+	return &service{config: &Config{}, logger: zap.NewNop(), metrics: newMetricsNoop(), postgres: postgresMock}, postgresMock
+}
+
+type mockPostgres struct{ mock.Mock }
+
+func (m *mockPostgres) insertTenant(ctx context.Context, tenant entities.Tenant, timestamp time.Time) (err error) {
+	args := m.Called(ctx, tenant, timestamp)
+	return args.Error(0)
+}
+
+func (m *mockPostgres) insertKey(ctx context.Context, keyHash, tenantID, name string, timestamp time.Time) (err error) {
+	args := m.Called(ctx, keyHash, tenantID, name, timestamp)
+	return args.Error(0)
+}
+
+func (m *mockPostgres) retrieveByKey(ctx context.Context, keyHash string) (tenant entities.Tenant, err error) {
+	args := m.Called(ctx, keyHash)
+	return args.Get(0).(entities.Tenant), args.Error(1)
+}
@@ -0,0 +1,124 @@
+package tenants
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	postgres_pkg "github.com/mohammadne/fesghel/pkg/databases/postgres"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+type Postgres interface {
+	insertTenant(ctx context.Context, tenant entities.Tenant, timestamp time.Time) (err error)
+	insertKey(ctx context.Context, keyHash, tenantID, name string, timestamp time.Time) (err error)
+	retrieveByKey(ctx context.Context, keyHash string) (tenant entities.Tenant, err error)
+}
+
+type postgres struct {
+	instance *postgres_pkg.Postgres
+}
+
+func NewPostgres(cfg *postgres_pkg.Config) (Postgres, error) {
+	instance, err := postgres_pkg.Open(cfg, entities.Namespace, entities.System)
+	if err != nil {
+		return nil, err
+	}
+	return &postgres{instance: instance}, nil
+}
+
+var (
+	errTenantExists    = errors.New("error tenant already exists")
+	errTenantNotExists = errors.New("error tenant not exists")
+	errInsertingTenant = errors.New("error inserting tenant")
+	errInsertingKey    = errors.New("error inserting api key")
+	errKeyNotExists    = errors.New("error api key not exists")
+	errRetrievingByKey = errors.New("error retrieving tenant by api key")
+)
+
+const (
+	queryInsertTenant = `
+	INSERT INTO tenants (id, name, created_at)
+	VALUES ($1, $2, $3)`
+)
+
+func (s *postgres) insertTenant(ctx context.Context, tenant entities.Tenant, timestamp time.Time) (err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("tenants", "insert", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("tenants", "insert", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "tenants", "insert")
+	}(time.Now())
+
+	if _, err = s.instance.ExecContext(ctx, queryInsertTenant, tenant.ID, tenant.Name, timestamp); err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return errTenantExists
+		}
+		return errors.Join(errInsertingTenant, err)
+	}
+
+	return nil
+}
+
+const (
+	queryInsertKey = `
+	INSERT INTO api_keys (key_hash, tenant_id, name, created_at)
+	VALUES ($1, $2, $3, $4)`
+)
+
+// insertKey stores the hash of the api key, the key itself is never stored
+func (s *postgres) insertKey(ctx context.Context, keyHash, tenantID, name string, timestamp time.Time) (err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("api_keys", "insert", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("api_keys", "insert", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "api_keys", "insert")
+	}(time.Now())
+
+	if _, err = s.instance.ExecContext(ctx, queryInsertKey, keyHash, tenantID, name, timestamp); err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return errTenantNotExists
+		}
+		return errors.Join(errInsertingKey, err)
+	}
+
+	return nil
+}
+
+const (
+	queryRetrieveByKey = `
+	SELECT tenants.id, tenants.name
+	FROM api_keys
+	JOIN tenants ON tenants.id = api_keys.tenant_id
+	WHERE api_keys.key_hash = $1 AND api_keys.revoked_at IS NULL`
+)
+
+// retrieveByKey returns the tenant owning the (non-revoked) api key
+func (s *postgres) retrieveByKey(ctx context.Context, keyHash string) (tenant entities.Tenant, err error) {
+	defer func(start time.Time) {
+		if err != nil && !errors.Is(err, errKeyNotExists) {
+			s.instance.Vectors.Counter.IncrementVector("api_keys", "retrieve", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("api_keys", "retrieve", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "api_keys", "retrieve")
+	}(time.Now())
+
+	err = s.instance.QueryRowContext(ctx, queryRetrieveByKey, keyHash).Scan(&tenant.ID, &tenant.Name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entities.Tenant{}, errKeyNotExists
+		}
+		return entities.Tenant{}, errors.Join(errRetrievingByKey, err)
+	}
+
+	return tenant, nil
+}
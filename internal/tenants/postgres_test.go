@@ -0,0 +1,52 @@
+package tenants
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPostgresRetrieveByKey(t *testing.T) {
+	var (
+		sampleHash = hashAPIKey(apiKeyPrefix + "sample")
+	)
+
+	t.Run("with empty result", func(t *testing.T) {
+		mockDatabase.
+			ExpectQuery(regexp.QuoteMeta(queryRetrieveByKey)).
+			WithArgs(sampleHash).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+		_, err := postgresInstance.retrieveByKey(context.TODO(), sampleHash)
+		if !errors.Is(err, errKeyNotExists) {
+			t.Errorf("expect errKeyNotExists error %v", err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("with valid non-empty result", func(t *testing.T) {
+		mockDatabase.
+			ExpectQuery(regexp.QuoteMeta(queryRetrieveByKey)).
+			WithArgs(sampleHash).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow("acme", "Acme"))
+
+		tenant, err := postgresInstance.retrieveByKey(context.TODO(), sampleHash)
+		if err != nil {
+			t.Errorf("expect no errors %v", err)
+		}
+
+		if tenant.ID != "acme" || tenant.Name != "Acme" {
+			t.Errorf("invalid tenant has been returned %v", tenant)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
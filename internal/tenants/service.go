@@ -0,0 +1,160 @@
+package tenants
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+type Service interface {
+	// Authenticate returns the tenant owning the api key
+	Authenticate(ctx context.Context, key string) (entities.Tenant, error)
+
+	// CreateTenant stores the tenant, its id is generated when it's not given
+	CreateTenant(ctx context.Context, tenant entities.Tenant) (entities.Tenant, error)
+
+	// IssueKey generates a new api key for the tenant, the key is only returned once (just its hash is stored)
+	IssueKey(ctx context.Context, tenantID, name string) (string, error)
+}
+
+type service struct {
+	config   *Config
+	logger   *zap.Logger
+	metrics  *metrics
+	postgres Postgres
+}
+
+func NewService(cfg *Config, l *zap.Logger) (Service, error) {
+	metrics, err := newMetrics()
+	if err != nil {
+		l.Panic("error registering tenants metrics", zap.Error(err))
+	}
+
+	postgres, err := NewPostgres(cfg.Postgres)
+	if err != nil {
+		l.Panic("error loading Postgres instance", zap.Error(err))
+	}
+
+	return &service{config: cfg, logger: l, metrics: metrics, postgres: postgres}, nil
+}
+
+var (
+	ErrAPIKeyInvalid       = errors.New("error api key is invalid")
+	ErrAuthenticating      = errors.New("error authenticating api key")
+	ErrTenantIDInvalid     = errors.New("error tenant id should be 1 to 64 characters of 0-9, a-z, A-Z, - and _")
+	ErrTenantAlreadyExists = errors.New("error tenant already exists")
+	ErrTenantNotExists     = errors.New("error tenant not exists")
+	ErrStoringTenant       = errors.New("error storing tenant")
+)
+
+const (
+	// apiKeyPrefix makes the api keys recognizable (e.g. by the secret scanners)
+	apiKeyPrefix = "fsg_"
+	apiKeyBytes  = 32
+
+	tenantIDBytes     = 12
+	tenantIDMaxLength = 64
+)
+
+func (s *service) Authenticate(ctx context.Context, key string) (tenant entities.Tenant, err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "authenticate")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("authenticate", status)
+	}(time.Now())
+
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return entities.Tenant{}, ErrAPIKeyInvalid
+	}
+
+	tenant, err = s.postgres.retrieveByKey(ctx, hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, errKeyNotExists) {
+			return entities.Tenant{}, ErrAPIKeyInvalid
+		}
+		return entities.Tenant{}, errors.Join(ErrAuthenticating, err)
+	}
+
+	return tenant, nil
+}
+
+func (s *service) CreateTenant(ctx context.Context, tenant entities.Tenant) (entities.Tenant, error) {
+	if len(tenant.ID) == 0 {
+		id, err := randomString(tenantIDBytes)
+		if err != nil {
+			return entities.Tenant{}, errors.Join(ErrStoringTenant, err)
+		}
+		tenant.ID = id
+	} else if !validTenantID(tenant.ID) {
+		return entities.Tenant{}, ErrTenantIDInvalid
+	}
+
+	if err := s.postgres.insertTenant(ctx, tenant, time.Now()); err != nil {
+		if errors.Is(err, errTenantExists) {
+			return entities.Tenant{}, ErrTenantAlreadyExists
+		}
+		return entities.Tenant{}, errors.Join(ErrStoringTenant, err)
+	}
+
+	return tenant, nil
+}
+
+func (s *service) IssueKey(ctx context.Context, tenantID, name string) (string, error) {
+	random, err := randomString(apiKeyBytes)
+	if err != nil {
+		return "", errors.Join(ErrStoringTenant, err)
+	}
+	key := apiKeyPrefix + random
+
+	if err := s.postgres.insertKey(ctx, hashAPIKey(key), tenantID, name, time.Now()); err != nil {
+		if errors.Is(err, errTenantNotExists) {
+			return "", ErrTenantNotExists
+		}
+		return "", errors.Join(ErrStoringTenant, err)
+	}
+
+	return key, nil
+}
+
+// hashAPIKey returns the hex encoded sha256 of the api key, the keys are random enough to need no salt
+func hashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// randomString returns the url-safe encoding of n random bytes
+func randomString(n int) (string, error) {
+	random := make([]byte, n)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func validTenantID(id string) bool {
+	if len(id) == 0 || len(id) > tenantIDMaxLength {
+		return false
+	}
+
+	for _, char := range id {
+		switch {
+		case char >= '0' && char <= '9', char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char == '-', char == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package tenants
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestServiceIssueAndAuthenticate(t *testing.T) {
+	svc, postgresMock := newServiceInstance()
+	tenant := entities.Tenant{ID: "acme", Name: "Acme"}
+
+	var storedHash string
+	postgresMock.
+		On("insertKey", mock.Anything, mock.Anything, tenant.ID, "ci", mock.Anything).
+		Run(func(args mock.Arguments) { storedHash = args.String(1) }).
+		Return(nil).Once()
+
+	key, err := svc.IssueKey(context.TODO(), tenant.ID, "ci")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, apiKeyPrefix))
+	assert.Equal(t, hashAPIKey(key), storedHash, "only the hash of the key should be stored")
+	assert.NotContains(t, storedHash, key)
+
+	postgresMock.
+		On("retrieveByKey", mock.Anything, storedHash).
+		Return(tenant, nil).Once()
+
+	authenticated, err := svc.Authenticate(context.TODO(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, tenant, authenticated)
+	postgresMock.AssertExpectations(t)
+}
+
+func TestServiceAuthenticate(t *testing.T) {
+	t.Run("without prefix", func(t *testing.T) {
+		svc, postgresMock := newServiceInstance()
+
+		_, err := svc.Authenticate(context.TODO(), "random-key")
+		assert.ErrorIs(t, err, ErrAPIKeyInvalid)
+		postgresMock.AssertNotCalled(t, "retrieveByKey", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		svc, postgresMock := newServiceInstance()
+
+		postgresMock.
+			On("retrieveByKey", mock.Anything, hashAPIKey(apiKeyPrefix+"unknown")).
+			Return(entities.Tenant{}, errKeyNotExists).Once()
+
+		_, err := svc.Authenticate(context.TODO(), apiKeyPrefix+"unknown")
+		assert.ErrorIs(t, err, ErrAPIKeyInvalid)
+		postgresMock.AssertExpectations(t)
+	})
+}
+
+func TestServiceCreateTenant(t *testing.T) {
+	t.Run("generated id", func(t *testing.T) {
+		svc, postgresMock := newServiceInstance()
+
+		postgresMock.
+			On("insertTenant", mock.Anything, mock.Anything, mock.Anything).
+			Return(nil).Once()
+
+		tenant, err := svc.CreateTenant(context.TODO(), entities.Tenant{Name: "Acme"})
+		assert.NoError(t, err)
+		assert.True(t, validTenantID(tenant.ID))
+		postgresMock.AssertExpectations(t)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		svc, _ := newServiceInstance()
+
+		_, err := svc.CreateTenant(context.TODO(), entities.Tenant{ID: "acme corp"})
+		assert.ErrorIs(t, err, ErrTenantIDInvalid)
+	})
+
+	t.Run("already exists", func(t *testing.T) {
+		svc, postgresMock := newServiceInstance()
+
+		postgresMock.
+			On("insertTenant", mock.Anything, entities.Tenant{ID: "acme"}, mock.Anything).
+			Return(errTenantExists).Once()
+
+		_, err := svc.CreateTenant(context.TODO(), entities.Tenant{ID: "acme"})
+		assert.ErrorIs(t, err, ErrTenantAlreadyExists)
+	})
+}
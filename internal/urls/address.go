@@ -0,0 +1,97 @@
+package urls
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+var (
+	ErrBaseAddressInvalid = errors.New("error base address should be a host (with optional port and path prefix) without a scheme")
+	ErrSchemeInvalid      = errors.New("error scheme should be either http or https")
+	ErrDestinationSelf    = errors.New("error destination points at the short urls, it would redirect in a loop")
+)
+
+// BaseAddress is where the short urls are served, a host with optional port and path prefix (e.g. fesghel.com/go).
+// It's validated and normalized while the config is loaded.
+type BaseAddress struct {
+	Host       string // normalized (lowercased and in its ascii form) with the port when it's not the default one
+	PathPrefix string // either empty or starting with a slash, without the trailing one
+}
+
+// Decode implements envconfig.Decoder
+func (a *BaseAddress) Decode(value string) error {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 || strings.Contains(value, "://") || strings.ContainsAny(value, "?#@") {
+		return ErrBaseAddressInvalid
+	}
+
+	// the host is normalized the same as the destinations, so they are compared as-is
+	normalized, err := entities.URL("https://" + value).Normalize(entities.URLPolicy{Schemes: []string{"https"}})
+	if err != nil {
+		return errors.Join(ErrBaseAddressInvalid, err)
+	}
+
+	parsed, err := url.Parse(string(normalized))
+	if err != nil {
+		return errors.Join(ErrBaseAddressInvalid, err)
+	}
+
+	a.Host = parsed.Host
+	a.PathPrefix = strings.TrimRight(parsed.EscapedPath(), "/")
+	return nil
+}
+
+func (a BaseAddress) String() string {
+	return a.Host + a.PathPrefix
+}
+
+// Scheme is the scheme of the short urls, either http or https
+type Scheme string
+
+// Decode implements envconfig.Decoder
+func (s *Scheme) Decode(value string) error {
+	switch scheme := Scheme(strings.ToLower(strings.TrimSpace(value))); scheme {
+	case "http", "https":
+		*s = scheme
+		return nil
+	default:
+		return ErrSchemeInvalid
+	}
+}
+
+// ShortURL returns the short url of the id, the links of the branded domains are served on their root
+func (s *service) ShortURL(domain, id string) string {
+	scheme := s.config.Scheme
+	if len(scheme) == 0 {
+		scheme = "https"
+	}
+
+	if len(domain) != 0 {
+		return string(scheme) + "://" + domain + "/" + id
+	}
+	return string(scheme) + "://" + s.config.BaseAddress.String() + "/" + id
+}
+
+// pointsAtSelf reports whether the (normalized) destination is one of the short urls, of any domain.
+// The domains are only registered by the admins, so the tenants can't claim the others' destinations as ours.
+func (s *service) pointsAtSelf(destination entities.URL) bool {
+	parsed, err := url.Parse(string(destination))
+	if err != nil {
+		return false
+	}
+
+	if _, ok := s.domains.get(strings.ToLower(parsed.Host)); ok {
+		return true
+	}
+
+	if !strings.EqualFold(parsed.Host, s.config.BaseAddress.Host) {
+		return false
+	}
+
+	prefix := s.config.BaseAddress.PathPrefix
+	path := parsed.EscapedPath()
+	return len(prefix) == 0 || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
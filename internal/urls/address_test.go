@@ -0,0 +1,65 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestBaseAddressDecode(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		for value, expected := range map[string]BaseAddress{
+			"fesghel.com":          {Host: "fesghel.com"},
+			"Fesghel.COM:443/go/":  {Host: "fesghel.com", PathPrefix: "/go"},
+			"localhost:8002":       {Host: "localhost:8002"},
+			"bücher.example/links": {Host: "xn--bcher-kva.example", PathPrefix: "/links"},
+		} {
+			var address BaseAddress
+			if err := address.Decode(value); err != nil || address != expected {
+				t.Errorf("expect %q to be decoded as %+v, got %+v and %v", value, expected, address, err)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, value := range []string{"", "https://fesghel.com", "fesghel.com/?q=1", "user@fesghel.com", "fesghel..com"} {
+			var address BaseAddress
+			if err := address.Decode(value); !errors.Is(err, ErrBaseAddressInvalid) {
+				t.Errorf("expect ErrBaseAddressInvalid error for %q: %v", value, err)
+			}
+		}
+	})
+}
+
+func TestShortURL(t *testing.T) {
+	initializeServiceInstance()
+	serviceInstance.config.Scheme, serviceInstance.config.BaseAddress = "https", BaseAddress{Host: "fesghel.com", PathPrefix: "/go"}
+	defer func() { serviceInstance.config.Scheme, serviceInstance.config.BaseAddress = "", BaseAddress{} }()
+
+	t.Run("built from the base address", func(t *testing.T) {
+		if shortURL := serviceInstance.ShortURL("", "abcdef"); shortURL != "https://fesghel.com/go/abcdef" {
+			t.Errorf("expect https://fesghel.com/go/abcdef, got %s", shortURL)
+		}
+	})
+
+	t.Run("destinations pointing at the short urls", func(t *testing.T) {
+		// the other destinations are stored, the storage fails since it's not needed here
+		postgresMock.On("insert", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+
+		for destination, self := range map[string]bool{
+			"https://FESGHEL.com/go/abcdef": true,
+			"http://fesghel.com:80/go":      true,
+			"https://fesghel.com/about":     false,
+			"https://www.fesghel.com/go/ab": false,
+		} {
+			_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(destination)})
+			if self != errors.Is(err, ErrDestinationSelf) {
+				t.Errorf("expect %q pointing at the short urls to be %v: %v", destination, self, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package urls
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	aliasMinLength = 3
+	aliasMaxLength = 12 // the size of the urls.id column
+)
+
+// reservedAliases are the path segments served by the application itself,
+// an alias can never be one of them so it won't shadow any route.
+var reservedAliases = []string{
+	"api", "v1", "healthz", "liveness", "readiness", "metrics", "shorten",
+}
+
+var (
+	ErrAliasInvalid       = errors.New("error alias is not a valid base62 string")
+	ErrAliasReserved      = errors.New("error alias is a reserved word")
+	ErrAliasAlreadyExists = errors.New("error alias already exists")
+)
+
+// validateAlias checks the user-chosen alias against the base62 charset and the reserved words
+func validateAlias(alias string) error {
+	if len(alias) < aliasMinLength || len(alias) > aliasMaxLength {
+		return ErrAliasInvalid
+	}
+
+	for index := 0; index < len(alias); index++ {
+		if strings.IndexByte(base62Chars, alias[index]) == -1 {
+			return ErrAliasInvalid
+		}
+	}
+
+	for _, reserved := range reservedAliases {
+		if strings.EqualFold(alias, reserved) {
+			return ErrAliasReserved
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package urls
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+const (
+	tagMaxLength      = 64
+	maxTags           = 20
+	campaignMaxLength = 128
+	titleMaxLength    = 256
+	metadataMaxSize   = 8 * 1024 // bytes
+)
+
+var (
+	ErrTagInvalid       = errors.New("error tag should be 1 to 64 characters of a-z, 0-9, -, _, . and :")
+	ErrTooManyTags      = errors.New("error a link can't have more than 20 tags")
+	ErrCampaignTooLong  = errors.New("error campaign exceeds the maximum length")
+	ErrTitleTooLong     = errors.New("error title exceeds the maximum length")
+	ErrMetadataInvalid  = errors.New("error metadata should be a json object")
+	ErrMetadataTooLarge = errors.New("error metadata exceeds the maximum size")
+)
+
+// NormalizeTag lowercases the tag and checks its characters
+func NormalizeTag(rawTag string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(rawTag))
+	if len(tag) == 0 || len(tag) > tagMaxLength {
+		return "", ErrTagInvalid
+	}
+
+	for index := 0; index < len(tag); index++ {
+		switch character := tag[index]; {
+		case 'a' <= character && character <= 'z', '0' <= character && character <= '9':
+		case character == '-', character == '_', character == '.', character == ':':
+		default:
+			return "", ErrTagInvalid
+		}
+	}
+
+	return tag, nil
+}
+
+// validateAttributes checks the descriptive attributes of the link and normalizes its tags
+func validateAttributes(link *entities.Link) error {
+	link.Campaign = strings.TrimSpace(link.Campaign)
+	if utf8.RuneCountInString(link.Campaign) > campaignMaxLength {
+		return ErrCampaignTooLong
+	}
+
+	link.Title = strings.TrimSpace(link.Title)
+	if utf8.RuneCountInString(link.Title) > titleMaxLength {
+		return ErrTitleTooLong
+	}
+
+	tags := make([]string, 0, len(link.Tags))
+	for _, rawTag := range link.Tags {
+		tag, err := NormalizeTag(rawTag)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	link.Tags = slices.Compact(tags)
+	if len(link.Tags) > maxTags {
+		return ErrTooManyTags
+	}
+
+	if metadata := bytes.TrimSpace(link.Metadata); len(metadata) == 0 || bytes.Equal(metadata, []byte("null")) {
+		link.Metadata = nil
+	} else {
+		if len(metadata) > metadataMaxSize {
+			return ErrMetadataTooLarge
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(metadata, &object); err != nil {
+			return ErrMetadataInvalid
+		}
+
+		// compacted, since the jsonb column doesn't keep the formatting anyway
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, metadata); err != nil {
+			return ErrMetadataInvalid
+		}
+		link.Metadata = compacted.Bytes()
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+// ShortenResult is the outcome of shortening a single link of a batch
+type ShortenResult struct {
+	ID  string
+	Err error
+}
+
+var (
+	ErrBatchTooLarge = errors.New("error batch exceeds the maximum size")
+)
+
+// ShortenBatch stores all of the links via multi-row inserts
+// 1. validate and deduplicate each link
+// 2. generate the keys (or use the given aliases)
+// 3. store the pending links on postgres at once
+// 4. retry the colliding generated keys with new keys
+// 5. store the stored links on redis via a pipeline
+func (s *service) ShortenBatch(ctx context.Context, links []entities.Link) (results []ShortenResult, err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "shorten_batch")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("shorten_batch", status)
+	}(time.Now())
+
+	if len(links) > s.config.MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results = make([]ShortenResult, len(links))
+	links = append([]entities.Link(nil), links...) // the normalized urls and generated keys are assigned to a copy
+
+	pending := make([]int, 0, len(links)) // the indexes waiting to be stored
+	generated := make(map[int]bool)       // the indexes having generated keys
+	taken := make(map[string]struct{})    // the keys (see linkKey) used within the batch
+
+	for index := range links {
+		if err := s.validate(&links[index]); err != nil {
+			results[index].Err = err
+			continue
+		}
+		if err := s.authorizeDomain(ctx, &links[index]); err != nil {
+			results[index].Err = err
+			continue
+		}
+		link := links[index]
+
+		if err := s.check(ctx, s.checker, link); err != nil {
+			results[index].Err = err
+			continue
+		}
+
+		if key, ok, err := s.deduplicate(ctx, link); err != nil || ok {
+			results[index] = ShortenResult{ID: key, Err: err}
+			continue
+		}
+
+		if len(link.ID) != 0 {
+			if _, exists := taken[linkKey(link.Domain, link.ID)]; exists {
+				results[index].Err = ErrAliasAlreadyExists
+				continue
+			}
+			taken[linkKey(link.Domain, link.ID)] = struct{}{}
+		} else {
+			generated[index] = true
+		}
+
+		pending = append(pending, index)
+	}
+
+	stored := make([]entities.Link, 0, len(pending))
+	for attempt := 1; len(pending) > 0; attempt++ {
+		batch := make([]entities.Link, 0, len(pending))
+		for _, index := range pending {
+			if generated[index] {
+				key, err := s.nextBatchKey(ctx, links[index].Domain, string(links[index].URL), taken)
+				if err != nil {
+					results[index].Err = err
+					continue
+				}
+				links[index].ID = key
+			}
+			batch = append(batch, links[index])
+		}
+
+		inserted, err := s.postgres.insertBatch(ctx, batch, time.Now())
+		if err != nil {
+			for _, index := range pending {
+				if results[index].Err == nil {
+					results[index].Err = errors.Join(ErrInsertingIntoPostgres, err)
+				}
+			}
+			break
+		}
+
+		retries := pending[:0]
+		for _, index := range pending {
+			if results[index].Err != nil {
+				continue
+			}
+
+			id := links[index].ID
+			if _, ok := inserted[linkKey(links[index].Domain, id)]; ok {
+				results[index].ID = id
+				stored = append(stored, links[index])
+				continue
+			}
+
+			switch {
+			case !generated[index]:
+				// the caller has chosen the alias, so there is nothing to retry with
+				results[index].Err = ErrAliasAlreadyExists
+			case attempt >= s.config.MaxRetriesOnCollision:
+				results[index].Err = ErrMaxRetriesForCollision
+			default: // Collision: retry with new key
+				delete(taken, linkKey(links[index].Domain, id))
+				retries = append(retries, index)
+			}
+		}
+		pending = retries
+	}
+
+	s.cacheBatch(ctx, stored)
+	s.remember(ctx, stored...)
+	return results, nil
+}
+
+// nextBatchKey returns a new key which is not used by the other links of the batch on the same domain
+func (s *service) nextBatchKey(ctx context.Context, domain, seed string, taken map[string]struct{}) (string, error) {
+	for attempt := 1; ; attempt++ {
+		key, err := s.nextKey(ctx, seed)
+		if err != nil {
+			return "", err
+		}
+
+		if _, exists := taken[linkKey(domain, key)]; !exists {
+			taken[linkKey(domain, key)] = struct{}{}
+			return key, nil
+		}
+
+		if attempt >= s.config.MaxRetriesOnCollision {
+			return "", ErrMaxRetriesForCollision
+		}
+	}
+}
+
+// cacheBatch stores the links on redis at once
+func (s *service) cacheBatch(ctx context.Context, links []entities.Link) {
+	cached := make([]entities.Link, 0, len(links))
+	expirations := make([]time.Duration, 0, len(links))
+
+	for _, link := range links {
+		if expiration := s.cacheExpiration(link); expiration > 0 {
+			cached = append(cached, link)
+			expirations = append(expirations, expiration)
+		}
+	}
+
+	if len(cached) > 0 {
+		_ = s.redis.insertBatch(ctx, cached, expirations)
+	}
+}
@@ -0,0 +1,147 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+// insertedIDs returns the keys of all of the links of the given batch as inserted
+func insertedIDs(links []entities.Link) map[string]struct{} {
+	inserted := make(map[string]struct{})
+	for _, link := range links {
+		inserted[linkKey(link.Domain, link.ID)] = struct{}{}
+	}
+	return inserted
+}
+
+func TestServiceShortenBatch(t *testing.T) {
+	var (
+		url = "https://example.com"
+	)
+
+	t.Run("success", func(t *testing.T) {
+		initializeServiceInstance()
+
+		var inserted map[string]struct{}
+		{ // prepare the mocks
+			postgresMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(func(links []entities.Link) map[string]struct{} {
+					inserted = insertedIDs(links)
+					return inserted
+				}, nil).Once()
+
+			redisMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(nil).Once()
+		}
+
+		links := []entities.Link{{URL: entities.URL(url)}, {ID: "spring", URL: entities.URL(url + "/spring")}}
+		results, err := serviceInstance.ShortenBatch(context.TODO(), links)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		for _, result := range results {
+			assert.NoError(t, result.Err)
+			assert.Contains(t, inserted, result.ID)
+		}
+		assert.Equal(t, "spring", results[1].ID)
+		assert.Empty(t, links[0].ID, "the given links should not be modified")
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("batch too large", func(t *testing.T) {
+		initializeServiceInstance()
+
+		links := make([]entities.Link, serviceInstance.config.MaxBatchSize+1)
+		_, err := serviceInstance.ShortenBatch(context.TODO(), links)
+		if !errors.Is(err, ErrBatchTooLarge) {
+			t.Errorf("expect ErrBatchTooLarge error %v", err)
+		}
+	})
+
+	t.Run("partial failures", func(t *testing.T) {
+		initializeServiceInstance()
+
+		{ // prepare the mocks
+			postgresMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(func(links []entities.Link) map[string]struct{} {
+					inserted := insertedIDs(links)
+					delete(inserted, "taken") // already stored by someone else
+					return inserted
+				}, nil).Once()
+
+			redisMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(nil).Once()
+		}
+
+		links := []entities.Link{
+			{ID: "summer", URL: entities.URL(url)},
+			{ID: "summer", URL: entities.URL(url)},
+			{ID: "taken", URL: entities.URL(url)},
+			{ID: "x", URL: entities.URL(url)},
+			{URL: entities.URL(url), Redirect: 200},
+		}
+		results, err := serviceInstance.ShortenBatch(context.TODO(), links)
+		assert.NoError(t, err)
+		assert.Equal(t, ShortenResult{ID: "summer"}, results[0])
+		assert.ErrorIs(t, results[1].Err, ErrAliasAlreadyExists)
+		assert.ErrorIs(t, results[2].Err, ErrAliasAlreadyExists)
+		assert.ErrorIs(t, results[3].Err, ErrAliasInvalid)
+		assert.ErrorIs(t, results[4].Err, ErrRedirectInvalid)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("retry for collision", func(t *testing.T) {
+		initializeServiceInstance()
+
+		{ // prepare the mocks
+			postgresMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(map[string]struct{}{}, nil).Once()
+
+			postgresMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(insertedIDs, nil).Once()
+
+			redisMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(nil).Once()
+		}
+
+		results, err := serviceInstance.ShortenBatch(context.TODO(), []entities.Link{{URL: entities.URL(url)}})
+		assert.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.NotEmpty(t, results[0].ID)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("max retry exceeded", func(t *testing.T) {
+		initializeServiceInstance()
+
+		{ // prepare the mocks
+			postgresMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(map[string]struct{}{}, nil).Times(serviceInstance.config.MaxRetriesOnCollision)
+
+			redisMock.
+				On("insertBatch", mock.Anything, mock.Anything, mock.Anything).
+				Return(nil).Maybe()
+		}
+
+		results, err := serviceInstance.ShortenBatch(context.TODO(), []entities.Link{{URL: entities.URL(url)}})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, ErrMaxRetriesForCollision)
+		postgresMock.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,269 @@
+package urls
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+type BloomConfig struct {
+	Enabled bool `default:"false"`
+	// FalsePositiveRate is the target rate of the unknown ids passing the filter (and reaching the stores)
+	FalsePositiveRate float64 `default:"0.01" split_words:"true"`
+	// MinCapacity is the minimum number of ids the filter is sized for, it's sized for twice the links otherwise
+	MinCapacity int64 `default:"100000" split_words:"true"`
+	// RebuildInterval is the interval of rebuilding the filter from postgres, it drops the deleted links and
+	// recovers the ids whose broadcast has been missed
+	RebuildInterval entities.Interval `default:"10m" split_words:"true"`
+	// Channel is the redis pub/sub channel the shortened ids are broadcast on
+	Channel string `default:"fesghel:urls:shortened"`
+}
+
+const cacheTierBloom = "bloom"
+
+// bloomFilter is a probabilistic set of the ids, it never misses an added id but may contain the others
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64 // the number of the bits
+	hashes uint64 // the number of the bits set for each id
+	items  uint64
+}
+
+// newBloomFilter sizes the filter to hold the given number of ids at the false positive rate
+func newBloomFilter(capacity int64, rate float64) *bloomFilter {
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	size = max(64, (size+63)/64*64)
+	hashes := uint64(max(1, math.Round(float64(size)/float64(capacity)*math.Ln2)))
+
+	return &bloomFilter{bits: make([]uint64, size/64), size: size, hashes: hashes}
+}
+
+// positions derives the bits of the id by double hashing
+func (f *bloomFilter) positions(key string, visit func(position uint64) bool) {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	sum := hash.Sum64()
+
+	first, second := sum&math.MaxUint32, sum>>32|1
+	for index := range f.hashes {
+		if !visit((first + index*second) % f.size) {
+			return
+		}
+	}
+}
+
+func (f *bloomFilter) add(key string) {
+	f.positions(key, func(position uint64) bool {
+		f.bits[position/64] |= 1 << (position % 64)
+		return true
+	})
+	f.items++
+}
+
+func (f *bloomFilter) mayContain(key string) bool {
+	contains := true
+	f.positions(key, func(position uint64) bool {
+		contains = f.bits[position/64]&(1<<(position%64)) != 0
+		return contains
+	})
+	return contains
+}
+
+// falsePositiveRate estimates the rate of the unknown ids passing the filter by the number of the added ids
+func (f *bloomFilter) falsePositiveRate() float64 {
+	return math.Pow(1-math.Exp(-float64(f.hashes*f.items)/float64(f.size)), float64(f.hashes))
+}
+
+// resubscribeInterval is the interval of subscribing again to the shortened ids once the subscription is closed
+const resubscribeInterval = time.Second
+
+// bloomGuard refuses the unknown ids before reaching any of the stores, so enumerating the random ids is cheap.
+// Each instance holds the filter in its memory, the shortened ids are broadcast via redis pub/sub to the others.
+// It lets everything pass until it's built, and it's rebuilt after each gap of the subscription to the broadcasts.
+//
+// The ids shortened by the other instances are refused until their broadcast is received, which is usually within
+// milliseconds. The ids whose broadcast has failed to be published are refused by the others until the next rebuild.
+type bloomGuard struct {
+	config   *BloomConfig
+	logger   *zap.Logger
+	gauge    metrics_pkg.Gauge
+	postgres Postgres
+	redis    Redis
+	rebuilds chan struct{} // asks run to rebuild the filter before its interval
+
+	mutex      sync.RWMutex
+	filter     *bloomFilter
+	rebuilding bool     // the added ids are kept in pending as well, then added to the rebuilt filter
+	pending    []string // the ids added while rebuilding
+}
+
+func newBloomGuard(cfg *BloomConfig, l *zap.Logger, g metrics_pkg.Gauge, p Postgres, r Redis) *bloomGuard {
+	return &bloomGuard{config: cfg, logger: l, gauge: g, postgres: p, redis: r, rebuilds: make(chan struct{}, 1)}
+}
+
+// mayContain reports whether the link may exist, it's false only when the link doesn't exist for sure
+func (g *bloomGuard) mayContain(key string) bool {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if g.filter == nil {
+		return true
+	}
+	return g.filter.mayContain(key)
+}
+
+func (g *bloomGuard) add(key string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.filter != nil {
+		g.filter.add(key)
+	}
+	if g.rebuilding {
+		g.pending = append(g.pending, key)
+	}
+}
+
+// remember adds the shortened id, then broadcasts it to the other instances
+func (g *bloomGuard) remember(ctx context.Context, key string) {
+	g.add(key)
+	g.observe()
+
+	if err := g.redis.publish(ctx, g.config.Channel, key); err != nil {
+		// the other instances recover the id once they rebuild their filter
+		g.logger.Warn("error broadcasting the shortened id", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// rebuild builds a new filter from all of the links stored on postgres, then replaces the current one by it
+func (g *bloomGuard) rebuild(ctx context.Context) error {
+	count, err := g.postgres.countLinks(ctx)
+	if err != nil {
+		return err
+	}
+	filter := newBloomFilter(max(g.config.MinCapacity, 2*count), g.config.FalsePositiveRate)
+
+	g.mutex.Lock()
+	g.rebuilding, g.pending = true, nil
+	g.mutex.Unlock()
+
+	err = g.postgres.linkKeys(ctx, filter.add)
+
+	g.mutex.Lock()
+	if err == nil {
+		for _, key := range g.pending {
+			filter.add(key)
+		}
+		g.filter = filter
+	}
+	g.rebuilding, g.pending = false, nil
+	g.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	g.observe()
+	return nil
+}
+
+// run rebuilds the filter periodically (or once it's asked to) until the context is done
+func (g *bloomGuard) run(ctx context.Context) {
+	ticker := time.NewTicker(g.config.RebuildInterval.Duration())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-g.rebuilds:
+		}
+
+		if err := g.rebuild(ctx); err != nil {
+			g.logger.Error("error rebuilding the bloom filter", zap.Error(err))
+		}
+	}
+}
+
+// requestRebuild asks run to rebuild the filter, the requests made before it has started rebuilding are merged
+func (g *bloomGuard) requestRebuild() {
+	select {
+	case g.rebuilds <- struct{}{}:
+	default:
+	}
+}
+
+// listen adds the ids shortened by the other instances until the context is done. The ids broadcast during the gaps
+// of the subscription are missed, so the filter is rebuilt after each of them.
+func (g *bloomGuard) listen(ctx context.Context, shortened <-chan string) {
+	for {
+		for key := range shortened {
+			if key == subscriptionGap {
+				g.logger.Warn("the shortened ids subscription has been reconnected, rebuilding the bloom filter")
+				g.requestRebuild()
+				continue
+			}
+			g.add(key)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		g.logger.Error("the shortened ids channel has been closed, subscribing again")
+
+		var ok bool
+		if shortened, ok = g.resubscribe(ctx); !ok {
+			return
+		}
+		g.requestRebuild()
+	}
+}
+
+// resubscribe subscribes to the shortened ids until it succeeds, it's false once the context is done
+func (g *bloomGuard) resubscribe(ctx context.Context) (<-chan string, bool) {
+	for {
+		shortened, err := g.redis.subscribe(ctx, g.config.Channel)
+		if err == nil {
+			return shortened, true
+		}
+		g.logger.Error("error subscribing to the shortened ids", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(resubscribeInterval):
+		}
+	}
+}
+
+// observe exposes the size and the estimated false positive rate of the filter
+func (g *bloomGuard) observe() {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if g.filter == nil {
+		return
+	}
+	g.gauge.Set(float64(g.filter.size), "bits")
+	g.gauge.Set(float64(g.filter.items), "items")
+	g.gauge.Set(g.filter.falsePositiveRate(), "false_positive_rate")
+}
+
+// remember adds the shortened links to the bloom filter, when it's enabled
+func (s *service) remember(ctx context.Context, links ...entities.Link) {
+	if s.bloom == nil {
+		return
+	}
+
+	for _, link := range links {
+		s.bloom.remember(ctx, linkKey(link.Domain, link.ID))
+	}
+}
@@ -0,0 +1,166 @@
+package urls
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+func TestBloomFilter(t *testing.T) {
+	var (
+		capacity = int64(10000)
+		rate     = 0.01
+	)
+
+	filter := newBloomFilter(capacity, rate)
+	for index := range capacity {
+		filter.add(fmt.Sprintf("id-%d", index))
+	}
+
+	for index := range capacity {
+		if !filter.mayContain(fmt.Sprintf("id-%d", index)) {
+			t.Fatalf("expect the added id-%d to be contained", index)
+		}
+	}
+
+	var falsePositives int
+	for index := range capacity {
+		if filter.mayContain(fmt.Sprintf("unknown-%d", index)) {
+			falsePositives++
+		}
+	}
+
+	if measured := float64(falsePositives) / float64(capacity); measured > 2*rate {
+		t.Errorf("expect the false positive rate to be about %v, measured %v", rate, measured)
+	}
+
+	if estimated := filter.falsePositiveRate(); estimated > 2*rate || estimated < rate/2 {
+		t.Errorf("expect the estimated false positive rate to be about %v, got %v", rate, estimated)
+	}
+}
+
+func TestBloomGuard(t *testing.T) {
+	initializeServiceInstance()
+	cfg := BloomConfig{FalsePositiveRate: 0.01, MinCapacity: 100, Channel: "shortened"}
+	guard := newBloomGuard(&cfg, zap.NewNop(), metrics_pkg.RegisterGaugeNoop(), postgresMock, redisMock)
+
+	assert.True(t, guard.mayContain("unknown"), "expect every id to pass until the filter is built")
+
+	{ // prepare the mocks
+		postgresMock.On("countLinks", mock.Anything).Return(int64(2), nil).Once()
+		postgresMock.
+			On("linkKeys", mock.Anything, mock.Anything).
+			Run(func(mock.Arguments) { guard.add("shortened-meanwhile") }).
+			Return([]string{"abcdef", "go.acme.com/spring"}, nil).Once()
+	}
+
+	assert.NoError(t, guard.rebuild(context.TODO()))
+	postgresMock.AssertExpectations(t)
+
+	for _, key := range []string{"abcdef", "go.acme.com/spring", "shortened-meanwhile"} {
+		assert.True(t, guard.mayContain(key), "expect %s to be contained", key)
+	}
+	assert.False(t, guard.mayContain("unknown"))
+
+	t.Run("remember", func(t *testing.T) {
+		redisMock.On("publish", mock.Anything, "shortened", "fresh").Return(nil).Once()
+
+		guard.remember(context.TODO(), "fresh")
+		assert.True(t, guard.mayContain("fresh"))
+		redisMock.AssertExpectations(t)
+	})
+}
+
+func TestBloomGuardListen(t *testing.T) {
+	initializeServiceInstance()
+	cfg := BloomConfig{FalsePositiveRate: 0.01, MinCapacity: 100, Channel: "shortened"}
+	guard := newBloomGuard(&cfg, zap.NewNop(), metrics_pkg.RegisterGaugeNoop(), postgresMock, redisMock)
+	guard.filter = newBloomFilter(cfg.MinCapacity, cfg.FalsePositiveRate)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shortened, resubscribed := make(chan string), make(chan string)
+	redisMock.On("subscribe", mock.Anything, "shortened").Return((<-chan string)(resubscribed), nil).Once()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		guard.listen(ctx, shortened)
+	}()
+
+	t.Run("broadcast", func(t *testing.T) {
+		shortened <- "elsewhere"
+		assert.Eventually(t, func() bool { return guard.mayContain("elsewhere") }, time.Second, 10*time.Millisecond)
+		assert.Len(t, guard.rebuilds, 0)
+	})
+
+	t.Run("gap", func(t *testing.T) {
+		shortened <- subscriptionGap
+		assert.Eventually(t, func() bool { return len(guard.rebuilds) == 1 }, time.Second, 10*time.Millisecond,
+			"expect the filter to be rebuilt after the gap")
+		<-guard.rebuilds
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		close(shortened)
+		assert.Eventually(t, func() bool { return len(guard.rebuilds) == 1 }, time.Second, 10*time.Millisecond,
+			"expect the filter to be rebuilt once subscribed again")
+
+		resubscribed <- "after"
+		assert.Eventually(t, func() bool { return guard.mayContain("after") }, time.Second, 10*time.Millisecond)
+		redisMock.AssertExpectations(t)
+	})
+
+	cancel()
+	close(resubscribed)
+	<-done
+}
+
+func TestServiceRetrieveBloom(t *testing.T) {
+	initializeServiceInstance()
+	cfg := BloomConfig{FalsePositiveRate: 0.01, MinCapacity: 100}
+	serviceInstance.bloom = newBloomGuard(&cfg, zap.NewNop(), metrics_pkg.RegisterGaugeNoop(), postgresMock, redisMock)
+	serviceInstance.bloom.filter = newBloomFilter(cfg.MinCapacity, cfg.FalsePositiveRate)
+	serviceInstance.bloom.add("known")
+	defer func() { serviceInstance.bloom = nil }()
+
+	t.Run("unknown id", func(t *testing.T) {
+		_, err := serviceInstance.Retrieve(context.TODO(), "", "unknown")
+		assert.ErrorIs(t, err, ErrShortenIDNotExists)
+		redisMock.AssertNotCalled(t, "retrieve", mock.Anything, mock.Anything, mock.Anything)
+		postgresMock.AssertNotCalled(t, "retrieve", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("shortened by another instance", func(t *testing.T) {
+		_, err := serviceInstance.Retrieve(context.TODO(), "", "elsewhere")
+		assert.ErrorIs(t, err, ErrShortenIDNotExists, "expect the id to be refused until its broadcast is received")
+
+		serviceInstance.bloom.add("elsewhere") // the broadcast is received
+		redisMock.
+			On("retrieve", mock.Anything, "", "elsewhere").
+			Return(entities.Link{ID: "elsewhere", URL: "https://example.com"}, time.Time{}, nil).Once()
+
+		_, err = serviceInstance.Retrieve(context.TODO(), "", "elsewhere")
+		assert.NoError(t, err)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("known id", func(t *testing.T) {
+		redisMock.
+			On("retrieve", mock.Anything, "", "known").
+			Return(entities.Link{ID: "known", URL: "https://example.com"}, time.Time{}, nil).Once()
+
+		_, err := serviceInstance.Retrieve(context.TODO(), "", "known")
+		assert.NoError(t, err)
+		redisMock.AssertExpectations(t)
+	})
+}
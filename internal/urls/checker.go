@@ -0,0 +1,319 @@
+package urls
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/idna"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+// DestinationChecker decides whether the links are allowed to redirect to a destination
+type DestinationChecker interface {
+	// Check returns ErrDestinationBlocked when the url should never be redirected to
+	Check(ctx context.Context, url entities.URL) error
+}
+
+type CheckerConfig struct {
+	// BlocklistFile is the path of the blocklist, each line is either a domain (including its subdomains)
+	// or a regular expression prefixed by "regex:" matched against the whole url, "#" starts a comment.
+	BlocklistFile string `split_words:"true"`
+	// BlocklistDatabase loads the rules of the blocked_destinations table alongside the file
+	BlocklistDatabase bool `default:"false" split_words:"true"`
+	// RefreshInterval is the interval of reloading the blocklist, so the newly blocked destinations stop redirecting
+	RefreshInterval entities.Interval `default:"1m" split_words:"true"`
+	// ReputationURL is the endpoint of the external reputation service, it's only consulted on shortening
+	ReputationURL     string        `split_words:"true"`
+	ReputationTimeout time.Duration `default:"2s" split_words:"true"`
+	// ReputationFailOpen accepts the destinations whenever the reputation service is unavailable
+	ReputationFailOpen bool `default:"true" split_words:"true"`
+}
+
+var (
+	ErrDestinationBlocked  = errors.New("error destination is blocked")
+	ErrCheckingDestination = errors.New("error checking destination")
+	errBlockRuleInvalid    = errors.New("error block rule is invalid")
+)
+
+const (
+	blockRuleDomain = "domain"
+	blockRuleRegex  = "regex"
+)
+
+// blockRule is a single rule of the blocklist, the pattern is either a domain or a regular expression
+type blockRule struct {
+	Pattern string `db:"pattern"`
+	Kind    string `db:"kind"`
+}
+
+// checkers runs all of the checkers in order, the first rejection is returned
+type checkers []DestinationChecker
+
+func (c checkers) Check(ctx context.Context, url entities.URL) error {
+	for _, checker := range c {
+		if err := checker.Check(ctx, url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blocklist rejects the destinations matching any of its domains or regular expressions,
+// the rules are kept in memory and reloaded from the file and database periodically.
+type blocklist struct {
+	config   *CheckerConfig
+	logger   *zap.Logger
+	postgres Postgres
+
+	mutex   sync.RWMutex
+	domains map[string]struct{}
+	regexps []*regexp.Regexp
+}
+
+func newBlocklist(cfg *CheckerConfig, l *zap.Logger, p Postgres) *blocklist {
+	return &blocklist{config: cfg, logger: l, postgres: p, domains: make(map[string]struct{})}
+}
+
+func (b *blocklist) Check(_ context.Context, rawURL entities.URL) error {
+	parsed, err := url.Parse(string(rawURL))
+	if err != nil {
+		return errors.Join(ErrDestinationBlocked, err) // a destination which can't be parsed is never safe
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	// the host and all of its parent domains are looked up
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	for domain := host; len(domain) != 0; {
+		if _, blocked := b.domains[domain]; blocked {
+			return fmt.Errorf("%w: domain %s", ErrDestinationBlocked, domain)
+		}
+
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+
+	for _, expression := range b.regexps {
+		if expression.MatchString(string(rawURL)) {
+			return fmt.Errorf("%w: pattern %s", ErrDestinationBlocked, expression)
+		}
+	}
+
+	return nil
+}
+
+// load reads the rules of all of the sources, then replaces the current rules at once
+func (b *blocklist) load(ctx context.Context) error {
+	var rules []blockRule
+
+	if len(b.config.BlocklistFile) != 0 {
+		file, err := os.Open(b.config.BlocklistFile)
+		if err != nil {
+			return fmt.Errorf("error opening blocklist file: %v", err)
+		}
+		defer file.Close()
+
+		fileRules, err := parseBlocklist(file)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, fileRules...)
+	}
+
+	if b.config.BlocklistDatabase {
+		databaseRules, err := b.postgres.blocklist(ctx)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, databaseRules...)
+	}
+
+	domains := make(map[string]struct{}, len(rules))
+	regexps := make([]*regexp.Regexp, 0)
+
+	for _, rule := range rules {
+		switch rule.Kind {
+		case blockRuleDomain:
+			domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(strings.TrimSpace(rule.Pattern), "."))
+			if err != nil || len(domain) == 0 {
+				return fmt.Errorf("%w: domain %q", errBlockRuleInvalid, rule.Pattern)
+			}
+			domains[domain] = struct{}{}
+		case blockRuleRegex:
+			expression, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("%w: regex %q, %v", errBlockRuleInvalid, rule.Pattern, err)
+			}
+			regexps = append(regexps, expression)
+		default:
+			return fmt.Errorf("%w: kind %q", errBlockRuleInvalid, rule.Kind)
+		}
+	}
+
+	b.mutex.Lock()
+	b.domains, b.regexps = domains, regexps
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// run reloads the blocklist until the context is done, the current rules are kept on failures
+func (b *blocklist) run(ctx context.Context) {
+	ticker := time.NewTicker(b.config.RefreshInterval.Duration())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.load(ctx); err != nil {
+				b.logger.Error("error reloading the blocklist", zap.Error(err))
+			}
+		}
+	}
+}
+
+// parseBlocklist reads the rules of a blocklist file
+func parseBlocklist(reader io.Reader) ([]blockRule, error) {
+	var rules []blockRule
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if pattern, ok := strings.CutPrefix(line, blockRuleRegex+":"); ok {
+			rules = append(rules, blockRule{Pattern: strings.TrimSpace(pattern), Kind: blockRuleRegex})
+			continue
+		}
+		rules = append(rules, blockRule{Pattern: line, Kind: blockRuleDomain})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading blocklist: %v", err)
+	}
+
+	return rules, nil
+}
+
+// reputationChecker asks an external service about the destinations,
+// the url is posted as {"url": "..."} and {"blocked": bool, "reason": "..."} is expected.
+type reputationChecker struct {
+	endpoint string
+	client   *http.Client
+	failOpen bool
+	logger   *zap.Logger
+}
+
+func newReputationChecker(cfg *CheckerConfig, l *zap.Logger) *reputationChecker {
+	return &reputationChecker{
+		endpoint: cfg.ReputationURL,
+		client:   &http.Client{Timeout: cfg.ReputationTimeout},
+		failOpen: cfg.ReputationFailOpen,
+		logger:   l,
+	}
+}
+
+type reputationRequest struct {
+	URL string `json:"url"`
+}
+
+type reputationResponse struct {
+	Blocked bool   `json:"blocked"`
+	Reason  string `json:"reason"`
+}
+
+func (r *reputationChecker) Check(ctx context.Context, url entities.URL) error {
+	response, err := r.ask(ctx, url)
+	if err != nil {
+		if r.failOpen {
+			r.logger.Warn("error asking the reputation service, the destination is accepted", zap.Error(err))
+			return nil
+		}
+		return errors.Join(ErrCheckingDestination, err)
+	}
+
+	if response.Blocked {
+		return fmt.Errorf("%w: %s", ErrDestinationBlocked, response.Reason)
+	}
+	return nil
+}
+
+func (r *reputationChecker) ask(ctx context.Context, url entities.URL) (*reputationResponse, error) {
+	body, err := json.Marshal(reputationRequest{URL: string(url)})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := r.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", httpResponse.StatusCode)
+	}
+
+	response := &reputationResponse{}
+	if err := json.NewDecoder(httpResponse.Body).Decode(response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// newDestinationCheckers returns the checkers of shortening and retrieving (nil when there is nothing to check),
+// the external reputation service is only asked while shortening to keep the redirects fast.
+func newDestinationCheckers(ctx context.Context, cfg *CheckerConfig, l *zap.Logger, p Postgres) (shorten, retrieve DestinationChecker, err error) {
+	var shortenCheckers checkers
+
+	if len(cfg.BlocklistFile) != 0 || cfg.BlocklistDatabase {
+		blocklist := newBlocklist(cfg, l, p)
+		if err := blocklist.load(ctx); err != nil {
+			return nil, nil, err
+		}
+		go blocklist.run(ctx)
+
+		shortenCheckers = append(shortenCheckers, blocklist)
+		retrieve = blocklist
+	}
+
+	if len(cfg.ReputationURL) != 0 {
+		shortenCheckers = append(shortenCheckers, newReputationChecker(cfg, l))
+	}
+
+	if len(shortenCheckers) != 0 {
+		shorten = shortenCheckers
+	}
+
+	return shorten, retrieve, nil
+}
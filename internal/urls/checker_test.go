@@ -0,0 +1,169 @@
+package urls
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestParseBlocklist(t *testing.T) {
+	content := `
+# phishing domains
+evil.com
+bücher.example # international domains are allowed
+
+regex: ^https?://[^/]+/wp-login\.php
+`
+
+	rules, err := parseBlocklist(strings.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, []blockRule{
+		{Pattern: "evil.com", Kind: blockRuleDomain},
+		{Pattern: "bücher.example", Kind: blockRuleDomain},
+		{Pattern: `^https?://[^/]+/wp-login\.php`, Kind: blockRuleRegex},
+	}, rules)
+}
+
+func TestBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist")
+	if err := os.WriteFile(path, []byte("evil.com\nregex:/wp-login\\.php$\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	postgresMock := new(mockPostgres)
+	postgresMock.
+		On("blocklist", mock.Anything).
+		Return([]blockRule{{Pattern: "bücher.example", Kind: blockRuleDomain}}, nil).Once()
+
+	cfg := &CheckerConfig{BlocklistFile: path, BlocklistDatabase: true}
+	blocklist := newBlocklist(cfg, zap.NewNop(), postgresMock)
+	if err := blocklist.load(context.TODO()); err != nil {
+		t.Fatalf("expect no errors %v", err)
+	}
+	postgresMock.AssertExpectations(t)
+
+	cases := map[entities.URL]bool{
+		"https://evil.com/login":              true,
+		"https://login.evil.com/":             true,
+		"https://xn--bcher-kva.example/":      true,
+		"https://example.com/wp-login.php":    true,
+		"https://notevil.com/":                false,
+		"https://evil.com.example.org/":       false,
+		"https://example.com/wp-login.php?a=": false,
+	}
+
+	for url, blocked := range cases {
+		err := blocklist.Check(context.TODO(), url)
+		if blocked != errors.Is(err, ErrDestinationBlocked) {
+			t.Errorf("expect %s to be blocked: %v, got %v", url, blocked, err)
+		}
+	}
+
+	t.Run("invalid rule", func(t *testing.T) {
+		postgresMock.
+			On("blocklist", mock.Anything).
+			Return([]blockRule{{Pattern: "(", Kind: blockRuleRegex}}, nil).Once()
+
+		err := blocklist.load(context.TODO())
+		if !errors.Is(err, errBlockRuleInvalid) {
+			t.Errorf("expect errBlockRuleInvalid error %v", err)
+		}
+
+		// the previous rules are kept
+		if err := blocklist.Check(context.TODO(), "https://evil.com"); !errors.Is(err, ErrDestinationBlocked) {
+			t.Errorf("expect ErrDestinationBlocked error %v", err)
+		}
+	})
+}
+
+func TestReputationChecker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := reputationRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch request.URL {
+		case "https://phishing.example/":
+			_ = json.NewEncoder(w).Encode(reputationResponse{Blocked: true, Reason: "phishing"})
+		case "https://unavailable.example/":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_ = json.NewEncoder(w).Encode(reputationResponse{})
+		}
+	}))
+	defer server.Close()
+
+	checker := newReputationChecker(&CheckerConfig{ReputationURL: server.URL}, zap.NewNop())
+
+	t.Run("safe", func(t *testing.T) {
+		assert.NoError(t, checker.Check(context.TODO(), "https://example.com/"))
+	})
+
+	t.Run("blocked", func(t *testing.T) {
+		err := checker.Check(context.TODO(), "https://phishing.example/")
+		assert.ErrorIs(t, err, ErrDestinationBlocked)
+		assert.Contains(t, err.Error(), "phishing")
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		checker.failOpen = false
+		assert.ErrorIs(t, checker.Check(context.TODO(), "https://unavailable.example/"), ErrCheckingDestination)
+
+		checker.failOpen = true
+		assert.NoError(t, checker.Check(context.TODO(), "https://unavailable.example/"))
+	})
+}
+
+type mockChecker struct{ mock.Mock }
+
+func (m *mockChecker) Check(ctx context.Context, url entities.URL) error {
+	args := m.Called(ctx, url)
+	return args.Error(0)
+}
+
+func TestServiceCheckDestination(t *testing.T) {
+	var (
+		url = "https://evil.com/"
+	)
+
+	checker := new(mockChecker)
+	checker.On("Check", mock.Anything, entities.URL(url)).Return(ErrDestinationBlocked)
+
+	serviceInstance.checker, serviceInstance.rechecker = checker, checker
+	defer func() { serviceInstance.checker, serviceInstance.rechecker = nil, nil }()
+
+	t.Run("shorten", func(t *testing.T) {
+		initializeServiceInstance()
+
+		_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url)})
+		assert.ErrorIs(t, err, ErrDestinationBlocked)
+		postgresMock.AssertNotCalled(t, "insert", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("retrieve cached link", func(t *testing.T) {
+		initializeServiceInstance()
+
+		redisMock.
+			On("retrieve", mock.Anything, "", "id").
+			Return(entities.Link{ID: "id", URL: entities.URL(url)}, time.Time{}, nil).Once()
+
+		_, err := serviceInstance.Retrieve(context.TODO(), "", "id")
+		assert.ErrorIs(t, err, ErrDestinationBlocked)
+		redisMock.AssertExpectations(t)
+	})
+}
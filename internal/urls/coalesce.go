@@ -0,0 +1,93 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+// load looks the link up on postgres and caches it, the concurrent loads of the same link share a single lookup.
+// The lookup isn't canceled by the caller which has started it, since the others may still be waiting for it.
+func (s *service) load(ctx context.Context, domain, id string) (link entities.Link, err error) {
+	// the lookup may outlive the request, so it shouldn't alias the buffer of the request (e.g. the fiber params)
+	domain, id = strings.Clone(domain), strings.Clone(id)
+	result := s.loads.DoChan(linkKey(domain, id), func() (any, error) {
+		return s.lookup(context.WithoutCancel(ctx), domain, id)
+	})
+
+	select {
+	case <-ctx.Done():
+		return entities.Link{}, errors.Join(ErrRetreivingDataFromDatabase, ctx.Err())
+	case loaded := <-result:
+		if loaded.Shared {
+			s.metrics.Counter.IncrementVector("coalesce", metrics_pkg.StatusSuccess)
+		}
+		if loaded.Err != nil {
+			return entities.Link{}, loaded.Err
+		}
+		return loaded.Val.(entities.Link), nil
+	}
+}
+
+// lookup retrieves the link from postgres, then fills the cache by it
+func (s *service) lookup(ctx context.Context, domain, id string) (entities.Link, error) {
+	start := time.Now()
+	link, err := s.postgres.retrieve(ctx, domain, id)
+	if err != nil {
+		if errors.Is(err, ErrIDNotExists) {
+			s.cacheMissing(ctx, domain, id)
+			return entities.Link{}, ErrShortenIDNotExists
+		}
+		return entities.Link{}, errors.Join(ErrRetreivingDataFromDatabase, err)
+	}
+	s.observeLoad(time.Since(start))
+
+	if link.Expired(time.Now()) {
+		return entities.Link{}, ErrShortenIDExpired
+	}
+	s.cache(ctx, link) // the disabled links are cached as well, so they don't reach postgres
+	return link, nil
+}
+
+// observeLoad updates the moving average of the lookup durations
+func (s *service) observeLoad(duration time.Duration) {
+	for {
+		previous := s.loadDuration.Load()
+		average := int64(duration)
+		if previous > 0 {
+			average = previous + (int64(duration)-previous)/8
+		}
+		if s.loadDuration.CompareAndSwap(previous, average) {
+			return
+		}
+	}
+}
+
+// refreshEarly decides whether the cache should be refreshed before it expires, using the XFetch algorithm:
+// the closer the expiration (and the slower the lookups), the more likely a retrieval refreshes it.
+func (s *service) refreshEarly(cachedUntil, now time.Time) bool {
+	if s.config.EarlyRefreshBeta <= 0 || cachedUntil.IsZero() {
+		return false
+	}
+
+	delta := float64(s.loadDuration.Load())
+	gap := -delta * s.config.EarlyRefreshBeta * math.Log(1-rand.Float64()) // 1-x keeps the logarithm finite
+	return !now.Add(time.Duration(gap)).Before(cachedUntil)
+}
+
+// refresh loads the link again in the background, so its cache is renewed before it expires
+func (s *service) refresh(ctx context.Context, domain, id string) {
+	_, err := s.load(ctx, domain, id)
+
+	var status = metrics_pkg.StatusSuccess
+	if err != nil {
+		status = metrics_pkg.StatusFailure
+	}
+	s.metrics.Counter.IncrementVector("refresh", status)
+}
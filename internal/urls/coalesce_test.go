@@ -0,0 +1,120 @@
+package urls
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestServiceRetrieveCoalesced(t *testing.T) {
+	var (
+		id        = "viral"
+		url       = "https://example.com"
+		retrieves = 10
+	)
+
+	initializeServiceInstance()
+	release := make(chan time.Time)
+
+	{ // prepare the mocks
+		redisMock.
+			On("retrieve", mock.Anything, "", id).
+			Return(entities.Link{}, time.Time{}, errIDNotFound).Times(retrieves)
+
+		postgresMock.
+			On("retrieve", mock.Anything, "", id).
+			WaitUntil(release).
+			Return(entities.Link{ID: id, URL: entities.URL(url)}, nil).Once()
+
+		redisMock.
+			On("insert", mock.Anything, linkMatcher(id, url), serviceInstance.config.CacheExpiration).
+			Return(nil).Once()
+	}
+
+	var wg sync.WaitGroup
+	for range retrieves {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link, err := serviceInstance.Retrieve(context.TODO(), "", id)
+			assert.NoError(t, err)
+			assert.Equal(t, entities.URL(url), link.URL)
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond) // all of the retrievals are waiting for the single lookup now
+	close(release)
+	wg.Wait()
+
+	postgresMock.AssertExpectations(t)
+	redisMock.AssertExpectations(t)
+}
+
+func TestServiceRefreshEarly(t *testing.T) {
+	initializeServiceInstance()
+	now := time.Now()
+
+	serviceInstance.loadDuration.Store(int64(10 * time.Millisecond))
+	defer func() { serviceInstance.config.EarlyRefreshBeta = 0 }()
+
+	t.Run("disabled", func(t *testing.T) {
+		serviceInstance.config.EarlyRefreshBeta = 0
+		assert.False(t, serviceInstance.refreshEarly(now, now))
+	})
+
+	serviceInstance.config.EarlyRefreshBeta = 1
+
+	t.Run("unknown expiration", func(t *testing.T) {
+		assert.False(t, serviceInstance.refreshEarly(time.Time{}, now))
+	})
+
+	t.Run("expiring", func(t *testing.T) {
+		assert.True(t, serviceInstance.refreshEarly(now, now))
+	})
+
+	t.Run("far from expiration", func(t *testing.T) {
+		assert.False(t, serviceInstance.refreshEarly(now.Add(time.Hour), now))
+	})
+
+	t.Run("refresh in the background", func(t *testing.T) {
+		var (
+			id        = "hot"
+			url       = "https://example.com"
+			refreshed = make(chan struct{})
+		)
+
+		{ // prepare the mocks
+			redisMock.
+				On("retrieve", mock.Anything, "", id).
+				Return(entities.Link{ID: id, URL: entities.URL(url)}, time.Now(), nil).Once()
+
+			postgresMock.
+				On("retrieve", mock.Anything, "", id).
+				Return(entities.Link{ID: id, URL: entities.URL(url)}, nil).Once()
+
+			redisMock.
+				On("insert", mock.Anything, linkMatcher(id, url), serviceInstance.config.CacheExpiration).
+				Run(func(mock.Arguments) { close(refreshed) }).
+				Return(nil).Once()
+		}
+
+		// the id aliases a buffer which is reused once the retrieval returns, the same as the params of fiber
+		buffer := []byte(id)
+		_, err := serviceInstance.Retrieve(context.TODO(), "", unsafe.String(&buffer[0], len(buffer)))
+		assert.NoError(t, err)
+		copy(buffer, "cld")
+
+		select {
+		case <-refreshed:
+		case <-time.After(time.Second):
+			t.Error("expect the cache to be refreshed")
+		}
+	})
+}
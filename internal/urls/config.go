@@ -1,8 +1,11 @@
 package urls
 
 import (
+	"strings"
 	"time"
 
+	"github.com/mohammadne/fesghel/internal/entities"
+
 	Postgres_pkg "github.com/mohammadne/fesghel/pkg/databases/postgres"
 	redis_pkg "github.com/mohammadne/fesghel/pkg/databases/redis"
 )
@@ -13,5 +16,63 @@ type Config struct {
 	ShortURLLength        int                  `required:"true" split_words:"true"`
 	MaxRetriesOnCollision int                  `required:"true" split_words:"true"`
 	CacheExpiration       time.Duration        `required:"true" split_words:"true"`
-	BaseAddress           string               `required:"true" split_words:"true"`
+	// NegativeCacheExpiration is how long the unknown ids are cached as missing, they're not cached when it's zero
+	NegativeCacheExpiration time.Duration `default:"10s" split_words:"true"`
+	// LocalCache keeps the hot links in the memory of each instance in front of redis, it's disabled when not given
+	LocalCache *LocalCacheConfig `split_words:"true"`
+	// Bloom refuses the unknown ids before reaching any of the stores, it's disabled when not given
+	Bloom *BloomConfig
+	// EarlyRefreshBeta refreshes the cache of the hot links before it expires (XFetch), the higher it is the earlier
+	// they're refreshed and 1 is the usual choice. The cache is only filled on the misses when it's zero.
+	EarlyRefreshBeta float64 `default:"0" split_words:"true"`
+	// BaseAddress is the host (with optional port and path prefix) of the short urls, e.g. fesghel.com or fesghel.com/go
+	BaseAddress BaseAddress `required:"true" split_words:"true"`
+	// Scheme is the scheme of the short urls, either http or https
+	Scheme Scheme `default:"https"`
+	// FallbackURL is where the unknown ids of the default domain are redirected to, they're not found when it's not given.
+	// It's normalized on starting, and refused when it points at the short urls.
+	FallbackURL entities.URL `split_words:"true"`
+	// DomainsRefreshInterval is the interval of reloading the registered domains, the changes made by the other instances
+	// are applied after that
+	DomainsRefreshInterval entities.Interval `default:"1m" split_words:"true"`
+	// MaxBatchSize is the maximum number of links shortened at once (at most 5461 due to the parameters limit of postgres)
+	MaxBatchSize int `default:"1000" split_words:"true"`
+	// KeyGenerator is the strategy of generating the keys, one of hash, sequence, snowflake or random
+	KeyGenerator KeyGeneratorStrategy `default:"hash" split_words:"true"`
+	// NodeID is the unique id (0-1023) of the instance, only used by the snowflake key generator
+	NodeID int64 `default:"0" split_words:"true"`
+	// KeyPool pre-generates the keys, the key generator is used whenever the pool is exhausted
+	KeyPool *KeyPoolConfig `split_words:"true"`
+	// URL is the policy the destinations should satisfy, they are stored normalized
+	URL *URLConfig `split_words:"true"`
+	// Checker refuses the unsafe destinations, nothing is checked when it's not given
+	Checker *CheckerConfig `split_words:"true"`
+	// PasswordCost is the bcrypt cost of hashing the passwords of the protected links
+	PasswordCost int `default:"10" split_words:"true"`
+	// Deduplicate returns the existing id of an already shortened url for all of the requests,
+	// otherwise only the requests asking for it are deduplicated.
+	Deduplicate bool `default:"false"`
+}
+
+type URLConfig struct {
+	// AllowedSchemes are the schemes the destinations may have
+	AllowedSchemes []string `default:"http,https" split_words:"true"`
+	// MaxLength is the maximum length of the (normalized) destinations
+	MaxLength int `default:"2048" split_words:"true"`
+	// StripFragment removes the fragment of the destinations before storing them
+	StripFragment bool `default:"false" split_words:"true"`
+}
+
+// policy returns the url policy of the config, the defaults are used when the config is not given
+func (cfg *URLConfig) policy() entities.URLPolicy {
+	if cfg == nil {
+		return entities.URLPolicy{Schemes: []string{"http", "https"}, MaxLength: 2048}
+	}
+
+	schemes := make([]string, 0, len(cfg.AllowedSchemes))
+	for _, scheme := range cfg.AllowedSchemes {
+		schemes = append(schemes, strings.ToLower(strings.TrimSpace(scheme)))
+	}
+
+	return entities.URLPolicy{Schemes: schemes, MaxLength: cfg.MaxLength, StripFragment: cfg.StripFragment}
 }
@@ -0,0 +1,23 @@
+package urls
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+// hashURL returns the hex encoded sha256 of the url, which is normalized by validate beforehand.
+// It's stored alongside each link to look up the links having the same destination.
+func hashURL(url entities.URL) string {
+	hash := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(hash[:])
+}
+
+// deduplicable reports whether the link has nothing but its destination. The links having any other attribute
+// are never deduplicated, since the existing link wouldn't honor them (e.g. a permanent link given for an expiring one),
+// and only the plain links are looked up for the same reason.
+func deduplicable(link entities.Link) bool {
+	return len(link.ID) == 0 && !link.Protected() && link.ExpiresAt == nil && link.Redirect == entities.RedirectDefault &&
+		len(link.Campaign) == 0 && len(link.Title) == 0 && len(link.Tags) == 0 && len(link.Metadata) == 0
+}
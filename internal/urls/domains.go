@@ -0,0 +1,338 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+var (
+	ErrDomainInvalid    = errors.New("error domain should be a host (with optional port) without a scheme or path")
+	ErrDomainNotExists  = errors.New("error domain not exists")
+	ErrDomainExists     = errors.New("error domain already exists")
+	ErrDomainInUse      = errors.New("error domain still has links")
+	ErrDomainNotOwner   = errors.New("error domain is not owned by the caller")
+	ErrFallbackURLSelf  = errors.New("error fallback url points at the domain itself, it would redirect in a loop")
+	ErrRetrievingDomain = errors.New("error retrieving the domains")
+)
+
+// normalizeHost normalizes the host of a domain the same as the base address
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.TrimSpace(host), ".")
+	if len(host) == 0 {
+		return "", nil
+	}
+
+	if strings.Contains(host, "://") || strings.ContainsAny(host, "/?#@") {
+		return "", ErrDomainInvalid
+	}
+
+	normalized, err := entities.URL("https://" + host).Normalize(entities.URLPolicy{Schemes: []string{"https"}})
+	if err != nil {
+		return "", errors.Join(ErrDomainInvalid, err)
+	}
+
+	parsed, err := url.Parse(string(normalized))
+	if err != nil {
+		return "", errors.Join(ErrDomainInvalid, err)
+	}
+
+	return parsed.Host, nil
+}
+
+// linkKey identifies the link across the domains, the ids of the default domain are kept as-is
+func linkKey(domain, id string) string {
+	if len(domain) == 0 {
+		return id
+	}
+	return domain + "/" + id
+}
+
+// NormalizeDomain normalizes the host of the domain, the host of the base address is the default domain which is empty
+func (s *service) NormalizeDomain(host string) (string, error) {
+	domain, err := normalizeHost(host)
+	if err != nil {
+		return "", err
+	}
+
+	if domain == s.config.BaseAddress.Host {
+		return "", nil
+	}
+	return domain, nil
+}
+
+// domainRegistry holds all of the registered domains in memory, so the redirects never wait for postgres
+type domainRegistry struct {
+	mutex   sync.RWMutex
+	domains map[string]entities.Domain
+}
+
+func (r *domainRegistry) get(host string) (entities.Domain, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	domain, ok := r.domains[host]
+	return domain, ok
+}
+
+func (r *domainRegistry) set(domain entities.Domain) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.domains == nil {
+		r.domains = make(map[string]entities.Domain)
+	}
+	r.domains[domain.Host] = domain
+}
+
+func (r *domainRegistry) remove(host string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.domains, host)
+}
+
+func (r *domainRegistry) replace(domains []entities.Domain) {
+	registered := make(map[string]entities.Domain, len(domains))
+	for _, domain := range domains {
+		registered[domain.Host] = domain
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.domains = registered
+}
+
+// loadDomains fills the registry by all of the domains stored on postgres
+func (s *service) loadDomains(ctx context.Context) error {
+	domains, err := s.postgres.domains(ctx, "")
+	if err != nil {
+		return err
+	}
+	s.domains.replace(domains)
+	return nil
+}
+
+// refreshDomains reloads the registry periodically, so the changes made by the other instances are applied
+func (s *service) refreshDomains(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.loadDomains(ctx); err != nil {
+				s.logger.Error("error refreshing the domains", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ResolveHost returns the domain served on the host, the unknown hosts are served as the default domain
+func (s *service) ResolveHost(host string) entities.Domain {
+	fallback := entities.Domain{FallbackURL: s.config.FallbackURL}
+
+	domain, err := s.NormalizeDomain(host)
+	if err != nil || len(domain) == 0 {
+		return fallback
+	}
+
+	if registered, ok := s.domains.get(domain); ok {
+		return registered
+	}
+	return fallback
+}
+
+// authorizeDomain checks the link may be shortened on its domain, the domains having no owner are shared
+func (s *service) authorizeDomain(ctx context.Context, link *entities.Link) error {
+	domain, err := s.NormalizeDomain(link.Domain)
+	if err != nil {
+		return err
+	}
+	link.Domain = domain
+
+	if len(domain) == 0 {
+		return nil
+	}
+
+	registered, err := s.domain(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	if len(registered.OwnerID) != 0 && registered.OwnerID != link.OwnerID {
+		return ErrDomainNotOwner
+	}
+	return nil
+}
+
+// domain returns the registered domain, postgres is asked when it's not registered on this instance yet
+func (s *service) domain(ctx context.Context, host string) (entities.Domain, error) {
+	if registered, ok := s.domains.get(host); ok {
+		return registered, nil
+	}
+
+	registered, err := s.postgres.domain(ctx, host)
+	if err != nil {
+		if errors.Is(err, ErrDomainNotExists) {
+			return entities.Domain{}, err
+		}
+		return entities.Domain{}, errors.Join(ErrRetrievingDomain, err)
+	}
+
+	s.domains.set(registered)
+	return registered, nil
+}
+
+// CreateDomain registers the domain, its host should point at this service
+func (s *service) CreateDomain(ctx context.Context, domain entities.Domain) (created entities.Domain, err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "create_domain")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("create_domain", status)
+	}(time.Now())
+
+	if domain.Host, err = s.NormalizeDomain(domain.Host); err != nil {
+		return entities.Domain{}, err
+	}
+
+	if len(domain.Host) == 0 {
+		return entities.Domain{}, ErrDomainExists // the default domain is always there
+	}
+
+	if err = s.validateFallback(&domain); err != nil {
+		return entities.Domain{}, err
+	}
+
+	domain.CreatedAt = time.Now().UTC()
+	if err = s.postgres.insertDomain(ctx, domain); err != nil {
+		if errors.Is(err, errUniqueConstraintViolated) {
+			return entities.Domain{}, ErrDomainExists
+		}
+		return entities.Domain{}, errors.Join(ErrInsertingIntoPostgres, err)
+	}
+
+	s.domains.set(domain)
+	return domain, nil
+}
+
+// Domains returns the domains of the owner, all of the domains are returned when the owner is empty
+func (s *service) Domains(ctx context.Context, ownerID string) ([]entities.Domain, error) {
+	domains, err := s.postgres.domains(ctx, ownerID)
+	if err != nil {
+		return nil, errors.Join(ErrRetrievingDomain, err)
+	}
+	return domains, nil
+}
+
+// UpdateDomain replaces the fallback url of the domain
+func (s *service) UpdateDomain(ctx context.Context, domain entities.Domain) (err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "update_domain")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("update_domain", status)
+	}(time.Now())
+
+	if err = s.validateFallback(&domain); err != nil {
+		return err
+	}
+
+	updated, err := s.postgres.updateDomain(ctx, domain)
+	if err != nil {
+		if errors.Is(err, ErrDomainNotExists) {
+			return err
+		}
+		return errors.Join(ErrUpdatingPostgres, err)
+	}
+
+	s.domains.set(updated)
+	return nil
+}
+
+// DeleteDomain removes the domain, the domains still having (not deleted) links are kept
+func (s *service) DeleteDomain(ctx context.Context, host string) (err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "delete_domain")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("delete_domain", status)
+	}(time.Now())
+
+	if err = s.postgres.deleteDomain(ctx, host); err != nil {
+		if errors.Is(err, ErrDomainNotExists) || errors.Is(err, ErrDomainInUse) {
+			return err
+		}
+		return errors.Join(ErrUpdatingPostgres, err)
+	}
+
+	s.domains.remove(host)
+	return nil
+}
+
+// AuthorizeDomain checks the ownership of the (normalized) domain, the domains having no owner can't be managed by anyone
+func (s *service) AuthorizeDomain(ctx context.Context, host, ownerID string) error {
+	registered, err := s.postgres.domain(ctx, host)
+	if err != nil {
+		if errors.Is(err, ErrDomainNotExists) {
+			return err
+		}
+		return errors.Join(ErrRetrievingDomain, err)
+	}
+
+	if len(registered.OwnerID) == 0 || registered.OwnerID != ownerID {
+		return ErrDomainNotOwner
+	}
+	return nil
+}
+
+// validateFallback normalizes the fallback url of the domain (when given), it shouldn't point at the domain itself
+// validateDefaultFallback normalizes the fallback url of the default domain the same as the destinations,
+// it's refused when it points at any of the short urls since it would redirect in a loop.
+func (s *service) validateDefaultFallback() error {
+	if len(s.config.FallbackURL) == 0 {
+		return nil
+	}
+
+	fallback, err := s.config.FallbackURL.Normalize(s.config.URL.policy())
+	if err != nil {
+		return err
+	}
+
+	if s.pointsAtSelf(fallback) {
+		return ErrFallbackURLSelf
+	}
+	s.config.FallbackURL = fallback
+	return nil
+}
+
+func (s *service) validateFallback(domain *entities.Domain) error {
+	if len(domain.FallbackURL) == 0 {
+		return nil
+	}
+
+	fallback, err := domain.FallbackURL.Normalize(s.config.URL.policy())
+	if err != nil {
+		return err
+	}
+	domain.FallbackURL = fallback
+
+	if parsed, err := url.Parse(string(fallback)); err == nil && strings.EqualFold(parsed.Host, domain.Host) {
+		return ErrFallbackURLSelf
+	}
+	return nil
+}
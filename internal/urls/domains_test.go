@@ -0,0 +1,171 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	serviceInstance.config.BaseAddress = BaseAddress{Host: "fesghel.com", PathPrefix: "/go"}
+	defer func() { serviceInstance.config.BaseAddress = BaseAddress{} }()
+
+	t.Run("valid", func(t *testing.T) {
+		for host, expected := range map[string]string{
+			"":                "",
+			"Fesghel.COM":     "",
+			"go.Acme.com.":    "go.acme.com",
+			"go.acme.com:443": "go.acme.com",
+			"bücher.example":  "xn--bcher-kva.example",
+		} {
+			if domain, err := serviceInstance.NormalizeDomain(host); err != nil || domain != expected {
+				t.Errorf("expect %q to be normalized as %q, got %q and %v", host, expected, domain, err)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, host := range []string{"https://go.acme.com", "go.acme.com/links", "user@go.acme.com", "go..acme.com"} {
+			if _, err := serviceInstance.NormalizeDomain(host); !errors.Is(err, ErrDomainInvalid) {
+				t.Errorf("expect ErrDomainInvalid error for %q: %v", host, err)
+			}
+		}
+	})
+}
+
+func TestResolveHost(t *testing.T) {
+	serviceInstance.config.FallbackURL = "https://fesghel.com/not-found"
+	defer func() { serviceInstance.config.FallbackURL = "" }()
+
+	serviceInstance.domains.replace([]entities.Domain{{Host: "go.acme.com", OwnerID: "acme", FallbackURL: "https://acme.com"}})
+	defer serviceInstance.domains.replace(nil)
+
+	if domain := serviceInstance.ResolveHost("GO.acme.com"); domain.Host != "go.acme.com" || domain.FallbackURL != "https://acme.com" {
+		t.Errorf("expect the registered domain, got %+v", domain)
+	}
+
+	if domain := serviceInstance.ResolveHost("unknown.com"); domain.Host != "" || domain.FallbackURL != "https://fesghel.com/not-found" {
+		t.Errorf("expect the default domain, got %+v", domain)
+	}
+}
+
+func TestServiceShortenOnDomain(t *testing.T) {
+	var (
+		url = "https://example.com"
+	)
+
+	t.Run("owned domain", func(t *testing.T) {
+		initializeServiceInstance()
+		defer serviceInstance.domains.replace(nil)
+
+		{ // prepare the mocks
+			postgresMock.
+				On("domain", mock.Anything, "go.acme.com").
+				Return(entities.Domain{Host: "go.acme.com", OwnerID: "acme"}, nil).Once()
+
+			postgresMock.
+				On("insert", mock.Anything, mock.MatchedBy(func(link entities.Link) bool { return link.Domain == "go.acme.com" }), mock.Anything).
+				Return(nil).Once()
+
+			redisMock.
+				On("insert", mock.Anything, mock.Anything, mock.Anything).
+				Return(nil).Once()
+		}
+
+		id, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), Domain: "Go.Acme.com", OwnerID: "acme"})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+		postgresMock.AssertExpectations(t)
+
+		// the domain is registered, so the links pointing at it are refused
+		_, err = serviceInstance.Shorten(context.TODO(), entities.Link{URL: "https://go.acme.com/" + entities.URL(id)})
+		assert.ErrorIs(t, err, ErrDestinationSelf)
+	})
+
+	t.Run("domain of another owner", func(t *testing.T) {
+		initializeServiceInstance()
+		defer serviceInstance.domains.replace(nil)
+
+		postgresMock.
+			On("domain", mock.Anything, "go.acme.com").
+			Return(entities.Domain{Host: "go.acme.com", OwnerID: "acme"}, nil).Once()
+
+		_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), Domain: "go.acme.com", OwnerID: "globex"})
+		assert.ErrorIs(t, err, ErrDomainNotOwner)
+		postgresMock.AssertNotCalled(t, "insert", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not registered domain", func(t *testing.T) {
+		initializeServiceInstance()
+
+		postgresMock.
+			On("domain", mock.Anything, "unknown.com").
+			Return(entities.Domain{}, ErrDomainNotExists).Once()
+
+		_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), Domain: "unknown.com"})
+		assert.ErrorIs(t, err, ErrDomainNotExists)
+	})
+}
+
+func TestServiceCreateDomain(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		initializeServiceInstance()
+		defer serviceInstance.domains.replace(nil)
+
+		postgresMock.
+			On("insertDomain", mock.Anything, mock.MatchedBy(func(domain entities.Domain) bool {
+				return domain.Host == "go.acme.com" && domain.FallbackURL == "https://acme.com/"
+			})).
+			Return(nil).Once()
+
+		domain, err := serviceInstance.CreateDomain(context.TODO(), entities.Domain{Host: "GO.acme.com", OwnerID: "acme", FallbackURL: "HTTPS://acme.com/"})
+		assert.NoError(t, err)
+		assert.Equal(t, "go.acme.com", domain.Host)
+		assert.Equal(t, "go.acme.com", serviceInstance.ResolveHost("go.acme.com").Host)
+	})
+
+	t.Run("already exists", func(t *testing.T) {
+		initializeServiceInstance()
+
+		postgresMock.
+			On("insertDomain", mock.Anything, mock.Anything).
+			Return(errUniqueConstraintViolated).Once()
+
+		_, err := serviceInstance.CreateDomain(context.TODO(), entities.Domain{Host: "go.acme.com"})
+		assert.ErrorIs(t, err, ErrDomainExists)
+	})
+
+	t.Run("fallback pointing at itself", func(t *testing.T) {
+		initializeServiceInstance()
+
+		_, err := serviceInstance.CreateDomain(context.TODO(), entities.Domain{Host: "go.acme.com", FallbackURL: "https://go.acme.com/home"})
+		assert.ErrorIs(t, err, ErrFallbackURLSelf)
+	})
+}
+
+func TestValidateDefaultFallback(t *testing.T) {
+	initializeServiceInstance()
+	serviceInstance.config.BaseAddress = BaseAddress{Host: "fesghel.com", PathPrefix: "/go"}
+	serviceInstance.domains.replace([]entities.Domain{{Host: "go.acme.com", OwnerID: "acme"}})
+	defer func() {
+		serviceInstance.config.BaseAddress, serviceInstance.config.FallbackURL = BaseAddress{}, ""
+		serviceInstance.domains.replace(nil)
+	}()
+
+	serviceInstance.config.FallbackURL = "HTTPS://Fesghel.com:443/not-found"
+	assert.NoError(t, serviceInstance.validateDefaultFallback())
+	assert.Equal(t, entities.URL("https://fesghel.com/not-found"), serviceInstance.config.FallbackURL)
+
+	for _, fallback := range []entities.URL{"https://fesghel.com/go/missing", "https://go.acme.com/home"} {
+		serviceInstance.config.FallbackURL = fallback
+		assert.ErrorIs(t, serviceInstance.validateDefaultFallback(), ErrFallbackURLSelf, "expect %s to be refused", fallback)
+	}
+
+	serviceInstance.config.FallbackURL = "ftp://fesghel.com/not-found"
+	assert.Error(t, serviceInstance.validateDefaultFallback())
+}
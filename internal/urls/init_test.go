@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 
+	"github.com/mohammadne/fesghel/internal/entities"
 	postgres_pkg "github.com/mohammadne/fesghel/pkg/databases/postgres"
 	redis_pkg "github.com/mohammadne/fesghel/pkg/databases/redis"
 	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
@@ -76,9 +77,11 @@ func TestMain(m *testing.M) {
 		config: &Config{ShortURLLength: 6,
 			MaxRetriesOnCollision: 3,
 			CacheExpiration:       time.Second * 10,
+			MaxBatchSize:          10,
 		},
-		logger:  zap.NewNop(),
-		metrics: newMetricsNoop(),
+		logger:       zap.NewNop(),
+		metrics:      newMetricsNoop(),
+		keyGenerator: &hashKeyGenerator{length: 6},
 	}
 
 	m.Run()
@@ -94,24 +97,160 @@ func initializeServiceInstance() {
 
 type mockPostgres struct{ mock.Mock }
 
-func (m *mockPostgres) insert(ctx context.Context, id, url string, timestamp time.Time) (err error) {
-	args := m.Called(ctx, id, url, timestamp)
+func (m *mockPostgres) insert(ctx context.Context, link entities.Link, timestamp time.Time) (err error) {
+	args := m.Called(ctx, link, timestamp)
 	return args.Error(0)
 }
 
-func (m *mockPostgres) retrieve(ctx context.Context, id string) (url string, err error) {
-	args := m.Called(ctx, id)
-	return args.Get(0).(string), args.Error(1)
+func (m *mockPostgres) insertBatch(ctx context.Context, links []entities.Link, timestamp time.Time) (inserted map[string]struct{}, err error) {
+	args := m.Called(ctx, links, timestamp)
+	if insertedFunc, ok := args.Get(0).(func([]entities.Link) map[string]struct{}); ok {
+		return insertedFunc(links), args.Error(1)
+	}
+	return args.Get(0).(map[string]struct{}), args.Error(1)
+}
+
+func (m *mockPostgres) retrieve(ctx context.Context, domain, id string) (link entities.Link, err error) {
+	args := m.Called(ctx, domain, id)
+	return args.Get(0).(entities.Link), args.Error(1)
+}
+
+func (m *mockPostgres) update(ctx context.Context, link entities.Link, timestamp time.Time) (err error) {
+	args := m.Called(ctx, link, timestamp)
+	return args.Error(0)
+}
+
+func (m *mockPostgres) setDisabled(ctx context.Context, domain, id string, disabled bool, timestamp time.Time) (err error) {
+	args := m.Called(ctx, domain, id, disabled, timestamp)
+	return args.Error(0)
+}
+
+func (m *mockPostgres) delete(ctx context.Context, domain, id string, timestamp time.Time) (err error) {
+	args := m.Called(ctx, domain, id, timestamp)
+	return args.Error(0)
+}
+
+func (m *mockPostgres) nextSequence(ctx context.Context) (value int64, err error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockPostgres) poolDepth(ctx context.Context) (depth int64, err error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockPostgres) fillPool(ctx context.Context, keys []string) (inserted int64, err error) {
+	args := m.Called(ctx, keys)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockPostgres) claimPool(ctx context.Context, count int) (keys []string, err error) {
+	args := m.Called(ctx, count)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockPostgres) lookup(ctx context.Context, domain, urlHash, ownerID string) (id string, err error) {
+	args := m.Called(ctx, domain, urlHash, ownerID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPostgres) owner(ctx context.Context, domain, id string) (ownerID string, err error) {
+	args := m.Called(ctx, domain, id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockPostgres) list(ctx context.Context, filter ListFilter, cursor *listCursor, now time.Time) (links []entities.Link, err error) {
+	args := m.Called(ctx, filter, cursor, now)
+	return args.Get(0).([]entities.Link), args.Error(1)
+}
+
+func (m *mockPostgres) blocklist(ctx context.Context) (rules []blockRule, err error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]blockRule), args.Error(1)
+}
+
+func (m *mockPostgres) countLinks(ctx context.Context) (count int64, err error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockPostgres) linkKeys(ctx context.Context, visit func(key string)) (err error) {
+	args := m.Called(ctx, visit)
+	if keys, ok := args.Get(0).([]string); ok {
+		for _, key := range keys {
+			visit(key)
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *mockPostgres) insertDomain(ctx context.Context, domain entities.Domain) (err error) {
+	args := m.Called(ctx, domain)
+	return args.Error(0)
+}
+
+func (m *mockPostgres) domain(ctx context.Context, host string) (domain entities.Domain, err error) {
+	args := m.Called(ctx, host)
+	return args.Get(0).(entities.Domain), args.Error(1)
+}
+
+func (m *mockPostgres) domains(ctx context.Context, ownerID string) (domains []entities.Domain, err error) {
+	args := m.Called(ctx, ownerID)
+	return args.Get(0).([]entities.Domain), args.Error(1)
+}
+
+func (m *mockPostgres) updateDomain(ctx context.Context, domain entities.Domain) (updated entities.Domain, err error) {
+	args := m.Called(ctx, domain)
+	return args.Get(0).(entities.Domain), args.Error(1)
+}
+
+func (m *mockPostgres) deleteDomain(ctx context.Context, host string) (err error) {
+	args := m.Called(ctx, host)
+	return args.Error(0)
+}
+
+// linkMatcher matches the links having the given id (any id when empty) and url
+func linkMatcher(id, url string) any {
+	return mock.MatchedBy(func(link entities.Link) bool {
+		return (len(id) == 0 || link.ID == id) && string(link.URL) == url
+	})
 }
 
 type mockRedis struct{ mock.Mock }
 
-func (m *mockRedis) insert(ctx context.Context, id, url string, expiration time.Duration) error {
-	args := m.Called(ctx, id, url, expiration)
+func (m *mockRedis) insert(ctx context.Context, link entities.Link, expiration time.Duration) error {
+	args := m.Called(ctx, link, expiration)
+	return args.Error(0)
+}
+
+func (m *mockRedis) insertBatch(ctx context.Context, links []entities.Link, expirations []time.Duration) error {
+	args := m.Called(ctx, links, expirations)
+	return args.Error(0)
+}
+
+func (m *mockRedis) retrieve(ctx context.Context, domain, id string) (link entities.Link, cachedUntil time.Time, err error) {
+	args := m.Called(ctx, domain, id)
+	return args.Get(0).(entities.Link), args.Get(1).(time.Time), args.Error(2)
+}
+
+func (m *mockRedis) insertMissing(ctx context.Context, domain, id string, expiration time.Duration) error {
+	args := m.Called(ctx, domain, id, expiration)
+	return args.Error(0)
+}
+
+func (m *mockRedis) invalidate(ctx context.Context, domain, id string) error {
+	args := m.Called(ctx, domain, id)
+	return args.Error(0)
+}
+
+func (m *mockRedis) publish(ctx context.Context, channel, message string) error {
+	args := m.Called(ctx, channel, message)
 	return args.Error(0)
 }
 
-func (m *mockRedis) retrieve(ctx context.Context, id string) (url string, err error) {
-	args := m.Called(ctx, id)
-	return args.Get(0).(string), args.Error(1)
+func (m *mockRedis) subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	args := m.Called(ctx, channel)
+	messages, _ := args.Get(0).(<-chan string)
+	return messages, args.Error(1)
 }
@@ -0,0 +1,179 @@
+package urls
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyGenerator generates the shortened ids of the links
+type KeyGenerator interface {
+	Generate(ctx context.Context, seed string) (string, error)
+}
+
+type KeyGeneratorStrategy string
+
+const (
+	KeyGeneratorHash      KeyGeneratorStrategy = "hash"
+	KeyGeneratorSequence  KeyGeneratorStrategy = "sequence"
+	KeyGeneratorSnowflake KeyGeneratorStrategy = "snowflake"
+	KeyGeneratorRandom    KeyGeneratorStrategy = "random"
+)
+
+var ErrKeyGeneratorUnknown = errors.New("error unknown key generator strategy")
+
+// NewKeyGenerator returns the key generator of the configured strategy
+func NewKeyGenerator(cfg *Config, postgres Postgres) (KeyGenerator, error) {
+	switch cfg.KeyGenerator {
+	case KeyGeneratorHash:
+		return &hashKeyGenerator{length: cfg.ShortURLLength}, nil
+	case KeyGeneratorSequence:
+		return &sequenceKeyGenerator{postgres: postgres}, nil
+	case KeyGeneratorSnowflake:
+		return newSnowflakeKeyGenerator(cfg.NodeID)
+	case KeyGeneratorRandom:
+		return &randomKeyGenerator{length: cfg.ShortURLLength}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrKeyGeneratorUnknown, cfg.KeyGenerator)
+	}
+}
+
+// hashKeyGenerator generates a key from the hash of the seed
+// 1. calculate current epoch timestamp
+// 2. generate a hash via sha256 from timestamp and the value
+// 3. calculate base62 of the trunicated hash
+type hashKeyGenerator struct {
+	length int
+}
+
+func (g *hashKeyGenerator) Generate(_ context.Context, seed string) (string, error) {
+	epoch := time.Now().UnixNano()
+	salt := strconv.FormatInt(epoch, 10)
+
+	// TODO: use mobile-number or account-id instead of value
+	hash := sha256.Sum256([]byte(seed + salt))
+	shortHash := hash[:g.length]
+
+	return encodeToBase62(shortHash), nil
+}
+
+// sequenceKeyGenerator encodes the next value of a postgres sequence,
+// the keys never collide with each other but they are predictable.
+type sequenceKeyGenerator struct {
+	postgres Postgres
+}
+
+func (g *sequenceKeyGenerator) Generate(ctx context.Context, _ string) (string, error) {
+	value, err := g.postgres.nextSequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encodeUint64ToBase62(uint64(value)), nil
+}
+
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+
+	snowflakeMaxNode     = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSequence = 1<<snowflakeSequenceBits - 1
+)
+
+// snowflakeEpoch is the custom epoch of the snowflake ids (2025-01-01 UTC)
+var snowflakeEpoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var ErrSnowflakeNodeInvalid = errors.New("error snowflake node id should be between 0 and 1023")
+
+// snowflakeKeyGenerator generates time ordered keys out of
+// 41 bits of milliseconds since the epoch, 10 bits of node id and 12 bits of sequence.
+// The keys are unique as long as each instance has its own node id.
+type snowflakeKeyGenerator struct {
+	node int64
+
+	mutex     sync.Mutex
+	timestamp int64
+	sequence  int64
+}
+
+func newSnowflakeKeyGenerator(node int64) (*snowflakeKeyGenerator, error) {
+	if node < 0 || node > snowflakeMaxNode {
+		return nil, ErrSnowflakeNodeInvalid
+	}
+	return &snowflakeKeyGenerator{node: node}, nil
+}
+
+func (g *snowflakeKeyGenerator) Generate(_ context.Context, _ string) (string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	now := time.Since(snowflakeEpoch).Milliseconds()
+	if now < g.timestamp { // the clock has moved backwards, stick to the last timestamp
+		now = g.timestamp
+	}
+
+	if now == g.timestamp {
+		g.sequence = (g.sequence + 1) & snowflakeMaxSequence
+		if g.sequence == 0 { // the sequence is exhausted, wait for the next millisecond
+			for now <= g.timestamp {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Since(snowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.timestamp = now
+
+	id := now<<(snowflakeNodeBits+snowflakeSequenceBits) | g.node<<snowflakeSequenceBits | g.sequence
+	return encodeUint64ToBase62(uint64(id)), nil
+}
+
+// randomKeyGenerator generates a key from cryptographically secure random bytes
+type randomKeyGenerator struct {
+	length int
+}
+
+func (g *randomKeyGenerator) Generate(_ context.Context, _ string) (string, error) {
+	buffer := make([]byte, g.length)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return encodeToBase62(buffer), nil
+}
+
+// Base62 charset
+const base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+func encodeUint64ToBase62(value uint64) string {
+	buffer := make([]byte, 8)
+	binary.BigEndian.PutUint64(buffer, value)
+	return encodeToBase62(buffer)
+}
+
+func encodeToBase62(input []byte) string {
+	num := new(big.Int).SetBytes(input)
+	var result strings.Builder
+	base := big.NewInt(62)
+	mod := new(big.Int)
+
+	for num.Cmp(big.NewInt(0)) > 0 {
+		num.DivMod(num, base, mod)
+		result.WriteByte(base62Chars[mod.Int64()])
+	}
+
+	// reverse result
+	runes := []rune(result.String())
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
@@ -0,0 +1,123 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyGenerator(t *testing.T) {
+	tests := []struct {
+		strategy KeyGeneratorStrategy
+		expected KeyGenerator
+	}{
+		{strategy: KeyGeneratorHash, expected: &hashKeyGenerator{}},
+		{strategy: KeyGeneratorSequence, expected: &sequenceKeyGenerator{}},
+		{strategy: KeyGeneratorSnowflake, expected: &snowflakeKeyGenerator{}},
+		{strategy: KeyGeneratorRandom, expected: &randomKeyGenerator{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			generator, err := NewKeyGenerator(&Config{KeyGenerator: tt.strategy, ShortURLLength: 6}, nil)
+			assert.NoError(t, err)
+			assert.IsType(t, tt.expected, generator)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := NewKeyGenerator(&Config{KeyGenerator: "unknown"}, nil)
+		if !errors.Is(err, ErrKeyGeneratorUnknown) {
+			t.Errorf("expect ErrKeyGeneratorUnknown error %v", err)
+		}
+	})
+
+	t.Run("invalid snowflake node", func(t *testing.T) {
+		_, err := NewKeyGenerator(&Config{KeyGenerator: KeyGeneratorSnowflake, NodeID: 1024}, nil)
+		if !errors.Is(err, ErrSnowflakeNodeInvalid) {
+			t.Errorf("expect ErrSnowflakeNodeInvalid error %v", err)
+		}
+	})
+}
+
+func TestHashKeyGenerator(t *testing.T) {
+	generator := &hashKeyGenerator{length: 6}
+	key, err := generator.Generate(context.TODO(), "anything")
+	assert.NoError(t, err)
+
+	expected := false
+	for i := range 4 {
+		if len(key) == generator.length+i {
+			expected = true
+		}
+	}
+
+	if !expected {
+		t.Errorf("invalid key length %d", len(key))
+	}
+}
+
+func BenchmarkGenerateKey(b *testing.B) {
+	url := "https://example.com"
+	generator := &hashKeyGenerator{length: 6}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = generator.Generate(context.TODO(), url)
+	}
+}
+
+func TestSequenceKeyGenerator(t *testing.T) {
+	initializeServiceInstance()
+	postgresMock.On("nextSequence", context.TODO()).Return(int64(238328), nil).Once()
+
+	generator := &sequenceKeyGenerator{postgres: postgresMock}
+	key, err := generator.Generate(context.TODO(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1000", key)
+	postgresMock.AssertExpectations(t)
+}
+
+func TestSnowflakeKeyGenerator(t *testing.T) {
+	generator, err := newSnowflakeKeyGenerator(7)
+	assert.NoError(t, err)
+
+	// more keys than a single millisecond's sequence to check the exhaustion
+	keys := make(map[string]struct{})
+	for range 2 * (snowflakeMaxSequence + 1) {
+		key, err := generator.Generate(context.TODO(), "")
+		assert.NoError(t, err)
+
+		if len(key) > aliasMaxLength {
+			t.Fatalf("key %s is longer than the id column", key)
+		}
+
+		if _, exists := keys[key]; exists {
+			t.Fatalf("duplicate key %s has been generated", key)
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func TestRandomKeyGenerator(t *testing.T) {
+	generator := &randomKeyGenerator{length: 6}
+
+	first, err := generator.Generate(context.TODO(), "")
+	assert.NoError(t, err)
+
+	second, err := generator.Generate(context.TODO(), "")
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+	for _, char := range first {
+		assert.Contains(t, base62Chars, string(char))
+	}
+}
+
+func TestEncodeUint64ToBase62(t *testing.T) {
+	assert.Equal(t, "10", encodeUint64ToBase62(62))
+	assert.Equal(t, "AzL8n0Y58m7", encodeUint64ToBase62(1<<63-1))
+}
@@ -0,0 +1,148 @@
+package urls
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+// ListSort is the order of the listed links
+type ListSort string
+
+const (
+	ListSortNewest ListSort = "newest" // by the creation time descending, the default
+	ListSortOldest ListSort = "oldest" // by the creation time ascending
+)
+
+func ToListSort(rawSort string) (ListSort, bool) {
+	switch sort := ListSort(rawSort); sort {
+	case ListSortNewest, ListSortOldest:
+		return sort, true
+	case "":
+		return ListSortNewest, true
+	default:
+		return "", false
+	}
+}
+
+// ListFilter narrows the links of the owner, the zero values don't filter
+type ListFilter struct {
+	OwnerID       string
+	Destination   string              // a case-insensitive substring of the url
+	Tag           string              // the links carrying the (normalized) tag
+	CreatedAfter  *time.Time          // inclusive
+	CreatedBefore *time.Time          // exclusive
+	Status        entities.LinkStatus // all of the statuses when empty
+	Sort          ListSort
+	Limit         int    // defaultListLimit when zero, capped at maxListLimit
+	Cursor        string // the NextCursor of the previous page
+}
+
+// ListPage is a page of the listed links, the NextCursor is empty on the last page
+type ListPage struct {
+	Links      []entities.Link
+	NextCursor string
+}
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+var (
+	ErrListFilterInvalid = errors.New("error list filter is invalid")
+	ErrCursorInvalid     = errors.New("error cursor is invalid")
+	ErrListingLinks      = errors.New("error listing links")
+)
+
+// listCursor is the position of the last link of a page, the links are ordered by (created_at, id)
+type listCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// encodeCursor encodes the cursor as an opaque base64url string of "<unix nano>:<id>"
+func encodeCursor(cursor listCursor) string {
+	raw := strconv.FormatInt(cursor.CreatedAt.UnixNano(), 10) + ":" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(encoded string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrCursorInvalid
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || len(id) == 0 {
+		return nil, ErrCursorInvalid
+	}
+
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrCursorInvalid
+	}
+
+	return &listCursor{CreatedAt: time.Unix(0, unixNano).UTC(), ID: id}, nil
+}
+
+// List returns a page of the owner's links, the next page is retrieved by giving its cursor
+func (s *service) List(ctx context.Context, filter ListFilter) (page ListPage, err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "list")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("list", status)
+	}(time.Now())
+
+	if filter.Sort == "" {
+		filter.Sort = ListSortNewest
+	}
+
+	if len(filter.Tag) != 0 {
+		if filter.Tag, err = NormalizeTag(filter.Tag); err != nil {
+			return ListPage{}, err
+		}
+	}
+
+	if filter.Limit < 0 || (filter.CreatedAfter != nil && filter.CreatedBefore != nil &&
+		!filter.CreatedAfter.Before(*filter.CreatedBefore)) {
+		return ListPage{}, ErrListFilterInvalid
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultListLimit
+	} else if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+
+	var cursor *listCursor
+	if len(filter.Cursor) != 0 {
+		if cursor, err = decodeCursor(filter.Cursor); err != nil {
+			return ListPage{}, err
+		}
+	}
+
+	// an extra link is retrieved to find out whether there is a next page
+	links, err := s.postgres.list(ctx, filter, cursor, time.Now())
+	if err != nil {
+		return ListPage{}, errors.Join(ErrListingLinks, err)
+	}
+
+	if len(links) > filter.Limit {
+		links = links[:filter.Limit]
+		last := links[len(links)-1]
+		page.NextCursor = encodeCursor(listCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	page.Links = links
+	return page, nil
+}
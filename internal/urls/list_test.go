@@ -0,0 +1,79 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestList(t *testing.T) {
+	var (
+		sampleOwner = "acme"
+		createdAt   = time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)
+	)
+
+	t.Run("returns the cursor of the next page", func(t *testing.T) {
+		initializeServiceInstance()
+
+		links := []entities.Link{
+			{ID: "first", CreatedAt: createdAt.Add(time.Second)},
+			{ID: "second", CreatedAt: createdAt},
+			{ID: "third", CreatedAt: createdAt.Add(-time.Second)},
+		}
+		filter := ListFilter{OwnerID: sampleOwner, Limit: 2}
+		postgresMock.On("list", mock.Anything, mock.Anything, (*listCursor)(nil), mock.Anything).Return(links, nil).Once()
+
+		page, err := serviceInstance.List(context.TODO(), filter)
+		if err != nil || len(page.Links) != 2 {
+			t.Fatalf("expect 2 links with no errors, got %v and %v", page.Links, err)
+		}
+
+		cursor, err := decodeCursor(page.NextCursor)
+		if err != nil || cursor.ID != "second" || !cursor.CreatedAt.Equal(createdAt) {
+			t.Errorf("expect the cursor to point at the second link, got %+v and %v", cursor, err)
+		}
+
+		postgresMock.AssertExpectations(t)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		initializeServiceInstance()
+
+		links := []entities.Link{{ID: "first", CreatedAt: createdAt}}
+		cursor := encodeCursor(listCursor{CreatedAt: createdAt, ID: "zero"})
+		postgresMock.On("list", mock.Anything, mock.MatchedBy(func(filter ListFilter) bool {
+			return filter.Limit == defaultListLimit && filter.Sort == ListSortNewest
+		}), &listCursor{CreatedAt: createdAt, ID: "zero"}, mock.Anything).Return(links, nil).Once()
+
+		page, err := serviceInstance.List(context.TODO(), ListFilter{OwnerID: sampleOwner, Cursor: cursor})
+		if err != nil || len(page.Links) != 1 || len(page.NextCursor) != 0 {
+			t.Errorf("expect a single link without the next cursor, got %+v and %v", page, err)
+		}
+
+		postgresMock.AssertExpectations(t)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		initializeServiceInstance()
+
+		_, err := serviceInstance.List(context.TODO(), ListFilter{OwnerID: sampleOwner, Cursor: "not a cursor"})
+		if !errors.Is(err, ErrCursorInvalid) {
+			t.Errorf("expect ErrCursorInvalid error %v", err)
+		}
+	})
+
+	t.Run("invalid date range", func(t *testing.T) {
+		initializeServiceInstance()
+
+		before := createdAt.Add(-time.Hour)
+		_, err := serviceInstance.List(context.TODO(), ListFilter{OwnerID: sampleOwner, CreatedAfter: &createdAt, CreatedBefore: &before})
+		if !errors.Is(err, ErrListFilterInvalid) {
+			t.Errorf("expect ErrListFilterInvalid error %v", err)
+		}
+	})
+}
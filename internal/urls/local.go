@@ -0,0 +1,211 @@
+package urls
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+type LocalCacheConfig struct {
+	Enabled bool `default:"false"`
+	// Size is the maximum number of links kept in the memory of each instance
+	Size int `default:"10000"`
+	// TTL is the maximum duration a link is kept in the memory, it bounds how long a missed invalidation is served
+	TTL time.Duration `default:"10s"`
+	// Channel is the redis pub/sub channel the invalidated links are broadcast on
+	Channel string `default:"fesghel:urls:invalidations"`
+}
+
+const (
+	cacheTierLocal = "local"
+	cacheTierRedis = "redis"
+
+	cacheResultHit      = "hit"
+	cacheResultMiss     = "miss"
+	cacheResultEviction = "eviction"
+	cacheResultNegative = "negative_hit" // the link is known not to exist
+)
+
+// lru is a size-bounded cache of the links, the least recently used link is evicted once it's full
+type lru struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // the front is the most recently used entry
+}
+
+type lruEntry struct {
+	key         string
+	link        entities.Link
+	cachedUntil time.Time // when the link expires on redis
+	expiresAt   time.Time
+}
+
+func newLRU(capacity int) *lru {
+	return &lru{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// get returns the link of the key, the expired entries are removed instead
+func (c *lru) get(key string, now time.Time) (lruEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return lruEntry{}, false
+	}
+
+	entry := element.Value.(*lruEntry)
+	if !now.Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return lruEntry{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return *entry, true
+}
+
+// set stores the entry, it returns the number of the evicted entries
+func (c *lru) set(entry lruEntry) (evicted int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = &entry
+		c.order.MoveToFront(element)
+		return 0
+	}
+
+	c.entries[entry.key] = c.order.PushFront(&entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+		evicted++
+	}
+	return evicted
+}
+
+func (c *lru) remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// tieredCache keeps the hot links in the memory of the instance in front of redis.
+// The invalidations are broadcast via redis pub/sub, so all of the instances drop the changed links.
+type tieredCache struct {
+	config  *LocalCacheConfig
+	logger  *zap.Logger
+	counter metrics_pkg.Counter
+	local   *lru
+	redis   Redis
+}
+
+func newTieredCache(cfg *LocalCacheConfig, l *zap.Logger, c metrics_pkg.Counter, r Redis) *tieredCache {
+	return &tieredCache{
+		config:  cfg,
+		logger:  l,
+		counter: c,
+		local:   newLRU(cfg.Size),
+		redis:   r,
+	}
+}
+
+func (c *tieredCache) insert(ctx context.Context, link entities.Link, expiration time.Duration) error {
+	return c.redis.insert(ctx, link, expiration)
+}
+
+func (c *tieredCache) insertBatch(ctx context.Context, links []entities.Link, expirations []time.Duration) error {
+	return c.redis.insertBatch(ctx, links, expirations)
+}
+
+func (c *tieredCache) insertMissing(ctx context.Context, domain, id string, expiration time.Duration) error {
+	return c.redis.insertMissing(ctx, domain, id, expiration)
+}
+
+// retrieve returns the link from the memory, redis is asked on a miss and its link is kept in the memory
+func (c *tieredCache) retrieve(ctx context.Context, domain, id string) (entities.Link, time.Time, error) {
+	key, now := linkKey(domain, id), time.Now()
+	if entry, ok := c.local.get(key, now); ok {
+		c.counter.IncrementVector(cacheTierLocal, cacheResultHit)
+		return entry.link, entry.cachedUntil, nil
+	}
+	c.counter.IncrementVector(cacheTierLocal, cacheResultMiss)
+
+	link, cachedUntil, err := c.redis.retrieve(ctx, domain, id)
+	if err != nil {
+		if errors.Is(err, errIDNotFound) {
+			c.counter.IncrementVector(cacheTierRedis, cacheResultMiss)
+		}
+		return entities.Link{}, time.Time{}, err
+	}
+	c.counter.IncrementVector(cacheTierRedis, cacheResultHit)
+
+	expiresAt := now.Add(c.config.TTL)
+	if link.ExpiresAt != nil && link.ExpiresAt.Before(expiresAt) {
+		expiresAt = *link.ExpiresAt
+	}
+
+	// the key is kept by the cache, so it shouldn't alias the buffer of the request (e.g. the fiber params)
+	entry := lruEntry{key: strings.Clone(key), link: link, cachedUntil: cachedUntil, expiresAt: expiresAt}
+	for range c.local.set(entry) {
+		c.counter.IncrementVector(cacheTierLocal, cacheResultEviction)
+	}
+	return link, cachedUntil, nil
+}
+
+// invalidate removes the link from both of the tiers, then asks the other instances to drop it as well
+func (c *tieredCache) invalidate(ctx context.Context, domain, id string) error {
+	key := linkKey(domain, id)
+	c.local.remove(key)
+
+	if err := c.redis.invalidate(ctx, domain, id); err != nil {
+		return err
+	}
+
+	if err := c.redis.publish(ctx, c.config.Channel, key); err != nil {
+		// the other instances drop the link once its ttl is passed
+		c.logger.Warn("error broadcasting the invalidation", zap.String("key", key), zap.Error(err))
+	}
+	return nil
+}
+
+func (c *tieredCache) publish(ctx context.Context, channel, message string) error {
+	return c.redis.publish(ctx, channel, message)
+}
+
+func (c *tieredCache) subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	return c.redis.subscribe(ctx, channel)
+}
+
+// listen drops the links invalidated by the other instances until the context is done
+func (c *tieredCache) listen(ctx context.Context, invalidations <-chan string) {
+	for key := range invalidations {
+		if key == subscriptionGap {
+			continue // the invalidations missed meanwhile are covered by the ttl
+		}
+		c.local.remove(key)
+	}
+
+	if ctx.Err() == nil {
+		c.logger.Error("the invalidations channel has been closed, the local cache relies on its ttl")
+	}
+}
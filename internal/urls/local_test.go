@@ -0,0 +1,154 @@
+package urls
+
+import (
+	"context"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+func newTieredCacheInstance(redis Redis) *tieredCache {
+	cfg := LocalCacheConfig{Size: 2, TTL: time.Minute, Channel: "invalidations"}
+	return newTieredCache(&cfg, zap.NewNop(), metrics_pkg.RegisterCounterNoop(), redis)
+}
+
+func TestLRU(t *testing.T) {
+	var (
+		now   = time.Now()
+		later = now.Add(time.Minute)
+	)
+
+	t.Run("evict the least recently used", func(t *testing.T) {
+		cache := newLRU(2)
+		assert.Zero(t, cache.set(lruEntry{key: "a", expiresAt: later}))
+		assert.Zero(t, cache.set(lruEntry{key: "b", expiresAt: later}))
+
+		_, ok := cache.get("a", now) // a is used more recently than b now
+		assert.True(t, ok)
+
+		assert.Equal(t, 1, cache.set(lruEntry{key: "c", expiresAt: later}))
+		_, ok = cache.get("b", now)
+		assert.False(t, ok, "expect b to be evicted")
+		_, ok = cache.get("a", now)
+		assert.True(t, ok)
+	})
+
+	t.Run("expired entries", func(t *testing.T) {
+		cache := newLRU(2)
+		cache.set(lruEntry{key: "a", expiresAt: later})
+
+		_, ok := cache.get("a", later)
+		assert.False(t, ok, "expect the expired entry not to be returned")
+		assert.Zero(t, cache.order.Len())
+	})
+}
+
+func TestTieredCacheRetrieve(t *testing.T) {
+	var (
+		id   = "spring"
+		link = entities.Link{ID: id, URL: "https://example.com"}
+	)
+
+	initializeServiceInstance()
+	cache := newTieredCacheInstance(redisMock)
+
+	cachedUntil := time.Now().Add(cacheTTL)
+	redisMock.On("retrieve", mock.Anything, "", id).Return(link, cachedUntil, nil).Once()
+
+	for range 3 { // only the first retrieval reaches redis
+		retrieved, until, err := cache.retrieve(context.TODO(), "", id)
+		assert.NoError(t, err)
+		assert.Equal(t, link, retrieved)
+		assert.Equal(t, cachedUntil, until)
+	}
+	redisMock.AssertExpectations(t)
+
+	t.Run("capped by the expiration of the link", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Second)
+		redisMock.
+			On("retrieve", mock.Anything, "", "expiring").
+			Return(entities.Link{ID: "expiring", URL: "https://example.com", ExpiresAt: &expiresAt}, time.Time{}, nil).Twice()
+
+		for range 2 {
+			_, _, err := cache.retrieve(context.TODO(), "", "expiring")
+			assert.NoError(t, err)
+		}
+		redisMock.AssertExpectations(t)
+	})
+}
+
+func TestTieredCacheInvalidate(t *testing.T) {
+	var (
+		id   = "spring"
+		link = entities.Link{ID: id, URL: "https://example.com"}
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// two instances sharing the same redis
+	first, second := newTieredCacheInstance(redisInstance), newTieredCacheInstance(redisInstance)
+	for _, instance := range []*tieredCache{first, second} {
+		invalidations, err := redisInstance.subscribe(ctx, instance.config.Channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go instance.listen(ctx, invalidations)
+	}
+
+	if err := redisInstance.insert(context.TODO(), link, cacheTTL); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, instance := range []*tieredCache{first, second} {
+		if _, _, err := instance.retrieve(context.TODO(), "", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := first.invalidate(context.TODO(), "", id); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := first.local.get(id, time.Now())
+	assert.False(t, ok, "expect the link to be dropped by the invalidating instance")
+
+	assert.Eventually(t, func() bool {
+		_, ok := second.local.get(id, time.Now())
+		return !ok
+	}, time.Second, 10*time.Millisecond, "expect the link to be dropped by the other instance")
+}
+
+func TestTieredCacheRequestBuffer(t *testing.T) {
+	var (
+		id   = "buffered"
+		link = entities.Link{ID: id, URL: "https://example.com"}
+	)
+
+	if err := redisInstance.insert(context.TODO(), link, cacheTTL); err != nil {
+		t.Fatal(err)
+	}
+	cache := newTieredCacheInstance(redisInstance)
+
+	// the id aliases a buffer which is reused afterwards, the same as the params of fiber
+	buffer := []byte(id)
+	if _, _, err := cache.retrieve(context.TODO(), "", unsafe.String(&buffer[0], len(buffer))); err != nil {
+		t.Fatal(err)
+	}
+	copy(buffer, "reusedxx")
+
+	entry, ok := cache.local.get(id, time.Now())
+	if assert.True(t, ok, "expect the link to be kept by its own key") {
+		assert.Equal(t, id, entry.link.ID)
+	}
+
+	_, ok = cache.local.get("reusedxx", time.Now())
+	assert.False(t, ok)
+}
@@ -0,0 +1,125 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+var (
+	ErrUpdatingPostgres  = errors.New("error updating value of postgres")
+	ErrInvalidatingCache = errors.New("error invalidating the cache")
+	ErrNotOwner          = errors.New("error link is not owned by the caller")
+)
+
+// Authorize checks the ownership of the link, the links having no owner can't be managed by anyone
+func (s *service) Authorize(ctx context.Context, domain, id, ownerID string) error {
+	owner, err := s.postgres.owner(ctx, domain, id)
+	if err != nil {
+		if errors.Is(err, ErrIDNotExists) {
+			return ErrShortenIDNotExists
+		}
+		return errors.Join(ErrRetreivingDataFromDatabase, err)
+	}
+
+	if len(owner) == 0 || owner != ownerID {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// Update stores the new attributes of the link, then invalidates its cache
+func (s *service) Update(ctx context.Context, link entities.Link) (err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "update")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("update", status)
+	}(time.Now())
+
+	if err = s.validateDestination(&link); err != nil {
+		return err
+	}
+
+	if err = s.check(ctx, s.checker, link); err != nil {
+		return err
+	}
+
+	if err = s.postgres.update(ctx, link, time.Now()); err != nil {
+		return s.managementError(err)
+	}
+
+	return s.invalidate(ctx, link.Domain, link.ID)
+}
+
+func (s *service) Disable(ctx context.Context, domain, id string) error {
+	return s.setDisabled(ctx, domain, id, true)
+}
+
+func (s *service) Enable(ctx context.Context, domain, id string) error {
+	return s.setDisabled(ctx, domain, id, false)
+}
+
+func (s *service) setDisabled(ctx context.Context, domain, id string, disabled bool) (err error) {
+	method := "enable"
+	if disabled {
+		method = "disable"
+	}
+
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, method)
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector(method, status)
+	}(time.Now())
+
+	if err = s.postgres.setDisabled(ctx, domain, id, disabled, time.Now()); err != nil {
+		return s.managementError(err)
+	}
+
+	return s.invalidate(ctx, domain, id)
+}
+
+func (s *service) Delete(ctx context.Context, domain, id string) (err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "delete")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("delete", status)
+	}(time.Now())
+
+	if err = s.postgres.delete(ctx, domain, id, time.Now()); err != nil {
+		return s.managementError(err)
+	}
+
+	return s.invalidate(ctx, domain, id)
+}
+
+// managementError converts the errors of postgres while changing a link
+func (s *service) managementError(err error) error {
+	if errors.Is(err, ErrIDNotExists) {
+		return ErrShortenIDNotExists
+	}
+	return errors.Join(ErrUpdatingPostgres, err)
+}
+
+// invalidate removes the cache of the changed link, the change has been stored already
+// but the caller is informed since the stale link may be served until the cache expires.
+func (s *service) invalidate(ctx context.Context, domain, id string) error {
+	if err := s.redis.invalidate(ctx, domain, id); err != nil {
+		s.logger.Error("error invalidating the cache of the link", zap.String("domain", domain), zap.String("id", id), zap.Error(err))
+		return errors.Join(ErrInvalidatingCache, err)
+	}
+	return nil
+}
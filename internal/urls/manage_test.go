@@ -0,0 +1,181 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestServiceUpdate(t *testing.T) {
+	var (
+		id  = "spring"
+		url = "https://example.com/new"
+	)
+
+	t.Run("success", func(t *testing.T) {
+		initializeServiceInstance()
+
+		{ // prepare the mocks
+			postgresMock.
+				On("update", mock.Anything, linkMatcher(id, url), mock.Anything).
+				Return(nil).Once()
+
+			redisMock.
+				On("invalidate", mock.Anything, "", id).
+				Return(nil).Once()
+		}
+
+		err := serviceInstance.Update(context.TODO(), entities.Link{ID: id, URL: "HTTPS://Example.com/new"})
+		assert.NoError(t, err)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("invalid destination", func(t *testing.T) {
+		initializeServiceInstance()
+
+		err := serviceInstance.Update(context.TODO(), entities.Link{ID: id, URL: "ftp://example.com"})
+		assert.ErrorIs(t, err, entities.ErrURLSchemeNotAllowed)
+		postgresMock.AssertNotCalled(t, "update", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not exists", func(t *testing.T) {
+		initializeServiceInstance()
+
+		postgresMock.
+			On("update", mock.Anything, linkMatcher(id, url), mock.Anything).
+			Return(ErrIDNotExists).Once()
+
+		err := serviceInstance.Update(context.TODO(), entities.Link{ID: id, URL: entities.URL(url)})
+		assert.ErrorIs(t, err, ErrShortenIDNotExists)
+		redisMock.AssertNotCalled(t, "invalidate", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestServiceDisable(t *testing.T) {
+	var (
+		id = "spring"
+	)
+
+	t.Run("disable then retrieve", func(t *testing.T) {
+		initializeServiceInstance()
+
+		{ // prepare the mocks
+			postgresMock.
+				On("setDisabled", mock.Anything, "", id, true, mock.Anything).
+				Return(nil).Once()
+
+			redisMock.
+				On("invalidate", mock.Anything, "", id).
+				Return(nil).Once()
+
+			redisMock.
+				On("retrieve", mock.Anything, "", id).
+				Return(entities.Link{ID: id, URL: "https://example.com", Disabled: true}, time.Time{}, nil).Once()
+		}
+
+		assert.NoError(t, serviceInstance.Disable(context.TODO(), "", id))
+
+		_, err := serviceInstance.Retrieve(context.TODO(), "", id)
+		assert.ErrorIs(t, err, ErrShortenIDDisabled)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("enable", func(t *testing.T) {
+		initializeServiceInstance()
+
+		{ // prepare the mocks
+			postgresMock.
+				On("setDisabled", mock.Anything, "", id, false, mock.Anything).
+				Return(nil).Once()
+
+			redisMock.
+				On("invalidate", mock.Anything, "", id).
+				Return(errors.New("connection refused")).Once()
+		}
+
+		err := serviceInstance.Enable(context.TODO(), "", id)
+		assert.ErrorIs(t, err, ErrInvalidatingCache)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+}
+
+func TestServiceDelete(t *testing.T) {
+	var (
+		id = "spring"
+	)
+
+	t.Run("success", func(t *testing.T) {
+		initializeServiceInstance()
+
+		{ // prepare the mocks
+			postgresMock.
+				On("delete", mock.Anything, "", id, mock.Anything).
+				Return(nil).Once()
+
+			redisMock.
+				On("invalidate", mock.Anything, "", id).
+				Return(nil).Once()
+		}
+
+		assert.NoError(t, serviceInstance.Delete(context.TODO(), "", id))
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("postgres error", func(t *testing.T) {
+		initializeServiceInstance()
+
+		postgresMock.
+			On("delete", mock.Anything, "", id, mock.Anything).
+			Return(errUpdatingURL).Once()
+
+		err := serviceInstance.Delete(context.TODO(), "", id)
+		assert.ErrorIs(t, err, ErrUpdatingPostgres)
+		postgresMock.AssertExpectations(t)
+	})
+}
+
+func TestServiceAuthorize(t *testing.T) {
+	var (
+		id = "spring"
+	)
+
+	cases := map[string]struct {
+		owner    string
+		err      error
+		expected error
+	}{
+		"owner":          {owner: "acme", expected: nil},
+		"another owner":  {owner: "globex", expected: ErrNotOwner},
+		"no owner":       {owner: "", expected: ErrNotOwner},
+		"not exists":     {err: ErrIDNotExists, expected: ErrShortenIDNotExists},
+		"postgres error": {err: ErrRetreivingValue, expected: ErrRetreivingDataFromDatabase},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			initializeServiceInstance()
+
+			postgresMock.
+				On("owner", mock.Anything, "", id).
+				Return(testCase.owner, testCase.err).Once()
+
+			err := serviceInstance.Authorize(context.TODO(), "", id, "acme")
+			if testCase.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, testCase.expected)
+			}
+			postgresMock.AssertExpectations(t)
+		})
+	}
+}
@@ -8,8 +8,11 @@ import (
 )
 
 type metrics struct {
-	Counter   metrics_pkg.Counter
-	Histogram metrics_pkg.Histogram
+	Counter      metrics_pkg.Counter
+	Histogram    metrics_pkg.Histogram
+	KeyPoolDepth metrics_pkg.Gauge
+	Cache        metrics_pkg.Counter
+	Bloom        metrics_pkg.Gauge
 }
 
 func newMetrics() (m *metrics, err error) {
@@ -30,12 +33,36 @@ func newMetrics() (m *metrics, err error) {
 		return nil, fmt.Errorf("error while registering histogram vector: %v", err)
 	}
 
+	keyPoolDepthName := prefix + "_key_pool_depth"
+	keyPoolDepthLabels := []string{"tier"}
+	m.KeyPoolDepth, err = metrics_pkg.RegisterGauge(keyPoolDepthName, entities.Namespace, entities.System, keyPoolDepthLabels)
+	if err != nil {
+		return nil, fmt.Errorf("error while registering gauge vector: %v", err)
+	}
+
+	cacheName := prefix + "_cache_counter"
+	cacheLabels := []string{"tier", "result"}
+	m.Cache, err = metrics_pkg.RegisterCounter(cacheName, entities.Namespace, entities.System, cacheLabels)
+	if err != nil {
+		return nil, fmt.Errorf("error while registering counter vector: %v", err)
+	}
+
+	bloomName := prefix + "_bloom_filter"
+	bloomLabels := []string{"measure"}
+	m.Bloom, err = metrics_pkg.RegisterGauge(bloomName, entities.Namespace, entities.System, bloomLabels)
+	if err != nil {
+		return nil, fmt.Errorf("error while registering gauge vector: %v", err)
+	}
+
 	return m, nil
 }
 
 func newMetricsNoop() *metrics {
 	return &metrics{
-		Counter:   metrics_pkg.RegisterCounterNoop(),
-		Histogram: metrics_pkg.RegisterHistogramNoop(),
+		Counter:      metrics_pkg.RegisterCounterNoop(),
+		Histogram:    metrics_pkg.RegisterHistogramNoop(),
+		KeyPoolDepth: metrics_pkg.RegisterGaugeNoop(),
+		Cache:        metrics_pkg.RegisterCounterNoop(),
+		Bloom:        metrics_pkg.RegisterGaugeNoop(),
 	}
 }
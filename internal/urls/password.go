@@ -0,0 +1,74 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+const (
+	passwordMinLength = 4
+	passwordMaxLength = 72 // bytes, bcrypt ignores the rest
+)
+
+var (
+	ErrPasswordInvalid   = errors.New("error password should be 4 to 72 bytes")
+	ErrPasswordIncorrect = errors.New("error password is incorrect")
+	ErrHashingPassword   = errors.New("error hashing the password")
+)
+
+// hashPassword replaces the plain password of the link (when given) by its bcrypt hash
+func (s *service) hashPassword(link *entities.Link) error {
+	if len(link.Password) == 0 {
+		return nil
+	}
+
+	if len(link.Password) > passwordMaxLength || utf8.RuneCountInString(link.Password) < passwordMinLength {
+		return ErrPasswordInvalid
+	}
+
+	cost := s.config.PasswordCost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(link.Password), cost)
+	if err != nil {
+		return errors.Join(ErrHashingPassword, err)
+	}
+
+	link.Password, link.PasswordHash = "", string(hash)
+	return nil
+}
+
+// Unlock retrieves the protected link, then returns it only when the given password matches
+func (s *service) Unlock(ctx context.Context, domain, id, password string) (link entities.Link, err error) {
+	defer func(start time.Time) {
+		var status = metrics_pkg.StatusFailure
+		if err == nil {
+			s.metrics.Histogram.ObserveResponseTime(start, "unlock")
+			status = metrics_pkg.StatusSuccess
+		}
+		s.metrics.Counter.IncrementVector("unlock", status)
+	}(time.Now())
+
+	if link, err = s.Retrieve(ctx, domain, id); err != nil {
+		return entities.Link{}, err
+	}
+
+	if !link.Protected() {
+		return link, nil
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+		return entities.Link{}, ErrPasswordIncorrect
+	}
+
+	return link, nil
+}
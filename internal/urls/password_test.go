@@ -0,0 +1,94 @@
+package urls
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+)
+
+func TestHashPassword(t *testing.T) {
+	serviceInstance.config.PasswordCost = bcrypt.MinCost
+	defer func() { serviceInstance.config.PasswordCost = 0 }()
+
+	t.Run("hashed", func(t *testing.T) {
+		link := entities.Link{Password: "s3cret"}
+		assert.NoError(t, serviceInstance.hashPassword(&link))
+		assert.Empty(t, link.Password)
+		assert.True(t, link.Protected())
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte("s3cret")))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, password := range []string{"abc", string(make([]byte, 73))} {
+			link := entities.Link{Password: password}
+			assert.ErrorIs(t, serviceInstance.hashPassword(&link), ErrPasswordInvalid)
+		}
+	})
+}
+
+func TestServiceUnlock(t *testing.T) {
+	var (
+		sampleID = "secret"
+		url      = "https://example.com/internal"
+	)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("error hashing the password %v", err)
+	}
+	protected := entities.Link{ID: sampleID, URL: entities.URL(url), PasswordHash: string(hash)}
+
+	for name, testCase := range map[string]struct {
+		password string
+		err      error
+	}{
+		"correct password":   {password: "s3cret"},
+		"incorrect password": {password: "guess", err: ErrPasswordIncorrect},
+	} {
+		t.Run(name, func(t *testing.T) {
+			initializeServiceInstance()
+
+			redisMock.
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(protected, time.Time{}, nil).Once()
+
+			link, err := serviceInstance.Unlock(context.TODO(), "", sampleID, testCase.password)
+			if testCase.err != nil {
+				assert.ErrorIs(t, err, testCase.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, url, string(link.URL))
+		})
+	}
+}
+
+func TestServiceShortenProtected(t *testing.T) {
+	serviceInstance.config.Deduplicate, serviceInstance.config.PasswordCost = true, bcrypt.MinCost
+	defer func() { serviceInstance.config.Deduplicate, serviceInstance.config.PasswordCost = false, 0 }()
+
+	initializeServiceInstance()
+
+	{ // prepare the mocks, the protected links are never deduplicated
+		postgresMock.
+			On("insert", mock.Anything, mock.MatchedBy(func(link entities.Link) bool {
+				return link.Protected() && len(link.Password) == 0
+			}), mock.Anything).
+			Return(nil).Once()
+
+		redisMock.
+			On("insert", mock.Anything, mock.Anything, mock.Anything).
+			Return(nil).Once()
+	}
+
+	_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: "https://example.com/internal", Password: "s3cret"})
+	assert.NoError(t, err)
+	postgresMock.AssertNotCalled(t, "lookup", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	postgresMock.AssertExpectations(t)
+}
@@ -0,0 +1,132 @@
+package urls
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+type KeyPoolConfig struct {
+	Enabled bool `default:"false"`
+	// BufferSize is the number of keys each instance claims from the table into its memory
+	BufferSize int `default:"1000" split_words:"true"`
+	// TableMinimum is the depth of the pool table that triggers generating a new batch of keys
+	TableMinimum int64 `default:"10000" split_words:"true"`
+	// BatchSize is the number of keys generated on each refill of the pool table
+	BatchSize      int               `default:"5000" split_words:"true"`
+	RefillInterval entities.Interval `default:"10s" split_words:"true"`
+}
+
+const keyPoolRefillTimeout = 30 * time.Second
+
+// keyPool pre-allocates batches of unused keys into the pool table, then each
+// instance claims a part of them into its memory so shortening needs no key generation.
+// The claimed keys are removed from the table, so the instances never share a key.
+type keyPool struct {
+	config    *KeyPoolConfig
+	logger    *zap.Logger
+	gauge     metrics_pkg.Gauge
+	postgres  Postgres
+	generator KeyGenerator
+
+	keys   chan string
+	refill chan struct{}
+}
+
+func newKeyPool(cfg *KeyPoolConfig, l *zap.Logger, g metrics_pkg.Gauge, p Postgres, kg KeyGenerator) *keyPool {
+	return &keyPool{
+		config:    cfg,
+		logger:    l,
+		gauge:     g,
+		postgres:  p,
+		generator: kg,
+		keys:      make(chan string, cfg.BufferSize),
+		refill:    make(chan struct{}, 1),
+	}
+}
+
+// claim returns a key of the memory buffer, false is returned when the buffer is exhausted
+func (p *keyPool) claim() (string, bool) {
+	select {
+	case key := <-p.keys:
+		if len(p.keys) < cap(p.keys)/2 {
+			p.triggerRefill()
+		}
+		return key, true
+	default:
+		p.triggerRefill()
+		return "", false
+	}
+}
+
+func (p *keyPool) triggerRefill() {
+	select {
+	case p.refill <- struct{}{}:
+	default: // a refill is already pending
+	}
+}
+
+// run refills the pool periodically or whenever the memory buffer runs low, until the context is done
+func (p *keyPool) run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.RefillInterval.Duration())
+	defer ticker.Stop()
+
+	for {
+		p.fill(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.refill:
+		}
+	}
+}
+
+func (p *keyPool) fill(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, keyPoolRefillTimeout)
+	defer cancel()
+
+	depth, err := p.postgres.poolDepth(ctx)
+	if err != nil {
+		p.logger.Error("error retrieving the key pool depth", zap.Error(err))
+		return
+	}
+
+	if depth < p.config.TableMinimum {
+		keys := make([]string, 0, p.config.BatchSize)
+		for range p.config.BatchSize {
+			key, err := p.generator.Generate(ctx, "")
+			if err != nil {
+				p.logger.Error("error generating key for the key pool", zap.Error(err))
+				break
+			}
+			keys = append(keys, key)
+		}
+
+		inserted, err := p.postgres.fillPool(ctx, keys)
+		if err != nil {
+			p.logger.Error("error filling the key pool", zap.Error(err))
+		}
+		depth += inserted
+	}
+
+	if missing := cap(p.keys) - len(p.keys); missing > 0 {
+		keys, err := p.postgres.claimPool(ctx, missing)
+		if err != nil {
+			p.logger.Error("error claiming keys from the key pool", zap.Error(err))
+		}
+
+		for _, key := range keys {
+			p.keys <- key // never blocks, there is only one producer
+		}
+		depth -= int64(len(keys))
+	}
+
+	p.gauge.Set(float64(depth), "table")
+	p.gauge.Set(float64(len(p.keys)), "memory")
+}
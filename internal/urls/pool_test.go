@@ -0,0 +1,146 @@
+package urls
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/mohammadne/fesghel/internal/entities"
+	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
+)
+
+func newKeyPoolInstance(postgres Postgres) *keyPool {
+	cfg := KeyPoolConfig{BufferSize: 4, TableMinimum: 10, BatchSize: 8, RefillInterval: entities.Interval(time.Hour)}
+	return newKeyPool(&cfg, zap.NewNop(), metrics_pkg.RegisterGaugeNoop(), postgres, &randomKeyGenerator{length: 6})
+}
+
+func TestKeyPoolFill(t *testing.T) {
+	t.Run("refill the table below minimum", func(t *testing.T) {
+		initializeServiceInstance()
+		pool := newKeyPoolInstance(postgresMock)
+
+		{ // prepare the mocks
+			postgresMock.On("poolDepth", mock.Anything).Return(int64(2), nil).Once()
+			postgresMock.
+				On("fillPool", mock.Anything, mock.MatchedBy(func(keys []string) bool { return len(keys) == 8 })).
+				Return(int64(8), nil).Once()
+			postgresMock.On("claimPool", mock.Anything, 4).Return([]string{"a", "b", "c", "d"}, nil).Once()
+		}
+
+		pool.fill(context.TODO())
+		assert.Len(t, pool.keys, 4)
+		postgresMock.AssertExpectations(t)
+	})
+
+	t.Run("only claim when the table is deep enough", func(t *testing.T) {
+		initializeServiceInstance()
+		pool := newKeyPoolInstance(postgresMock)
+		pool.keys <- "a"
+
+		{ // prepare the mocks
+			postgresMock.On("poolDepth", mock.Anything).Return(int64(100), nil).Once()
+			postgresMock.On("claimPool", mock.Anything, 3).Return([]string{"b", "c", "d"}, nil).Once()
+		}
+
+		pool.fill(context.TODO())
+		assert.Len(t, pool.keys, 4)
+		postgresMock.AssertExpectations(t)
+	})
+}
+
+func TestKeyPoolClaim(t *testing.T) {
+	pool := newKeyPoolInstance(nil)
+
+	_, ok := pool.claim()
+	assert.False(t, ok, "expect exhausted pool")
+	assert.Len(t, pool.refill, 1, "expect a refill to be triggered")
+
+	pool.keys <- "abcd"
+	key, ok := pool.claim()
+	assert.True(t, ok)
+	assert.Equal(t, "abcd", key)
+}
+
+func TestServiceShortenWithKeyPool(t *testing.T) {
+	url := "https://example.com"
+
+	t.Run("claimed key", func(t *testing.T) {
+		initializeServiceInstance()
+		serviceInstance.keyPool = newKeyPoolInstance(postgresMock)
+		defer func() { serviceInstance.keyPool = nil }()
+		serviceInstance.keyPool.keys <- "pooled"
+
+		{ // prepare the mocks
+			postgresMock.
+				On("insert", mock.Anything, linkMatcher("pooled", url), mock.Anything).
+				Return(nil).Once()
+
+			redisMock.
+				On("insert", mock.Anything, linkMatcher("pooled", url), serviceInstance.config.CacheExpiration).
+				Return(nil).Once()
+		}
+
+		id, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url)})
+		assert.NoError(t, err)
+		assert.Equal(t, "pooled", id)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("fallback on exhausted pool", func(t *testing.T) {
+		initializeServiceInstance()
+		serviceInstance.keyPool = newKeyPoolInstance(postgresMock)
+		defer func() { serviceInstance.keyPool = nil }()
+
+		{ // prepare the mocks
+			postgresMock.
+				On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
+				Return(nil).Once()
+
+			redisMock.
+				On("insert", mock.Anything, linkMatcher("", url), serviceInstance.config.CacheExpiration).
+				Return(nil).Once()
+		}
+
+		id, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url)})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("retry on a taken key", func(t *testing.T) {
+		initializeServiceInstance()
+		serviceInstance.keyPool = newKeyPoolInstance(postgresMock)
+		defer func() { serviceInstance.keyPool = nil }()
+		serviceInstance.keyPool.keys <- "taken"
+		serviceInstance.keyPool.keys <- "free"
+
+		{ // prepare the mocks
+			postgresMock.
+				On("insert", mock.Anything, linkMatcher("taken", url), mock.Anything).
+				Return(errUniqueConstraintViolated).Once()
+
+			postgresMock.
+				On("insert", mock.Anything, linkMatcher("free", url), mock.Anything).
+				Return(nil).Once()
+
+			redisMock.
+				On("insert", mock.Anything, linkMatcher("free", url), serviceInstance.config.CacheExpiration).
+				Return(nil).Once()
+		}
+
+		id, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url)})
+		if errors.Is(err, ErrMaxRetriesForCollision) {
+			t.Fatal("expect the second pooled key to be used")
+		}
+		assert.Equal(t, "free", id)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+}
@@ -3,7 +3,10 @@ package urls
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -14,8 +17,31 @@ import (
 )
 
 type Postgres interface {
-	insert(ctx context.Context, id, url string, timestamp time.Time) (err error)
-	retrieve(ctx context.Context, id string) (url string, err error)
+	insert(ctx context.Context, link entities.Link, timestamp time.Time) (err error)
+	insertBatch(ctx context.Context, links []entities.Link, timestamp time.Time) (inserted map[string]struct{}, err error)
+	retrieve(ctx context.Context, domain, id string) (link entities.Link, err error)
+	update(ctx context.Context, link entities.Link, timestamp time.Time) (err error)
+	setDisabled(ctx context.Context, domain, id string, disabled bool, timestamp time.Time) (err error)
+	delete(ctx context.Context, domain, id string, timestamp time.Time) (err error)
+	lookup(ctx context.Context, domain, urlHash, ownerID string) (id string, err error)
+	owner(ctx context.Context, domain, id string) (ownerID string, err error)
+	list(ctx context.Context, filter ListFilter, cursor *listCursor, now time.Time) (links []entities.Link, err error)
+	nextSequence(ctx context.Context) (value int64, err error)
+
+	poolDepth(ctx context.Context) (depth int64, err error)
+	fillPool(ctx context.Context, keys []string) (inserted int64, err error)
+	claimPool(ctx context.Context, count int) (keys []string, err error)
+
+	blocklist(ctx context.Context) (rules []blockRule, err error)
+
+	countLinks(ctx context.Context) (count int64, err error)
+	linkKeys(ctx context.Context, visit func(key string)) (err error)
+
+	insertDomain(ctx context.Context, domain entities.Domain) (err error)
+	domain(ctx context.Context, host string) (domain entities.Domain, err error)
+	domains(ctx context.Context, ownerID string) (domains []entities.Domain, err error)
+	updateDomain(ctx context.Context, domain entities.Domain) (updated entities.Domain, err error)
+	deleteDomain(ctx context.Context, host string) (err error)
 }
 
 type postgres struct {
@@ -36,12 +62,27 @@ var (
 )
 
 const (
+	// the link and its tags are inserted via a single statement
 	queryInsert = `
-	INSERT INTO urls (id, url, created_at)
-	VALUES ($1, $2, $3)`
+	WITH link AS (
+		INSERT INTO urls (id, url, url_hash, expires_at, redirect, created_at, owner_id, campaign, title, metadata, domain,
+			password_hash)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $12, NULLIF($13, ''))
+		RETURNING domain, id
+	)
+	INSERT INTO url_tags (domain, url_id, tag)
+	SELECT link.domain, link.id, tag FROM link, unnest($11::VARCHAR[]) AS tag`
 )
 
-func (s *postgres) insert(ctx context.Context, id, url string, timestamp time.Time) (err error) {
+// metadataArgument passes the metadata as a string, so it's parsed as jsonb (or NULL when not given)
+func metadataArgument(metadata json.RawMessage) any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return string(metadata)
+}
+
+func (s *postgres) insert(ctx context.Context, link entities.Link, timestamp time.Time) (err error) {
 	defer func(start time.Time) {
 		if err != nil {
 			s.instance.Vectors.Counter.IncrementVector("urls", "insert", metrics_pkg.StatusFailure)
@@ -51,7 +92,8 @@ func (s *postgres) insert(ctx context.Context, id, url string, timestamp time.Ti
 		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "insert")
 	}(time.Now())
 
-	_, err = s.instance.ExecContext(ctx, queryInsert, id, url, timestamp)
+	_, err = s.instance.ExecContext(ctx, queryInsert, link.ID, string(link.URL), hashURL(link.URL), link.ExpiresAt, int(link.Redirect),
+		timestamp, link.OwnerID, link.Campaign, link.Title, metadataArgument(link.Metadata), pq.Array(link.Tags), link.Domain, link.PasswordHash)
 	if err != nil {
 		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
 			return errUniqueConstraintViolated
@@ -62,6 +104,83 @@ func (s *postgres) insert(ctx context.Context, id, url string, timestamp time.Ti
 	return nil
 }
 
+const (
+	queryInsertBatch = `
+	WITH inserted AS (
+		INSERT INTO urls (id, url, url_hash, expires_at, redirect, created_at, owner_id, campaign, title, metadata, domain,
+			password_hash)
+		VALUES `
+	// only the tags of the inserted links are inserted, the tags are given as parallel arrays of domains, ids and tags
+	queryInsertBatchConflict = `
+		ON CONFLICT (domain, id) DO NOTHING
+		RETURNING domain, id
+	), tagged AS (
+		INSERT INTO url_tags (domain, url_id, tag)
+		SELECT tags.domain, tags.url_id, tags.tag
+		FROM unnest($%d::VARCHAR[], $%d::VARCHAR[], $%d::VARCHAR[]) AS tags (domain, url_id, tag)
+		WHERE (tags.domain, tags.url_id) IN (SELECT domain, id FROM inserted)
+	)
+	SELECT domain, id FROM inserted`
+	insertBatchColumns = 12
+)
+
+// maxInsertBatch keeps the batch insert below the 65535 parameters limit of postgres, 3 of them are the tag arrays
+const maxInsertBatch = (65535 - 3) / insertBatchColumns
+
+// insertBatch stores all of the links via a single multi-row statement,
+// the keys (see linkKey) of the inserted links are returned and the taken ones are skipped.
+func (s *postgres) insertBatch(ctx context.Context, links []entities.Link, timestamp time.Time) (inserted map[string]struct{}, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("urls", "insert_batch", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("urls", "insert_batch", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "insert_batch")
+	}(time.Now())
+
+	inserted = make(map[string]struct{}, len(links))
+	if len(links) == 0 {
+		return inserted, nil
+	}
+
+	var query strings.Builder
+	query.WriteString(queryInsertBatch)
+	args := make([]any, 0, len(links)*insertBatchColumns+3)
+	var tagDomains, tagIDs, tags []string
+
+	for index, link := range links {
+		if index > 0 {
+			query.WriteString(", ")
+		}
+		base := index * insertBatchColumns
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, NULLIF($%d, 0), $%d, NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), $%d, $%d, "+
+			"NULLIF($%d, ''))", base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10, base+11, base+12)
+		args = append(args, link.ID, string(link.URL), hashURL(link.URL), link.ExpiresAt, int(link.Redirect), timestamp,
+			link.OwnerID, link.Campaign, link.Title, metadataArgument(link.Metadata), link.Domain, link.PasswordHash)
+
+		for _, tag := range link.Tags {
+			tagDomains, tagIDs, tags = append(tagDomains, link.Domain), append(tagIDs, link.ID), append(tags, tag)
+		}
+	}
+	fmt.Fprintf(&query, queryInsertBatchConflict, len(args)+1, len(args)+2, len(args)+3)
+	args = append(args, pq.Array(tagDomains), pq.Array(tagIDs), pq.Array(tags))
+
+	rows := make([]struct {
+		Domain string `db:"domain"`
+		ID     string `db:"id"`
+	}, 0, len(links))
+	if err = s.instance.SelectContext(ctx, &rows, query.String(), args...); err != nil {
+		return nil, errors.Join(errInsertingURL, err)
+	}
+
+	for _, row := range rows {
+		inserted[linkKey(row.Domain, row.ID)] = struct{}{}
+	}
+
+	return inserted, nil
+}
+
 var (
 	ErrIDNotExists     = errors.New("error id not exists")
 	ErrRetreivingValue = errors.New("error retreiving url")
@@ -69,12 +188,14 @@ var (
 
 const (
 	queryRetrieve = `
-	SELECT url
+	SELECT url, expires_at, COALESCE(redirect, 0), disabled, COALESCE(owner_id, ''),
+		COALESCE(campaign, ''), COALESCE(title, ''), metadata, COALESCE(password_hash, ''),
+		ARRAY(SELECT tag FROM url_tags WHERE url_tags.domain = urls.domain AND url_tags.url_id = urls.id ORDER BY tag)
 	FROM urls
-	WHERE id = $1`
+	WHERE domain = $1 AND id = $2 AND deleted_at IS NULL`
 )
 
-func (s *postgres) retrieve(ctx context.Context, id string) (url string, err error) {
+func (s *postgres) retrieve(ctx context.Context, domain, id string) (link entities.Link, err error) {
 	defer func(start time.Time) {
 		if err != nil {
 			s.instance.Vectors.Counter.IncrementVector("urls", "retrieve", metrics_pkg.StatusFailure)
@@ -84,7 +205,46 @@ func (s *postgres) retrieve(ctx context.Context, id string) (url string, err err
 		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "retrieve")
 	}(time.Now())
 
-	err = s.instance.QueryRowContext(ctx, queryRetrieve, id).Scan(&url)
+	link.ID, link.Domain = id, domain
+	var metadata []byte
+	err = s.instance.QueryRowContext(ctx, queryRetrieve, domain, id).Scan(&link.URL, &link.ExpiresAt, &link.Redirect, &link.Disabled,
+		&link.OwnerID, &link.Campaign, &link.Title, &metadata, &link.PasswordHash, pq.Array(&link.Tags))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entities.Link{}, ErrIDNotExists
+		}
+		return entities.Link{}, errors.Join(ErrRetreivingValue, err)
+	}
+	link.Metadata = metadata
+
+	return link, nil
+}
+
+const (
+	queryLookup = `
+	SELECT id
+	FROM urls
+	WHERE url_hash = $1 AND deleted_at IS NULL AND NOT disabled AND COALESCE(owner_id, '') = $2 AND domain = $3
+		AND expires_at IS NULL AND redirect IS NULL AND password_hash IS NULL
+		AND campaign IS NULL AND title IS NULL AND metadata IS NULL
+		AND NOT EXISTS (SELECT 1 FROM url_tags WHERE url_tags.domain = urls.domain AND url_tags.url_id = urls.id)
+	ORDER BY created_at
+	LIMIT 1`
+)
+
+// lookup returns the oldest plain (having no attribute but the url), enabled and not deleted id of the given url hash
+// owned by the owner on the domain
+func (s *postgres) lookup(ctx context.Context, domain, urlHash, ownerID string) (id string, err error) {
+	defer func(start time.Time) {
+		if err != nil && !errors.Is(err, ErrIDNotExists) {
+			s.instance.Vectors.Counter.IncrementVector("urls", "lookup", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("urls", "lookup", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "lookup")
+	}(time.Now())
+
+	err = s.instance.QueryRowContext(ctx, queryLookup, urlHash, ownerID, domain).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", ErrIDNotExists
@@ -92,5 +252,552 @@ func (s *postgres) retrieve(ctx context.Context, id string) (url string, err err
 		return "", errors.Join(ErrRetreivingValue, err)
 	}
 
-	return url, nil
+	return id, nil
+}
+
+const (
+	queryOwner = `
+	SELECT COALESCE(owner_id, '')
+	FROM urls
+	WHERE domain = $1 AND id = $2 AND deleted_at IS NULL`
+)
+
+// owner returns the owner of the (not deleted) link, it's empty when the link has no owner
+func (s *postgres) owner(ctx context.Context, domain, id string) (ownerID string, err error) {
+	defer func(start time.Time) {
+		if err != nil && !errors.Is(err, ErrIDNotExists) {
+			s.instance.Vectors.Counter.IncrementVector("urls", "owner", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("urls", "owner", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "owner")
+	}(time.Now())
+
+	err = s.instance.QueryRowContext(ctx, queryOwner, domain, id).Scan(&ownerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrIDNotExists
+		}
+		return "", errors.Join(ErrRetreivingValue, err)
+	}
+
+	return ownerID, nil
+}
+
+const (
+	queryList = `
+	SELECT domain, id, url, expires_at, COALESCE(redirect, 0), disabled, created_at
+	FROM urls
+	WHERE owner_id = $1 AND deleted_at IS NULL`
+)
+
+// likeEscaper escapes the wildcards of the LIKE patterns, so the given substring is matched as-is
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// listQuery builds the query of a page (having an extra row) of the filtered links
+func listQuery(filter ListFilter, cursor *listCursor, now time.Time) (string, []any) {
+	var query strings.Builder
+	query.WriteString(queryList)
+	args := []any{filter.OwnerID}
+
+	condition := func(format string, values ...any) {
+		placeholders := make([]any, 0, len(values))
+		for _, value := range values {
+			args = append(args, value)
+			placeholders = append(placeholders, len(args))
+		}
+		query.WriteString("\n\tAND ")
+		fmt.Fprintf(&query, format, placeholders...)
+	}
+
+	if len(filter.Destination) != 0 {
+		condition("url ILIKE '%%' || $%d || '%%'", likeEscaper.Replace(filter.Destination))
+	}
+	if len(filter.Tag) != 0 {
+		condition("EXISTS (SELECT 1 FROM url_tags WHERE url_tags.domain = urls.domain AND url_tags.url_id = urls.id "+
+			"AND url_tags.tag = $%d)", filter.Tag)
+	}
+	if filter.CreatedAfter != nil {
+		condition("created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		condition("created_at < $%d", *filter.CreatedBefore)
+	}
+
+	switch filter.Status {
+	case entities.LinkStatusActive:
+		condition("NOT disabled AND (expires_at IS NULL OR expires_at > $%d)", now)
+	case entities.LinkStatusDisabled:
+		condition("disabled AND (expires_at IS NULL OR expires_at > $%d)", now)
+	case entities.LinkStatusExpired:
+		condition("expires_at <= $%d", now)
+	}
+
+	order, comparison := "DESC", "<"
+	if filter.Sort == ListSortOldest {
+		order, comparison = "ASC", ">"
+	}
+
+	if cursor != nil {
+		condition("(created_at, id) "+comparison+" ($%d, $%d)", cursor.CreatedAt, cursor.ID)
+	}
+
+	args = append(args, filter.Limit+1)
+	fmt.Fprintf(&query, "\n\tORDER BY created_at %s, id %s\n\tLIMIT $%d", order, order, len(args))
+
+	return query.String(), args
+}
+
+// list returns the filtered links of the owner after the cursor, one more than the limit is returned when there are
+func (s *postgres) list(ctx context.Context, filter ListFilter, cursor *listCursor, now time.Time) (links []entities.Link, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("urls", "list", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("urls", "list", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "list")
+	}(time.Now())
+
+	query, args := listQuery(filter, cursor, now)
+	rows, err := s.instance.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Join(ErrRetreivingValue, err)
+	}
+	defer rows.Close()
+
+	links = make([]entities.Link, 0, filter.Limit+1)
+	for rows.Next() {
+		link := entities.Link{OwnerID: filter.OwnerID}
+		err = rows.Scan(&link.Domain, &link.ID, &link.URL, &link.ExpiresAt, &link.Redirect, &link.Disabled, &link.CreatedAt)
+		if err != nil {
+			return nil, errors.Join(ErrRetreivingValue, err)
+		}
+		links = append(links, link)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Join(ErrRetreivingValue, err)
+	}
+
+	return links, nil
+}
+
+var (
+	errUpdatingURL = errors.New("error updating url")
+)
+
+const (
+	queryUpdate = `
+	UPDATE urls
+	SET url = $3, url_hash = $4, expires_at = $5, redirect = NULLIF($6, 0), updated_at = $7
+	WHERE domain = $1 AND id = $2 AND deleted_at IS NULL`
+	querySetDisabled = `
+	UPDATE urls
+	SET disabled = $3, updated_at = $4
+	WHERE domain = $1 AND id = $2 AND deleted_at IS NULL`
+	queryDelete = `
+	UPDATE urls
+	SET deleted_at = $3, updated_at = $3
+	WHERE domain = $1 AND id = $2 AND deleted_at IS NULL`
+)
+
+// update replaces the destination, expiration and redirect of the link
+func (s *postgres) update(ctx context.Context, link entities.Link, timestamp time.Time) (err error) {
+	return s.execManagement(ctx, "update", queryUpdate,
+		link.Domain, link.ID, string(link.URL), hashURL(link.URL), link.ExpiresAt, int(link.Redirect), timestamp)
+}
+
+func (s *postgres) setDisabled(ctx context.Context, domain, id string, disabled bool, timestamp time.Time) (err error) {
+	return s.execManagement(ctx, "set_disabled", querySetDisabled, domain, id, disabled, timestamp)
+}
+
+// delete marks the link as deleted, the row is kept so its id is never reused
+func (s *postgres) delete(ctx context.Context, domain, id string, timestamp time.Time) (err error) {
+	return s.execManagement(ctx, "delete", queryDelete, domain, id, timestamp)
+}
+
+// execManagement executes a query changing a single (not deleted) link, ErrIDNotExists is returned when there is none
+func (s *postgres) execManagement(ctx context.Context, method, query string, args ...any) (err error) {
+	defer func(start time.Time) {
+		if err != nil && !errors.Is(err, ErrIDNotExists) {
+			s.instance.Vectors.Counter.IncrementVector("urls", method, metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("urls", method, metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", method)
+	}(time.Now())
+
+	result, err := s.instance.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Join(errUpdatingURL, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Join(errUpdatingURL, err)
+	}
+
+	if affected == 0 {
+		return ErrIDNotExists
+	}
+	return nil
+}
+
+var (
+	errNextSequence = errors.New("error retrieving next value of the sequence")
+)
+
+const (
+	queryNextSequence = `SELECT nextval('urls_id_seq')`
+)
+
+func (s *postgres) nextSequence(ctx context.Context) (value int64, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("urls", "next_sequence", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("urls", "next_sequence", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "next_sequence")
+	}(time.Now())
+
+	if err = s.instance.QueryRowContext(ctx, queryNextSequence).Scan(&value); err != nil {
+		return 0, errors.Join(errNextSequence, err)
+	}
+
+	return value, nil
+}
+
+var (
+	errKeyPool = errors.New("error accessing the key pool")
+)
+
+const (
+	queryPoolDepth = `
+	SELECT COUNT(*)
+	FROM key_pool`
+
+	// the keys already taken by a link are skipped
+	queryFillPool = `
+	INSERT INTO key_pool (id)
+	SELECT key FROM unnest($1::VARCHAR[]) AS key
+	WHERE NOT EXISTS (SELECT 1 FROM urls WHERE urls.domain = '' AND urls.id = key)
+	ON CONFLICT (id) DO NOTHING`
+
+	// the locked rows are skipped so the instances never claim the same key
+	queryClaimPool = `
+	DELETE FROM key_pool
+	WHERE id IN (SELECT id FROM key_pool LIMIT $1 FOR UPDATE SKIP LOCKED)
+	RETURNING id`
+)
+
+func (s *postgres) poolDepth(ctx context.Context) (depth int64, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("key_pool", "depth", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("key_pool", "depth", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "key_pool", "depth")
+	}(time.Now())
+
+	if err = s.instance.QueryRowContext(ctx, queryPoolDepth).Scan(&depth); err != nil {
+		return 0, errors.Join(errKeyPool, err)
+	}
+
+	return depth, nil
+}
+
+func (s *postgres) fillPool(ctx context.Context, keys []string) (inserted int64, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("key_pool", "fill", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("key_pool", "fill", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "key_pool", "fill")
+	}(time.Now())
+
+	result, err := s.instance.ExecContext(ctx, queryFillPool, pq.Array(keys))
+	if err != nil {
+		return 0, errors.Join(errKeyPool, err)
+	}
+
+	if inserted, err = result.RowsAffected(); err != nil {
+		return 0, errors.Join(errKeyPool, err)
+	}
+
+	return inserted, nil
+}
+
+func (s *postgres) claimPool(ctx context.Context, count int) (keys []string, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("key_pool", "claim", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("key_pool", "claim", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "key_pool", "claim")
+	}(time.Now())
+
+	keys = make([]string, 0, count)
+	if err = s.instance.SelectContext(ctx, &keys, queryClaimPool, count); err != nil {
+		return nil, errors.Join(errKeyPool, err)
+	}
+
+	return keys, nil
+}
+
+var (
+	errBlocklist = errors.New("error retrieving the blocklist")
+)
+
+const (
+	queryBlocklist = `
+	SELECT pattern, kind
+	FROM blocked_destinations`
+)
+
+// blocklist returns all of the rules of the blocked destinations
+func (s *postgres) blocklist(ctx context.Context) (rules []blockRule, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("blocked_destinations", "list", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("blocked_destinations", "list", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "blocked_destinations", "list")
+	}(time.Now())
+
+	if err = s.instance.SelectContext(ctx, &rules, queryBlocklist); err != nil {
+		return nil, errors.Join(errBlocklist, err)
+	}
+
+	return rules, nil
+}
+
+var (
+	errLinkKeys = errors.New("error retrieving the keys of the links")
+)
+
+const (
+	queryCountLinks = `
+	SELECT count(*)
+	FROM urls
+	WHERE deleted_at IS NULL`
+
+	queryLinkKeys = `
+	SELECT domain, id
+	FROM urls
+	WHERE deleted_at IS NULL`
+)
+
+// countLinks returns the number of the (not deleted) links
+func (s *postgres) countLinks(ctx context.Context) (count int64, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("urls", "count", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("urls", "count", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "count")
+	}(time.Now())
+
+	if err = s.instance.GetContext(ctx, &count, queryCountLinks); err != nil {
+		return 0, errors.Join(errLinkKeys, err)
+	}
+
+	return count, nil
+}
+
+// linkKeys visits the keys of all of the (not deleted) links, the rows are streamed so they're never held at once
+func (s *postgres) linkKeys(ctx context.Context, visit func(key string)) (err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("urls", "keys", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("urls", "keys", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "urls", "keys")
+	}(time.Now())
+
+	rows, err := s.instance.QueryContext(ctx, queryLinkKeys)
+	if err != nil {
+		return errors.Join(errLinkKeys, err)
+	}
+	defer rows.Close()
+
+	var domain, id string
+	for rows.Next() {
+		if err = rows.Scan(&domain, &id); err != nil {
+			return errors.Join(errLinkKeys, err)
+		}
+		visit(linkKey(domain, id))
+	}
+
+	if err = rows.Err(); err != nil {
+		return errors.Join(errLinkKeys, err)
+	}
+	return nil
+}
+
+const (
+	queryInsertDomain = `
+	INSERT INTO domains (host, owner_id, fallback_url, created_at)
+	VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4)`
+)
+
+func (s *postgres) insertDomain(ctx context.Context, domain entities.Domain) (err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("domains", "insert", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("domains", "insert", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "domains", "insert")
+	}(time.Now())
+
+	_, err = s.instance.ExecContext(ctx, queryInsertDomain, domain.Host, domain.OwnerID, string(domain.FallbackURL), domain.CreatedAt)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return errUniqueConstraintViolated
+		}
+		return errors.Join(errInsertingURL, err)
+	}
+
+	return nil
+}
+
+const (
+	queryDomain = `
+	SELECT host, COALESCE(owner_id, '') AS owner_id, COALESCE(fallback_url, '') AS fallback_url, created_at
+	FROM domains
+	WHERE host = $1`
+	// all of the domains are returned when the owner is empty
+	queryDomains = `
+	SELECT host, COALESCE(owner_id, '') AS owner_id, COALESCE(fallback_url, '') AS fallback_url, created_at
+	FROM domains
+	WHERE $1 = '' OR owner_id = $1
+	ORDER BY host`
+)
+
+// domainRow is the representation of a domain stored on postgres
+type domainRow struct {
+	Host        string    `db:"host"`
+	OwnerID     string    `db:"owner_id"`
+	FallbackURL string    `db:"fallback_url"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (row domainRow) entity() entities.Domain {
+	return entities.Domain{Host: row.Host, OwnerID: row.OwnerID, FallbackURL: entities.URL(row.FallbackURL), CreatedAt: row.CreatedAt}
+}
+
+func (s *postgres) domain(ctx context.Context, host string) (domain entities.Domain, err error) {
+	defer func(start time.Time) {
+		if err != nil && !errors.Is(err, ErrDomainNotExists) {
+			s.instance.Vectors.Counter.IncrementVector("domains", "retrieve", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("domains", "retrieve", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "domains", "retrieve")
+	}(time.Now())
+
+	var row domainRow
+	if err = s.instance.GetContext(ctx, &row, queryDomain, host); err != nil {
+		if err == sql.ErrNoRows {
+			return entities.Domain{}, ErrDomainNotExists
+		}
+		return entities.Domain{}, errors.Join(ErrRetreivingValue, err)
+	}
+
+	return row.entity(), nil
+}
+
+func (s *postgres) domains(ctx context.Context, ownerID string) (domains []entities.Domain, err error) {
+	defer func(start time.Time) {
+		if err != nil {
+			s.instance.Vectors.Counter.IncrementVector("domains", "list", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("domains", "list", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "domains", "list")
+	}(time.Now())
+
+	var rows []domainRow
+	if err = s.instance.SelectContext(ctx, &rows, queryDomains, ownerID); err != nil {
+		return nil, errors.Join(ErrRetreivingValue, err)
+	}
+
+	domains = make([]entities.Domain, 0, len(rows))
+	for _, row := range rows {
+		domains = append(domains, row.entity())
+	}
+
+	return domains, nil
+}
+
+const (
+	queryUpdateDomain = `
+	UPDATE domains
+	SET fallback_url = NULLIF($2, '')
+	WHERE host = $1
+	RETURNING host, COALESCE(owner_id, '') AS owner_id, COALESCE(fallback_url, '') AS fallback_url, created_at`
+	// the domain is only deleted when it has no (not deleted) links, the second column reports whether it has existed
+	queryDeleteDomain = `
+	WITH deleted AS (
+		DELETE FROM domains
+		WHERE host = $1 AND NOT EXISTS (SELECT 1 FROM urls WHERE urls.domain = $1 AND urls.deleted_at IS NULL)
+		RETURNING host
+	)
+	SELECT EXISTS (SELECT 1 FROM deleted), EXISTS (SELECT 1 FROM domains WHERE host = $1)`
+)
+
+// updateDomain replaces the fallback url of the domain, then returns the updated domain
+func (s *postgres) updateDomain(ctx context.Context, domain entities.Domain) (updated entities.Domain, err error) {
+	defer func(start time.Time) {
+		if err != nil && !errors.Is(err, ErrDomainNotExists) {
+			s.instance.Vectors.Counter.IncrementVector("domains", "update", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("domains", "update", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "domains", "update")
+	}(time.Now())
+
+	var row domainRow
+	if err = s.instance.GetContext(ctx, &row, queryUpdateDomain, domain.Host, string(domain.FallbackURL)); err != nil {
+		if err == sql.ErrNoRows {
+			return entities.Domain{}, ErrDomainNotExists
+		}
+		return entities.Domain{}, errors.Join(errUpdatingURL, err)
+	}
+
+	return row.entity(), nil
+}
+
+// deleteDomain removes the domain, ErrDomainInUse is returned when it still has links
+func (s *postgres) deleteDomain(ctx context.Context, host string) (err error) {
+	defer func(start time.Time) {
+		if err != nil && !errors.Is(err, ErrDomainNotExists) && !errors.Is(err, ErrDomainInUse) {
+			s.instance.Vectors.Counter.IncrementVector("domains", "delete", metrics_pkg.StatusFailure)
+			return
+		}
+		s.instance.Vectors.Counter.IncrementVector("domains", "delete", metrics_pkg.StatusSuccess)
+		s.instance.Vectors.Histogram.ObserveResponseTime(start, "domains", "delete")
+	}(time.Now())
+
+	var deleted, existed bool
+	if err = s.instance.QueryRowContext(ctx, queryDeleteDomain, host).Scan(&deleted, &existed); err != nil {
+		return errors.Join(errUpdatingURL, err)
+	}
+
+	switch {
+	case deleted:
+		return nil
+	case existed:
+		return ErrDomainInUse
+	default:
+		return ErrDomainNotExists
+	}
 }
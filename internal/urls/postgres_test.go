@@ -4,15 +4,27 @@ import (
 	"context"
 	"errors"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/mohammadne/fesghel/internal/entities"
 )
 
 var urlColumns = []string{
 	// "id",
 	"url",
+	"expires_at",
+	"redirect",
+	"disabled",
+	"owner_id",
+	"campaign",
+	"title",
+	"metadata",
+	"password_hash",
+	"tags",
 	// "created_at",
 }
 
@@ -27,10 +39,11 @@ func TestPostgresInsert(t *testing.T) {
 
 		mockDatabase.
 			ExpectExec(regexp.QuoteMeta(queryInsert)).
-			WithArgs(sampleId, sampleUrl, timestamp).
+			WithArgs(sampleId, sampleUrl, hashURL(entities.URL(sampleUrl)), nil, 0, timestamp, "", "", "", nil, nil, "", "").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		err := postgresInstacne.insert(context.TODO(), sampleId, sampleUrl, timestamp)
+		link := entities.Link{ID: sampleId, URL: entities.URL(sampleUrl)}
+		err := postgresInstacne.insert(context.TODO(), link, timestamp)
 		if err != nil {
 			t.Errorf("expect no errors %v", err)
 		}
@@ -50,10 +63,10 @@ func TestPostgresRetrieve(t *testing.T) {
 	t.Run("with empty result", func(t *testing.T) {
 		mockDatabase.
 			ExpectQuery(regexp.QuoteMeta(queryRetrieve)).
-			WithArgs(sampleId).
+			WithArgs("", sampleId).
 			WillReturnRows(sqlmock.NewRows(urlColumns))
 
-		_, err := postgresInstacne.retrieve(context.TODO(), sampleId)
+		_, err := postgresInstacne.retrieve(context.TODO(), "", sampleId)
 		if !errors.Is(err, ErrIDNotExists) {
 			t.Errorf("expect ErrIDNotExists error %v", err)
 		}
@@ -66,20 +79,292 @@ func TestPostgresRetrieve(t *testing.T) {
 	t.Run("with valid non-empty result", func(t *testing.T) {
 		mockDatabase.
 			ExpectQuery(regexp.QuoteMeta(queryRetrieve)).
-			WithArgs(sampleId).
-			WillReturnRows(sqlmock.NewRows(urlColumns).AddRow(sampleUrl))
+			WithArgs("", sampleId).
+			WillReturnRows(sqlmock.NewRows(urlColumns).AddRow(sampleUrl, nil, 0, false, "acme", "spring", "", []byte(`{"channel":"email"}`), "", "{ads,sale}"))
 
-		url, err := postgresInstacne.retrieve(context.TODO(), sampleId)
+		link, err := postgresInstacne.retrieve(context.TODO(), "", sampleId)
 		if err != nil {
 			t.Errorf("expect no errors %v", err)
 		}
 
-		if url != sampleUrl {
+		if string(link.URL) != sampleUrl || link.ExpiresAt != nil {
 			t.Error("invalid url has been returned")
 		}
 
+		if link.Campaign != "spring" || len(link.Tags) != 2 || string(link.Metadata) != `{"channel":"email"}` {
+			t.Errorf("invalid attributes have been returned %+v", link)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestPostgresLookup(t *testing.T) {
+	var (
+		sampleId    = "abc"
+		sampleHash  = hashURL("https://sample.com")
+		sampleOwner = "acme"
+	)
+
+	t.Run("with empty result", func(t *testing.T) {
+		mockDatabase.
+			ExpectQuery(regexp.QuoteMeta(queryLookup)).
+			WithArgs(sampleHash, sampleOwner, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		_, err := postgresInstacne.lookup(context.TODO(), "", sampleHash, sampleOwner)
+		if !errors.Is(err, ErrIDNotExists) {
+			t.Errorf("expect ErrIDNotExists error %v", err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("with valid non-empty result", func(t *testing.T) {
+		mockDatabase.
+			ExpectQuery(regexp.QuoteMeta(queryLookup)).
+			WithArgs(sampleHash, sampleOwner, "").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(sampleId))
+
+		id, err := postgresInstacne.lookup(context.TODO(), "", sampleHash, sampleOwner)
+		if err != nil {
+			t.Errorf("expect no errors %v", err)
+		}
+
+		if id != sampleId {
+			t.Error("invalid id has been returned")
+		}
+
 		if err := mockDatabase.ExpectationsWereMet(); err != nil {
 			t.Errorf("unfulfilled expectations: %v", err)
 		}
 	})
 }
+
+func TestPostgresKeyPool(t *testing.T) {
+	t.Run("depth", func(t *testing.T) {
+		mockDatabase.
+			ExpectQuery(regexp.QuoteMeta(queryPoolDepth)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+		depth, err := postgresInstacne.poolDepth(context.TODO())
+		if err != nil || depth != 42 {
+			t.Errorf("expect depth of 42 with no errors, got %d and %v", depth, err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("fill", func(t *testing.T) {
+		mockDatabase.
+			ExpectExec(regexp.QuoteMeta(queryFillPool)).
+			WithArgs(`{"abcd","efgh"}`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		inserted, err := postgresInstacne.fillPool(context.TODO(), []string{"abcd", "efgh"})
+		if err != nil || inserted != 1 {
+			t.Errorf("expect 1 inserted key with no errors, got %d and %v", inserted, err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("claim", func(t *testing.T) {
+		mockDatabase.
+			ExpectQuery(regexp.QuoteMeta(queryClaimPool)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("abcd").AddRow("efgh"))
+
+		keys, err := postgresInstacne.claimPool(context.TODO(), 2)
+		if err != nil || len(keys) != 2 {
+			t.Errorf("expect 2 claimed keys with no errors, got %v and %v", keys, err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestPostgresInsertBatch(t *testing.T) {
+	var (
+		sampleURL = "https://sample.com"
+	)
+
+	t.Run("skips the taken ids", func(t *testing.T) {
+		timestamp := time.Now()
+		links := []entities.Link{
+			{ID: "first", URL: entities.URL(sampleURL)},
+			{ID: "second", Domain: "go.acme.com", URL: entities.URL(sampleURL), Redirect: entities.RedirectFound, OwnerID: "acme",
+				Campaign: "spring", Tags: []string{"ads", "sale"}, Metadata: []byte(`{"channel":"email"}`)},
+		}
+
+		mockDatabase.
+			ExpectQuery(regexp.QuoteMeta(queryInsertBatch)).
+			WithArgs(
+				"first", sampleURL, hashURL(entities.URL(sampleURL)), nil, 0, timestamp, "", "", "", nil, "", "",
+				"second", sampleURL, hashURL(entities.URL(sampleURL)), nil, 302, timestamp, "acme", "spring", "", `{"channel":"email"}`, "go.acme.com", "",
+				`{"go.acme.com","go.acme.com"}`, `{"second","second"}`, `{"ads","sale"}`,
+			).
+			WillReturnRows(sqlmock.NewRows([]string{"domain", "id"}).AddRow("go.acme.com", "second"))
+
+		inserted, err := postgresInstacne.insertBatch(context.TODO(), links, timestamp)
+		if err != nil {
+			t.Errorf("expect no errors %v", err)
+		}
+
+		if _, ok := inserted["go.acme.com/second"]; !ok || len(inserted) != 1 {
+			t.Errorf("expect only the second link to be inserted %v", inserted)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestPostgresManagement(t *testing.T) {
+	var (
+		sampleID = "spring"
+	)
+
+	t.Run("set disabled", func(t *testing.T) {
+		timestamp := time.Now()
+
+		mockDatabase.
+			ExpectExec(regexp.QuoteMeta(querySetDisabled)).
+			WithArgs("", sampleID, true, timestamp).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		if err := postgresInstacne.setDisabled(context.TODO(), "", sampleID, true, timestamp); err != nil {
+			t.Errorf("expect no errors %v", err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+
+	t.Run("delete not existing", func(t *testing.T) {
+		timestamp := time.Now()
+
+		mockDatabase.
+			ExpectExec(regexp.QuoteMeta(queryDelete)).
+			WithArgs("", sampleID, timestamp).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := postgresInstacne.delete(context.TODO(), "", sampleID, timestamp)
+		if !errors.Is(err, ErrIDNotExists) {
+			t.Errorf("expect ErrIDNotExists error %v", err)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestPostgresList(t *testing.T) {
+	var (
+		sampleOwner = "acme"
+		createdAt   = time.Now().UTC()
+		now         = time.Now()
+	)
+
+	t.Run("filters after the cursor", func(t *testing.T) {
+		filter := ListFilter{
+			OwnerID:     sampleOwner,
+			Destination: "100%_off",
+			Status:      entities.LinkStatusActive,
+			Sort:        ListSortOldest,
+			Limit:       2,
+		}
+		cursor := &listCursor{CreatedAt: createdAt, ID: "abc"}
+
+		query, args := listQuery(filter, cursor, now)
+		mockDatabase.
+			ExpectQuery(regexp.QuoteMeta(query)).
+			WithArgs(sampleOwner, `100\%\_off`, now, createdAt, "abc", 3).
+			WillReturnRows(sqlmock.NewRows([]string{"domain", "id", "url", "expires_at", "redirect", "disabled", "created_at"}).
+				AddRow("", "abd", "https://sample.com/100%_off", nil, 0, false, createdAt))
+
+		links, err := postgresInstacne.list(context.TODO(), filter, cursor, now)
+		if err != nil || len(links) != 1 || links[0].ID != "abd" {
+			t.Errorf("expect a single link with no errors, got %v and %v", links, err)
+		}
+
+		if len(args) != 6 || !strings.Contains(query, "(created_at, id) > ($4, $5)") ||
+			!strings.Contains(query, "ORDER BY created_at ASC, id ASC") {
+			t.Errorf("expect the links after the cursor in ascending order, got %s with %v", query, args)
+		}
+
+		if err := mockDatabase.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+	})
+}
+
+func TestPostgresDeleteDomain(t *testing.T) {
+	var (
+		sampleHost = "go.acme.com"
+	)
+
+	for name, testCase := range map[string]struct {
+		deleted, existed bool
+		err              error
+	}{
+		"deleted":    {deleted: true, existed: true},
+		"in use":     {deleted: false, existed: true, err: ErrDomainInUse},
+		"not exists": {deleted: false, existed: false, err: ErrDomainNotExists},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockDatabase.
+				ExpectQuery(regexp.QuoteMeta(queryDeleteDomain)).
+				WithArgs(sampleHost).
+				WillReturnRows(sqlmock.NewRows([]string{"deleted", "existed"}).AddRow(testCase.deleted, testCase.existed))
+
+			err := postgresInstacne.deleteDomain(context.TODO(), sampleHost)
+			if !errors.Is(err, testCase.err) {
+				t.Errorf("expect %v error, got %v", testCase.err, err)
+			}
+
+			if err := mockDatabase.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestPostgresLinkKeys(t *testing.T) {
+	mockDatabase.
+		ExpectQuery(regexp.QuoteMeta(queryLinkKeys)).
+		WillReturnRows(sqlmock.NewRows([]string{"domain", "id"}).AddRow("", "abcdef").AddRow("go.acme.com", "spring"))
+
+	var keys []string
+	err := postgresInstacne.linkKeys(context.TODO(), func(key string) { keys = append(keys, key) })
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(keys) != 2 || keys[0] != "abcdef" || keys[1] != "go.acme.com/spring" {
+		t.Errorf("invalid keys have been visited %v", keys)
+	}
+
+	if err := mockDatabase.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %v", err)
+	}
+}
+
+func TestMaxInsertBatch(t *testing.T) {
+	if parameters := maxInsertBatch*insertBatchColumns + 3; parameters > 65535 {
+		t.Errorf("expect the largest batch insert to fit the parameters limit of postgres, got %d parameters", parameters)
+	}
+}
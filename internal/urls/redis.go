@@ -2,17 +2,34 @@ package urls
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
+	"github.com/mohammadne/fesghel/internal/entities"
 	redis_pkg "github.com/mohammadne/fesghel/pkg/databases/redis"
 )
 
 type Redis interface {
-	insert(ctx context.Context, id, url string, expiration time.Duration) error
-	retrieve(ctx context.Context, id string) (url string, err error)
+	insert(ctx context.Context, link entities.Link, expiration time.Duration) error
+	insertBatch(ctx context.Context, links []entities.Link, expirations []time.Duration) error
+	// retrieve returns the cached link alongside the time its cache expires (zero when it's unknown)
+	retrieve(ctx context.Context, domain, id string) (link entities.Link, cachedUntil time.Time, err error)
+	// insertMissing caches that the link doesn't exist, retrieve returns errIDMissing until it expires
+	insertMissing(ctx context.Context, domain, id string, expiration time.Duration) error
+	invalidate(ctx context.Context, domain, id string) error
+
+	// publish broadcasts the message to the subscribers of the channel (on all of the instances)
+	publish(ctx context.Context, channel, message string) error
+	// subscribe returns the messages of the channel until the context is done, it's reconnected when the connection
+	// drops and the subscriptionGap is delivered then, since the messages published meanwhile have been missed.
+	subscribe(ctx context.Context, channel string) (<-chan string, error)
 }
 
+// subscriptionGap is delivered by the subscriptions once they're reconnected, it's never published by the instances
+const subscriptionGap = ""
+
 type redis struct {
 	instance *redis_pkg.Redis
 }
@@ -25,17 +42,75 @@ func NewRedis(cfg *redis_pkg.Config) (Redis, error) {
 	return &redis{instance: instance}, nil
 }
 
+// cachedLink is the representation of a link stored on redis
+type cachedLink struct {
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Redirect  int        `json:"redirect,omitempty"`
+	Disabled  bool       `json:"disabled,omitempty"`
+	OwnerID   string     `json:"owner_id,omitempty"`
+
+	Campaign string          `json:"campaign,omitempty"`
+	Title    string          `json:"title,omitempty"`
+	Tags     []string        `json:"tags,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
 var (
 	errInvalidInsertParameters = errors.New("error Invalid Insert Parameters")
 	errInsertURLToRedis        = errors.New("error insert url to redis")
 )
 
-func (s *redis) insert(ctx context.Context, id, url string, expiration time.Duration) error {
-	if len(id) == 0 || len(url) == 0 {
+const (
+	// invalidationGuard is the duration the cache of a changed link is not filled again,
+	// it covers the retrievals which have read the link from postgres before it's been changed.
+	invalidationGuard = 5 * time.Second
+	guardKeyPrefix    = "guard:"
+	// missingKeyPrefix marks the ids which are known not to exist (the negative cache)
+	missingKeyPrefix = "nx:"
+)
+
+// guardKey and missingKey hash-tag the key of the link, so they share its hash slot on the clusters
+// and the scripts (and transactions) touching them together are allowed. The keys of the links never
+// have braces themselves (base62 ids and hosts), so the whole key is their hash tag.
+func guardKey(key string) string {
+	return guardKeyPrefix + "{" + key + "}"
+}
+
+func missingKey(key string) string {
+	return missingKeyPrefix + "{" + key + "}"
+}
+
+// scriptInsert sets the link only when it's not guarded, KEYS: link, guard and missing, ARGV: value and expiration (ms).
+// The link is no longer missing in any case, since it's been stored on postgres.
+var scriptInsert = redis_pkg.NewScript(`
+redis.call('DEL', KEYS[3])
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1`)
+
+// insert fills the cache of the link, it's skipped while the link is guarded by an invalidation
+func (s *redis) insert(ctx context.Context, link entities.Link, expiration time.Duration) error {
+	if len(link.ID) == 0 || len(link.URL) == 0 {
 		return errInvalidInsertParameters
 	}
 
-	if err := s.instance.Set(ctx, id, url, expiration).Err(); err != nil {
+	value, err := encodeLink(link)
+	if err != nil {
+		return errors.Join(errInsertURLToRedis, err)
+	}
+
+	key := linkKey(link.Domain, link.ID)
+	keys := []string{key, guardKey(key), missingKey(key)}
+	if err := scriptInsert.Run(ctx, s.instance, keys, value, expiration.Milliseconds()).Err(); err != nil {
 		// s.metrics.counter.WithLabelValues("SetInformation", "failure").Inc()
 		return errors.Join(errInsertURLToRedis, err)
 	}
@@ -44,25 +119,217 @@ func (s *redis) insert(ctx context.Context, id, url string, expiration time.Dura
 	return nil
 }
 
+// insertBatch stores all of the links (each with its own expiration) via a single pipeline
+func (s *redis) insertBatch(ctx context.Context, links []entities.Link, expirations []time.Duration) error {
+	if len(links) != len(expirations) {
+		return errInvalidInsertParameters
+	}
+
+	_, err := s.instance.Pipelined(ctx, func(pipe redis_pkg.Pipeliner) error {
+		for index, link := range links {
+			if len(link.ID) == 0 || len(link.URL) == 0 {
+				return errInvalidInsertParameters
+			}
+
+			value, err := encodeLink(link)
+			if err != nil {
+				return err
+			}
+			key := linkKey(link.Domain, link.ID)
+			pipe.Del(ctx, missingKey(key))
+			pipe.Set(ctx, key, value, expirations[index])
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Join(errInsertURLToRedis, err)
+	}
+
+	return nil
+}
+
+func encodeLink(link entities.Link) ([]byte, error) {
+	return json.Marshal(cachedLink{
+		URL:       string(link.URL),
+		ExpiresAt: link.ExpiresAt,
+		Redirect:  int(link.Redirect),
+		Disabled:  link.Disabled,
+		OwnerID:   link.OwnerID,
+		Campaign:  link.Campaign,
+		Title:     link.Title,
+		Tags:      link.Tags,
+		Metadata:  link.Metadata,
+
+		PasswordHash: link.PasswordHash,
+	})
+}
+
 var (
 	errInvalidRetrieveParameters = errors.New("error Invalid Insert Parameters")
 	errIDNotFound                = errors.New("errIDNotFound")
+	errIDMissing                 = errors.New("error id is cached as missing")
+	errRetrieveURLFromRedis      = errors.New("error retrieve url from redis")
 )
 
-func (s *redis) retrieve(ctx context.Context, id string) (string, error) {
+func (s *redis) retrieve(ctx context.Context, domain, id string) (entities.Link, time.Time, error) {
 	if len(id) == 0 {
-		return "", errInvalidRetrieveParameters
+		return entities.Link{}, time.Time{}, errInvalidRetrieveParameters
 	}
 
-	url, err := s.instance.Get(ctx, id).Result()
+	key := linkKey(domain, id)
+	var get *redis_pkg.StringCmd
+	var ttl *redis_pkg.DurationCmd
+	var missing *redis_pkg.IntCmd
+	_, err := s.instance.Pipelined(ctx, func(pipe redis_pkg.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		ttl = pipe.PTTL(ctx, key)
+		missing = pipe.Exists(ctx, missingKey(key))
+		return nil
+	})
 	if err != nil {
 		if errors.Is(err, redis_pkg.Nil) {
-			return "", errIDNotFound
+			if missing.Val() == 1 {
+				return entities.Link{}, time.Time{}, errIDMissing
+			}
+			return entities.Link{}, time.Time{}, errIDNotFound
 		}
 		// s.metrics.counter.WithLabelValues("SetInformation", "failure").Inc()
-		return "", errors.Join(errInsertURLToRedis, err)
+		return entities.Link{}, time.Time{}, errors.Join(errRetrieveURLFromRedis, err)
+	}
+
+	value, err := get.Bytes()
+	if err != nil {
+		return entities.Link{}, time.Time{}, errors.Join(errRetrieveURLFromRedis, err)
+	}
+
+	var cached cachedLink
+	if err := json.Unmarshal(value, &cached); err != nil {
+		return entities.Link{}, time.Time{}, errors.Join(errRetrieveURLFromRedis, err)
+	}
+
+	var cachedUntil time.Time
+	if remaining := ttl.Val(); remaining > 0 { // negative when the key has no expiration
+		cachedUntil = time.Now().Add(remaining)
 	}
 
 	// s.metrics.counter.WithLabelValues("SetInformation", "success").Inc()
-	return url, nil
+	return entities.Link{
+		ID:        strings.Clone(id), // the id may alias the buffer of the request, while the link outlives it
+		Domain:    strings.Clone(domain),
+		URL:       entities.URL(cached.URL),
+		ExpiresAt: cached.ExpiresAt,
+		Redirect:  entities.Redirect(cached.Redirect),
+		Disabled:  cached.Disabled,
+		OwnerID:   cached.OwnerID,
+		Campaign:  cached.Campaign,
+		Title:     cached.Title,
+		Tags:      cached.Tags,
+		Metadata:  cached.Metadata,
+
+		PasswordHash: cached.PasswordHash,
+	}, cachedUntil, nil
+}
+
+// scriptInsertMissing marks the link as missing unless it's been cached meanwhile, KEYS: link and missing, ARGV: expiration (ms)
+var scriptInsertMissing = redis_pkg.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('SET', KEYS[2], 1, 'PX', ARGV[1])
+return 1`)
+
+func (s *redis) insertMissing(ctx context.Context, domain, id string, expiration time.Duration) error {
+	if len(id) == 0 || expiration <= 0 {
+		return errInvalidInsertParameters
+	}
+
+	key := linkKey(domain, id)
+	keys := []string{key, missingKey(key)}
+	if err := scriptInsertMissing.Run(ctx, s.instance, keys, expiration.Milliseconds()).Err(); err != nil {
+		return errors.Join(errInsertURLToRedis, err)
+	}
+	return nil
+}
+
+var (
+	errInvalidateURLFromRedis = errors.New("error invalidate url from redis")
+)
+
+// invalidate removes the link and guards it against the stale fills at once
+func (s *redis) invalidate(ctx context.Context, domain, id string) error {
+	if len(id) == 0 {
+		return errInvalidRetrieveParameters
+	}
+
+	key := linkKey(domain, id)
+	_, err := s.instance.TxPipelined(ctx, func(pipe redis_pkg.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.Set(ctx, guardKey(key), 1, invalidationGuard)
+		return nil
+	})
+	if err != nil {
+		return errors.Join(errInvalidateURLFromRedis, err)
+	}
+
+	return nil
+}
+
+var (
+	errPublishToRedis   = errors.New("error publish message to redis")
+	errSubscribeToRedis = errors.New("error subscribe to redis channel")
+)
+
+func (s *redis) publish(ctx context.Context, channel, message string) error {
+	if err := s.instance.Publish(ctx, channel, message).Err(); err != nil {
+		return errors.Join(errPublishToRedis, err)
+	}
+	return nil
+}
+
+func (s *redis) subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	pubsub := s.instance.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil { // waits for the subscription to be confirmed
+		pubsub.Close()
+		return nil, errors.Join(errSubscribeToRedis, err)
+	}
+
+	messages := make(chan string)
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		received := pubsub.ChannelWithSubscriptions()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-received:
+				if !ok {
+					return
+				}
+
+				// the confirmation has been received already, so the next subscriptions are the reconnections
+				var payload string
+				switch message := message.(type) {
+				case *redis_pkg.Message:
+					payload = message.Payload
+				case *redis_pkg.Subscription:
+					if message.Kind != "subscribe" {
+						continue
+					}
+					payload = subscriptionGap
+				default:
+					continue
+				}
+
+				select {
+				case messages <- payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
 }
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+
+	"github.com/mohammadne/fesghel/internal/entities"
 )
 
 const cacheTTL = 3 * time.Second
@@ -19,14 +21,14 @@ func TestRedisInsert(t *testing.T) {
 
 	t.Run("empty parameters", func(t *testing.T) {
 		t.Run("empty id", func(t *testing.T) {
-			err := redisInstance.insert(context.TODO(), "", sampleURL, cacheTTL)
+			err := redisInstance.insert(context.TODO(), entities.Link{URL: entities.URL(sampleURL)}, cacheTTL)
 			if !errors.Is(err, errInvalidInsertParameters) {
 				t.Error(err)
 			}
 		})
 
 		t.Run("empty url", func(t *testing.T) {
-			err := redisInstance.insert(context.TODO(), sampleID, "", cacheTTL)
+			err := redisInstance.insert(context.TODO(), entities.Link{ID: sampleID}, cacheTTL)
 			if !errors.Is(err, errInvalidInsertParameters) {
 				t.Error(err)
 			}
@@ -34,23 +36,24 @@ func TestRedisInsert(t *testing.T) {
 	})
 
 	t.Run("valid insert", func(t *testing.T) {
-		err := redisInstance.insert(context.TODO(), sampleID, sampleURL, cacheTTL)
+		link := entities.Link{ID: sampleID, URL: entities.URL(sampleURL), Redirect: entities.RedirectFound}
+		err := redisInstance.insert(context.TODO(), link, cacheTTL)
 		if err != nil {
 			t.Error(err)
 		}
 
-		url, err := miniredisInstance.Get(sampleID)
+		value, err := miniredisInstance.Get(sampleID)
 		if err != nil {
 			t.Error(err)
 		}
 
-		if url != sampleURL {
-			t.Error("invalid url has been returned")
+		if value != `{"url":"sample-url","redirect":302}` {
+			t.Errorf("invalid value has been stored %s", value)
 		}
 	})
 
 	t.Run("check ttl", func(t *testing.T) {
-		err := redisInstance.insert(context.TODO(), sampleID, sampleURL, cacheTTL)
+		err := redisInstance.insert(context.TODO(), entities.Link{ID: sampleID, URL: entities.URL(sampleURL)}, cacheTTL)
 		if err != nil {
 			t.Error(err)
 		}
@@ -71,12 +74,13 @@ func TestRedisInsert(t *testing.T) {
 
 func TestRedisRetrieve(t *testing.T) {
 	var (
-		sampleID  = "sample-id"
-		sampleURL = "sample-url"
+		sampleID    = "sample-id"
+		sampleURL   = "sample-url"
+		sampleValue = `{"url":"sample-url","redirect":307}`
 	)
 
 	t.Run("with empty id", func(t *testing.T) {
-		_, err := redisInstance.retrieve(context.TODO(), "")
+		_, _, err := redisInstance.retrieve(context.TODO(), "", "")
 		if !errors.Is(err, errInvalidRetrieveParameters) {
 			t.Error(err)
 		}
@@ -84,28 +88,185 @@ func TestRedisRetrieve(t *testing.T) {
 
 	t.Run("valid result", func(t *testing.T) {
 		// miniredisInstance.FlushAll()
-		miniredisInstance.Set(sampleID, sampleURL)
+		miniredisInstance.Set(sampleID, sampleValue)
 		miniredisInstance.SetTTL(sampleID, cacheTTL)
 
-		url, err := redisInstance.retrieve(context.TODO(), sampleID)
+		link, cachedUntil, err := redisInstance.retrieve(context.TODO(), "", sampleID)
 		if err != nil {
 			t.Error(err)
 		}
 
-		if url != sampleURL {
-			t.Error("invalid url has been returned")
+		if string(link.URL) != sampleURL || link.Redirect != entities.RedirectTemporaryRedirect {
+			t.Error("invalid link has been returned")
+		}
+
+		if remaining := time.Until(cachedUntil); remaining <= 0 || remaining > cacheTTL {
+			t.Errorf("invalid cache expiration has been returned %v", remaining)
 		}
 	})
 
 	t.Run("check ttl", func(t *testing.T) {
-		miniredisInstance.Set(sampleID, sampleURL)
+		miniredisInstance.Set(sampleID, sampleValue)
 		miniredisInstance.SetTTL(sampleID, cacheTTL)
 
 		miniredisInstance.FastForward(cacheTTL)
 
-		_, err := redisInstance.retrieve(context.TODO(), sampleID)
+		_, _, err := redisInstance.retrieve(context.TODO(), "", sampleID)
 		if !errors.Is(err, errIDNotFound) {
 			t.Errorf("expecting errIDNotFound error but got something else: %v", err)
 		}
 	})
 }
+
+func TestRedisInsertBatch(t *testing.T) {
+	t.Run("mismatched expirations", func(t *testing.T) {
+		err := redisInstance.insertBatch(context.TODO(), []entities.Link{{ID: "id", URL: "url"}}, nil)
+		if !errors.Is(err, errInvalidInsertParameters) {
+			t.Error(err)
+		}
+	})
+
+	t.Run("valid insert", func(t *testing.T) {
+		links := []entities.Link{{ID: "batch-1", URL: "url-1"}, {ID: "batch-2", URL: "url-2"}}
+		err := redisInstance.insertBatch(context.TODO(), links, []time.Duration{cacheTTL, 2 * cacheTTL})
+		if err != nil {
+			t.Error(err)
+		}
+
+		if value, _ := miniredisInstance.Get("batch-2"); value != `{"url":"url-2"}` {
+			t.Errorf("invalid value has been stored %s", value)
+		}
+
+		if ttl := miniredisInstance.TTL("batch-2"); ttl != 2*cacheTTL {
+			t.Errorf("invalid ttl has been set %v", ttl)
+		}
+	})
+}
+
+func TestRedisInvalidate(t *testing.T) {
+	var (
+		sampleID = "invalidated-id"
+		link     = entities.Link{ID: sampleID, URL: "https://example.com"}
+	)
+
+	if err := redisInstance.insert(context.TODO(), link, cacheTTL); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := redisInstance.invalidate(context.TODO(), "", sampleID); err != nil {
+		t.Error(err)
+	}
+
+	if miniredisInstance.Exists(sampleID) {
+		t.Error("expect the link to be removed")
+	}
+
+	if ttl := miniredisInstance.TTL(guardKey(sampleID)); ttl != invalidationGuard {
+		t.Errorf("expect the link to be guarded, ttl %v", ttl)
+	}
+
+	t.Run("guarded fill", func(t *testing.T) {
+		if err := redisInstance.insert(context.TODO(), link, cacheTTL); err != nil {
+			t.Error(err)
+		}
+
+		if miniredisInstance.Exists(sampleID) {
+			t.Error("expect the stale link not to be cached while guarded")
+		}
+
+		miniredisInstance.FastForward(invalidationGuard)
+		if err := redisInstance.insert(context.TODO(), link, cacheTTL); err != nil {
+			t.Error(err)
+		}
+
+		if !miniredisInstance.Exists(sampleID) {
+			t.Error("expect the link to be cached after the guard")
+		}
+	})
+}
+
+func TestRedisMissing(t *testing.T) {
+	var (
+		sampleID = "missing-id"
+		link     = entities.Link{ID: sampleID, URL: "https://example.com"}
+	)
+
+	if err := redisInstance.insertMissing(context.TODO(), "", sampleID, cacheTTL); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl := miniredisInstance.TTL(missingKey(sampleID)); ttl != cacheTTL {
+		t.Errorf("invalid ttl has been set %v", ttl)
+	}
+
+	if _, _, err := redisInstance.retrieve(context.TODO(), "", sampleID); !errors.Is(err, errIDMissing) {
+		t.Errorf("expecting errIDMissing error but got something else: %v", err)
+	}
+
+	t.Run("cleared by storing the link", func(t *testing.T) {
+		if err := redisInstance.insert(context.TODO(), link, cacheTTL); err != nil {
+			t.Error(err)
+		}
+
+		if miniredisInstance.Exists(missingKey(sampleID)) {
+			t.Error("expect the link not to be missing anymore")
+		}
+
+		if _, _, err := redisInstance.retrieve(context.TODO(), "", sampleID); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("not marked while cached", func(t *testing.T) {
+		if err := redisInstance.insertMissing(context.TODO(), "", sampleID, cacheTTL); err != nil {
+			t.Error(err)
+		}
+
+		if miniredisInstance.Exists(missingKey(sampleID)) {
+			t.Error("expect the cached link not to be marked as missing")
+		}
+	})
+}
+
+func TestRedisSubscriptionGap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, err := redisInstance.subscribe(ctx, "gaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func() (string, bool) {
+		select {
+		case message := <-messages:
+			return message, true
+		case <-time.After(5 * time.Second):
+			return "", false
+		}
+	}
+
+	if err := redisInstance.publish(ctx, "gaps", "before"); err != nil {
+		t.Fatal(err)
+	}
+	if message, ok := receive(); !ok || message != "before" {
+		t.Fatalf("expect the published message, got %q", message)
+	}
+
+	// the connection drops, then the subscription is reconnected
+	miniredisInstance.Close()
+	if err := miniredisInstance.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	if message, ok := receive(); !ok || message != subscriptionGap {
+		t.Fatalf("expect the gap to be delivered once reconnected, got %q", message)
+	}
+
+	if err := redisInstance.publish(ctx, "gaps", "after"); err != nil {
+		t.Fatal(err)
+	}
+	if message, ok := receive(); !ok || message != "after" {
+		t.Errorf("expect the published message after the gap, got %q", message)
+	}
+}
@@ -2,38 +2,101 @@ package urls
 
 import (
 	"context"
-	"crypto/sha256"
 	"errors"
-	"math/big"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/mohammadne/fesghel/internal/entities"
 	metrics_pkg "github.com/mohammadne/fesghel/pkg/observability/metrics"
 )
 
 type Service interface {
-	// Shorten shortenes the url by giving a url string, then returns the shortened id
-	Shorten(ctx context.Context, url entities.URL) (string, error)
+	// Shorten shortenes the link's url, then returns the shortened id.
+	// The link's ID is used as-is when given, otherwise a key will be generated.
+	Shorten(ctx context.Context, link entities.Link) (string, error)
 
-	// Retrieve returns the actual url by giving url's shortened id
-	Retrieve(ctx context.Context, id string) (entities.URL, error)
+	// ShortenBatch shortenes all of the links at once, the result of each link is returned at the same index
+	ShortenBatch(ctx context.Context, links []entities.Link) ([]ShortenResult, error)
+
+	// Retrieve returns the link (including the actual url) by giving url's shortened id and its domain
+	Retrieve(ctx context.Context, domain, id string) (entities.Link, error)
+
+	// Unlock returns the link the same as Retrieve, but the password should match when the link is protected
+	Unlock(ctx context.Context, domain, id, password string) (entities.Link, error)
+
+	// Update replaces the destination, expiration and redirect of the link having the same id
+	Update(ctx context.Context, link entities.Link) error
+
+	// Disable stops the link from redirecting until it's enabled again
+	Disable(ctx context.Context, domain, id string) error
+
+	// Enable lets the disabled link redirect again
+	Enable(ctx context.Context, domain, id string) error
+
+	// Delete removes the link, its id is never reused on the domain
+	Delete(ctx context.Context, domain, id string) error
+
+	// List returns a page of the owner's links matching the filter
+	List(ctx context.Context, filter ListFilter) (ListPage, error)
+
+	// ShortURL returns the address redirecting to the link having the id on the domain
+	ShortURL(domain, id string) string
+
+	// Authorize returns ErrNotOwner unless the link is owned by the given owner
+	Authorize(ctx context.Context, domain, id, ownerID string) error
+
+	// NormalizeDomain normalizes the host of a domain, it's empty for the default domain (the base address)
+	NormalizeDomain(host string) (string, error)
+
+	// ResolveHost returns the domain served on the host, the unknown hosts are served as the default domain
+	ResolveHost(host string) entities.Domain
+
+	// CreateDomain registers a new domain, its host is normalized
+	CreateDomain(ctx context.Context, domain entities.Domain) (entities.Domain, error)
+
+	// Domains returns the domains of the owner, all of the domains are returned when the owner is empty
+	Domains(ctx context.Context, ownerID string) ([]entities.Domain, error)
+
+	// UpdateDomain replaces the fallback url of the domain having the same host
+	UpdateDomain(ctx context.Context, domain entities.Domain) error
+
+	// DeleteDomain removes the domain, ErrDomainInUse is returned when it still has links
+	DeleteDomain(ctx context.Context, host string) error
+
+	// AuthorizeDomain returns ErrDomainNotOwner unless the domain is owned by the given owner
+	AuthorizeDomain(ctx context.Context, host, ownerID string) error
 }
 
 type service struct {
-	config   *Config
-	logger   *zap.Logger
-	metrics  *metrics
-	postgres Postgres
-	redis    Redis
+	config       *Config
+	logger       *zap.Logger
+	metrics      *metrics
+	postgres     Postgres
+	redis        Redis
+	keyGenerator KeyGenerator
+	keyPool      *keyPool    // nil when the key pool is disabled
+	bloom        *bloomGuard // nil when the bloom filter is disabled
+	domains      domainRegistry
+
+	loads        singleflight.Group // coalesces the concurrent lookups of the same link
+	loadDuration atomic.Int64       // the moving average of the lookups (ns), used to refresh the cache early
+
+	checker   DestinationChecker // checks the destinations on shortening, nil when there is nothing to check
+	rechecker DestinationChecker // checks the destinations again on retrieving, nil when there is nothing to check
 }
 
 func NewService(cfg *Config, l *zap.Logger) (Service, error) {
 	var svc = service{config: cfg, logger: l}
 
+	if cfg.MaxBatchSize <= 0 || cfg.MaxBatchSize > maxInsertBatch {
+		l.Panic("the max batch size should be positive and fit the parameters limit of postgres",
+			zap.Int("max_batch_size", cfg.MaxBatchSize), zap.Int("limit", maxInsertBatch))
+	}
+
 	metrics, err := newMetrics()
 	if err != nil {
 		l.Panic("error loading Postgres instance", zap.Error(err))
@@ -52,19 +115,75 @@ func NewService(cfg *Config, l *zap.Logger) (Service, error) {
 	}
 	svc.redis = redis
 
+	if cfg.LocalCache != nil && cfg.LocalCache.Enabled {
+		tiered := newTieredCache(cfg.LocalCache, l, metrics.Cache, redis)
+		invalidations, err := redis.subscribe(context.Background(), cfg.LocalCache.Channel)
+		if err != nil {
+			l.Panic("error subscribing to the invalidations", zap.Error(err))
+		}
+		go tiered.listen(context.Background(), invalidations)
+		svc.redis = tiered
+	}
+
+	if cfg.Bloom != nil && cfg.Bloom.Enabled {
+		svc.bloom = newBloomGuard(cfg.Bloom, l, metrics.Bloom, postgres, svc.redis)
+		// subscribed before building, so the ids shortened meanwhile are not missed
+		shortened, err := redis.subscribe(context.Background(), cfg.Bloom.Channel)
+		if err != nil {
+			l.Panic("error subscribing to the shortened ids", zap.Error(err))
+		}
+		go svc.bloom.listen(context.Background(), shortened)
+
+		if err := svc.bloom.rebuild(context.Background()); err != nil {
+			l.Error("error building the bloom filter, it's built on the next rebuild", zap.Error(err))
+		}
+		go svc.bloom.run(context.Background())
+	}
+
+	keyGenerator, err := NewKeyGenerator(cfg, postgres)
+	if err != nil {
+		l.Panic("error initializing key generator", zap.Error(err))
+	}
+	svc.keyGenerator = keyGenerator
+
+	if cfg.KeyPool != nil && cfg.KeyPool.Enabled {
+		svc.keyPool = newKeyPool(cfg.KeyPool, l, metrics.KeyPoolDepth, postgres, keyGenerator)
+		go svc.keyPool.run(context.Background())
+	}
+
+	if err := svc.loadDomains(context.Background()); err != nil {
+		l.Panic("error loading the domains", zap.Error(err))
+	}
+
+	if err := svc.validateDefaultFallback(); err != nil {
+		l.Panic("error validating the fallback url", zap.Error(err))
+	}
+	go svc.refreshDomains(context.Background(), cfg.DomainsRefreshInterval.Duration())
+
+	if cfg.Checker != nil {
+		svc.checker, svc.rechecker, err = newDestinationCheckers(context.Background(), cfg.Checker, l, postgres)
+		if err != nil {
+			l.Panic("error initializing destination checkers", zap.Error(err))
+		}
+	}
+
 	return &svc, nil
 }
 
 var (
 	ErrInsertingIntoPostgres  = errors.New("error inserting value into postgres")
 	ErrMaxRetriesForCollision = errors.New("max retries exceeded while generating unique key")
+	ErrGeneratingKey          = errors.New("error generating key")
+	ErrExpirationInvalid      = errors.New("error expiration should be in the future")
+	ErrRedirectInvalid        = errors.New("error redirect should be one of 301, 302, 307 or 308")
 )
 
 // Shorten stores the data and returns the shorten key
-// 1. generate key
-// 2. store on oracle
-// 3. retry on conflicts
-func (s *service) Shorten(ctx context.Context, url entities.URL) (key string, err error) {
+// 1. return the existing key of the url when deduplicating
+// 2. generate key (or use the given alias)
+// 3. store on oracle
+// 4. retry on conflicts (only for generated keys)
+func (s *service) Shorten(ctx context.Context, link entities.Link) (key string, err error) {
 	defer func(start time.Time) {
 		var status = metrics_pkg.StatusFailure
 		if err == nil {
@@ -74,15 +193,50 @@ func (s *service) Shorten(ctx context.Context, url entities.URL) (key string, er
 		s.metrics.Counter.IncrementVector("shorten", status)
 	}(time.Now())
 
+	if err = s.validate(&link); err != nil {
+		return "", err
+	}
+
+	if err = s.authorizeDomain(ctx, &link); err != nil {
+		return "", err
+	}
+
+	if err = s.check(ctx, s.checker, link); err != nil {
+		return "", err
+	}
+
+	if key, ok, err := s.deduplicate(ctx, link); err != nil || ok {
+		return key, err
+	}
+
+	if len(link.ID) != 0 {
+		err = s.postgres.insert(ctx, link, time.Now())
+		if err != nil {
+			if errors.Is(err, errUniqueConstraintViolated) {
+				// the caller has chosen the alias, so there is nothing to retry with
+				return "", ErrAliasAlreadyExists
+			}
+			return "", errors.Join(ErrInsertingIntoPostgres, err)
+		}
+
+		s.cache(ctx, link)
+		s.remember(ctx, link)
+		return link.ID, nil
+	}
+
 	for attempt := 1; attempt <= s.config.MaxRetriesOnCollision; attempt++ {
 		timestamp := time.Now()
 
-		key = s.generateKey(string(url), timestamp)
+		link.ID, err = s.nextKey(ctx, string(link.URL))
+		if err != nil {
+			return "", err
+		}
 
-		err = s.postgres.insert(ctx, key, string(url), timestamp)
+		err = s.postgres.insert(ctx, link, timestamp)
 		if err == nil {
-			_ = s.redis.insert(ctx, key, string(url), s.config.CacheExpiration)
-			return key, nil // success
+			s.cache(ctx, link)
+			s.remember(ctx, link)
+			return link.ID, nil // success
 		}
 
 		if errors.Is(err, errUniqueConstraintViolated) {
@@ -97,51 +251,140 @@ func (s *service) Shorten(ctx context.Context, url entities.URL) (key string, er
 	return "", ErrMaxRetriesForCollision
 }
 
-// generateKey generates a random key
-// 1. calculate current epoch timestamp
-// 2. generate a hash via sha256 from timestamp and the value
-// 3. calculate base62 of the trunicated hash
-func (s *service) generateKey(seed string, timestamp time.Time) string {
-	epoch := timestamp.UnixNano()
-	salt := strconv.FormatInt(epoch, 10)
+// validate checks the attributes of the link given for shortening and normalizes its url
+func (s *service) validate(link *entities.Link) error {
+	if err := s.validateDestination(link); err != nil {
+		return err
+	}
+
+	if err := validateAttributes(link); err != nil {
+		return err
+	}
+
+	if err := s.hashPassword(link); err != nil {
+		return err
+	}
+
+	if len(link.ID) != 0 {
+		return validateAlias(link.ID)
+	}
+
+	return nil
+}
+
+// validateDestination checks the url, expiration and redirect of the link and normalizes its url
+func (s *service) validateDestination(link *entities.Link) error {
+	url, err := link.URL.Normalize(s.config.URL.policy())
+	if err != nil {
+		return err
+	}
+	link.URL = url
+
+	if s.pointsAtSelf(link.URL) {
+		return ErrDestinationSelf
+	}
+
+	if link.Expired(time.Now()) {
+		return ErrExpirationInvalid
+	}
+
+	if link.Redirect != entities.RedirectDefault {
+		if _, ok := entities.ToRedirect(int(link.Redirect)); !ok {
+			return ErrRedirectInvalid
+		}
+	}
+
+	return nil
+}
+
+// check asks the checker (when there is one) whether the link's destination is allowed
+func (s *service) check(ctx context.Context, checker DestinationChecker, link entities.Link) error {
+	if checker == nil {
+		return nil
+	}
+
+	if err := checker.Check(ctx, link.URL); err != nil {
+		s.metrics.Counter.IncrementVector("check_destination", metrics_pkg.StatusFailure)
+		return err
+	}
+	s.metrics.Counter.IncrementVector("check_destination", metrics_pkg.StatusSuccess)
+	return nil
+}
+
+// deduplicate returns the existing key of the link's url, if it's asked for and there is one
+func (s *service) deduplicate(ctx context.Context, link entities.Link) (string, bool, error) {
+	if !deduplicable(link) || !(s.config.Deduplicate || link.Deduplicate) {
+		return "", false, nil
+	}
 
-	// TODO: use mobile-number or account-id instead of value
-	hash := sha256.Sum256([]byte(seed + salt))
-	shortHash := hash[:s.config.ShortURLLength]
+	key, err := s.postgres.lookup(ctx, link.Domain, hashURL(link.URL), link.OwnerID)
+	if err != nil {
+		if errors.Is(err, ErrIDNotExists) {
+			return "", false, nil
+		}
+		return "", false, errors.Join(ErrRetreivingDataFromDatabase, err)
+	}
 
-	return encodeToBase62(shortHash)
+	return key, true, nil
 }
 
-// Base62 charset
-const base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+// nextKey claims a key from the key pool, then falls back to generating it when the pool is exhausted
+func (s *service) nextKey(ctx context.Context, seed string) (string, error) {
+	if s.keyPool != nil {
+		if key, ok := s.keyPool.claim(); ok {
+			s.metrics.Counter.IncrementVector("key_pool", metrics_pkg.StatusSuccess)
+			return key, nil
+		}
+		s.metrics.Counter.IncrementVector("key_pool", metrics_pkg.StatusFailure)
+	}
 
-func encodeToBase62(input []byte) string {
-	num := new(big.Int).SetBytes(input)
-	var result strings.Builder
-	base := big.NewInt(62)
-	mod := new(big.Int)
+	return s.generateKey(ctx, seed)
+}
 
-	for num.Cmp(big.NewInt(0)) > 0 {
-		num.DivMod(num, base, mod)
-		result.WriteByte(base62Chars[mod.Int64()])
+// generateKey generates a new key via the configured key generator
+func (s *service) generateKey(ctx context.Context, seed string) (string, error) {
+	key, err := s.keyGenerator.Generate(ctx, seed)
+	if err != nil {
+		return "", errors.Join(ErrGeneratingKey, err)
 	}
+	return key, nil
+}
 
-	// reverse result
-	runes := []rune(result.String())
-	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-		runes[i], runes[j] = runes[j], runes[i]
+// cache stores the link on redis
+func (s *service) cache(ctx context.Context, link entities.Link) {
+	if expiration := s.cacheExpiration(link); expiration > 0 {
+		_ = s.redis.insert(ctx, link, expiration)
 	}
+}
+
+// cacheMissing caches that the link doesn't exist, so the unknown ids don't reach postgres on every retrieval.
+// The entry is removed once a link is stored (and cached) with the same id.
+func (s *service) cacheMissing(ctx context.Context, domain, id string) {
+	if s.config.NegativeCacheExpiration > 0 {
+		_ = s.redis.insertMissing(ctx, domain, id, s.config.NegativeCacheExpiration)
+	}
+}
 
-	return string(runes)
+// cacheExpiration is the configured cache expiration, but the cache never outlives the link itself
+func (s *service) cacheExpiration(link entities.Link) time.Duration {
+	expiration := s.config.CacheExpiration
+	if link.ExpiresAt != nil {
+		if remaining := time.Until(*link.ExpiresAt); remaining < expiration {
+			expiration = remaining
+		}
+	}
+	return expiration
 }
 
 var (
 	ErrShortenIDNotExists         = errors.New("ErrShortenIDNotExists")
+	ErrShortenIDExpired           = errors.New("ErrShortenIDExpired")
+	ErrShortenIDDisabled          = errors.New("ErrShortenIDDisabled")
 	ErrRetreivingDataFromDatabase = errors.New("error retreiving data from database")
 )
 
 // Retrieve retrieves the key's value from the database
-func (s *service) Retrieve(ctx context.Context, id string) (value entities.URL, err error) {
+func (s *service) Retrieve(ctx context.Context, domain, id string) (link entities.Link, err error) {
 	defer func(start time.Time) {
 		var status = metrics_pkg.StatusFailure
 		if err == nil {
@@ -151,20 +394,36 @@ func (s *service) Retrieve(ctx context.Context, id string) (value entities.URL,
 		s.metrics.Counter.IncrementVector("retrieve", status)
 	}(time.Now())
 
-	urlString, err := s.redis.retrieve(ctx, id)
-	if err == nil {
-		return entities.URL(urlString), nil
+	if s.bloom != nil && !s.bloom.mayContain(linkKey(domain, id)) {
+		s.metrics.Cache.IncrementVector(cacheTierBloom, cacheResultNegative)
+		return entities.Link{}, ErrShortenIDNotExists
 	}
-	// todo: just log the error
 
-	urlString, err = s.postgres.retrieve(ctx, id)
-	if err != nil {
-		if errors.Is(err, ErrIDNotExists) {
-			return "", ErrShortenIDNotExists
+	link, cachedUntil, err := s.redis.retrieve(ctx, domain, id)
+	switch {
+	case errors.Is(err, errIDMissing):
+		s.metrics.Cache.IncrementVector(cacheTierRedis, cacheResultNegative)
+		return entities.Link{}, ErrShortenIDNotExists
+	case err != nil:
+		// todo: just log the error
+
+		// the concurrent misses of the same link share a single lookup
+		if link, err = s.load(ctx, domain, id); err != nil {
+			return entities.Link{}, err
 		}
-		return "", errors.Join(ErrRetreivingDataFromDatabase, err)
+	case s.refreshEarly(cachedUntil, time.Now()):
+		// the refresh outlives the request, so it shouldn't alias the buffer of the request
+		go s.refresh(context.WithoutCancel(ctx), strings.Clone(domain), strings.Clone(id))
+	}
+
+	if link.Disabled {
+		return entities.Link{}, ErrShortenIDDisabled
+	}
+
+	// the newly blocked destinations stop redirecting even when they're cached
+	if err = s.check(ctx, s.rechecker, link); err != nil {
+		return entities.Link{}, err
 	}
-	_ = s.redis.insert(ctx, id, urlString, s.config.CacheExpiration)
 
-	return entities.URL(urlString), nil
+	return link, nil
 }
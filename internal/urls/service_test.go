@@ -3,6 +3,7 @@ package urls
 import (
 	"context"
 	"errors"
+	"slices"
 	"testing"
 	"time"
 
@@ -22,15 +23,15 @@ func TestServiceShorten(t *testing.T) {
 
 		{ // prepare the mocks
 			postgresMock.
-				On("insert", mock.Anything, mock.Anything, url, mock.Anything).
+				On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
 				Return(nil).Once()
 
 			redisMock.
-				On("insert", mock.Anything, mock.Anything, url, serviceInstance.config.CacheExpiration).
+				On("insert", mock.Anything, linkMatcher("", url), serviceInstance.config.CacheExpiration).
 				Return(nil).Once()
 		}
 
-		id, err := serviceInstance.Shorten(context.TODO(), entities.URL(url))
+		id, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url)})
 		assert.NoError(t, err)
 		assert.NotNil(t, id)
 		postgresMock.AssertExpectations(t)
@@ -43,19 +44,19 @@ func TestServiceShorten(t *testing.T) {
 
 			{ // prepare the mocks
 				postgresMock.
-					On("insert", mock.Anything, mock.Anything, url, mock.Anything).
+					On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
 					Return(errUniqueConstraintViolated).Once()
 
 				postgresMock.
-					On("insert", mock.Anything, mock.Anything, url, mock.Anything).
+					On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
 					Return(nil).Once()
 
 				redisMock.
-					On("insert", mock.Anything, mock.Anything, url, serviceInstance.config.CacheExpiration).
+					On("insert", mock.Anything, linkMatcher("", url), serviceInstance.config.CacheExpiration).
 					Return(nil).Once()
 			}
 
-			id, err := serviceInstance.Shorten(context.TODO(), entities.URL(url))
+			id, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url)})
 			assert.NoError(t, err)
 			assert.NotNil(t, id)
 			postgresMock.AssertExpectations(t)
@@ -68,12 +69,12 @@ func TestServiceShorten(t *testing.T) {
 			{ // prepare the mocks
 				for range serviceInstance.config.MaxRetriesOnCollision {
 					postgresMock.
-						On("insert", mock.Anything, mock.Anything, url+"2", mock.Anything).
+						On("insert", mock.Anything, linkMatcher("", url+"2"), mock.Anything).
 						Return(errUniqueConstraintViolated).Once()
 				}
 			}
 
-			_, err := serviceInstance.Shorten(context.TODO(), entities.URL(url+"2"))
+			_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url + "2")})
 			if !errors.Is(err, ErrMaxRetriesForCollision) {
 				t.Errorf("expect ErrMaxRetriesForCollision error %v", err)
 			}
@@ -86,42 +87,220 @@ func TestServiceShorten(t *testing.T) {
 
 		{ // prepare the mocks
 			postgresMock.
-				On("insert", mock.Anything, mock.Anything, url, mock.Anything).
+				On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
 				Return(errInsertingURL).Once()
 		}
 
-		_, err := serviceInstance.Shorten(context.TODO(), entities.URL(url))
+		_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url)})
 		if !errors.Is(err, ErrInsertingIntoPostgres) {
 			t.Errorf("expect ErrInsertingIntoPostgres error %v", err)
 		}
 		postgresMock.AssertExpectations(t)
 	})
-}
 
-func TestGenerateKey(t *testing.T) {
-	key := serviceInstance.generateKey("anything", time.Now())
+	t.Run("with expiration", func(t *testing.T) {
+		t.Run("cache aligned to expiration", func(t *testing.T) {
+			initializeServiceInstance()
+
+			expiresAt := time.Now().Add(serviceInstance.config.CacheExpiration / 2)
+
+			{ // prepare the mocks
+				postgresMock.
+					On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
+					Return(nil).Once()
+
+				redisMock.
+					On("insert", mock.Anything, linkMatcher("", url), mock.MatchedBy(func(expiration time.Duration) bool {
+						return expiration > 0 && expiration <= serviceInstance.config.CacheExpiration/2
+					})).
+					Return(nil).Once()
+			}
+
+			_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), ExpiresAt: &expiresAt})
+			assert.NoError(t, err)
+			postgresMock.AssertExpectations(t)
+			redisMock.AssertExpectations(t)
+		})
 
-	expected := false
-	for i := range 4 {
-		if len(key) == serviceInstance.config.ShortURLLength+i {
-			expected = true
+		t.Run("expiration in the past", func(t *testing.T) {
+			initializeServiceInstance()
+
+			expiresAt := time.Now().Add(-time.Minute)
+			_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), ExpiresAt: &expiresAt})
+			if !errors.Is(err, ErrExpirationInvalid) {
+				t.Errorf("expect ErrExpirationInvalid error %v", err)
+			}
+			postgresMock.AssertExpectations(t)
+		})
+	})
+
+	t.Run("deduplicate", func(t *testing.T) {
+		t.Run("already shortened", func(t *testing.T) {
+			initializeServiceInstance()
+
+			{ // prepare the mocks
+				postgresMock.
+					On("lookup", mock.Anything, "", hashURL(entities.URL(url)), "").
+					Return("existing", nil).Once()
+			}
+
+			id, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), Deduplicate: true})
+			assert.NoError(t, err)
+			assert.Equal(t, "existing", id)
+			postgresMock.AssertExpectations(t)
+			redisMock.AssertExpectations(t)
+		})
+
+		t.Run("having attributes", func(t *testing.T) {
+			initializeServiceInstance()
+			expiresAt := time.Now().Add(24 * time.Hour)
+
+			{ // prepare the mocks
+				postgresMock.
+					On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
+					Return(nil).Once()
+
+				redisMock.
+					On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
+					Return(nil).Once()
+			}
+
+			_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), ExpiresAt: &expiresAt, Deduplicate: true})
+			assert.NoError(t, err)
+			postgresMock.AssertNotCalled(t, "lookup", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			postgresMock.AssertExpectations(t)
+		})
+
+		t.Run("not shortened yet", func(t *testing.T) {
+			initializeServiceInstance()
+
+			{ // prepare the mocks
+				postgresMock.
+					On("lookup", mock.Anything, "", hashURL(entities.URL(url)), "").
+					Return("", ErrIDNotExists).Once()
+
+				postgresMock.
+					On("insert", mock.Anything, linkMatcher("", url), mock.Anything).
+					Return(nil).Once()
+
+				redisMock.
+					On("insert", mock.Anything, linkMatcher("", url), serviceInstance.config.CacheExpiration).
+					Return(nil).Once()
+			}
+
+			id, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), Deduplicate: true})
+			assert.NoError(t, err)
+			assert.NotEmpty(t, id)
+			postgresMock.AssertExpectations(t)
+			redisMock.AssertExpectations(t)
+		})
+	})
+
+	t.Run("invalid redirect", func(t *testing.T) {
+		initializeServiceInstance()
+
+		_, err := serviceInstance.Shorten(context.TODO(), entities.Link{URL: entities.URL(url), Redirect: 300})
+		if !errors.Is(err, ErrRedirectInvalid) {
+			t.Errorf("expect ErrRedirectInvalid error %v", err)
 		}
-	}
+		postgresMock.AssertExpectations(t)
+	})
 
-	if !expected {
-		t.Errorf("invalid key length %d", len(key))
-	}
-}
+	t.Run("attributes", func(t *testing.T) {
+		t.Run("normalized", func(t *testing.T) {
+			initializeServiceInstance()
 
-func BenchmarkGenerateKey(b *testing.B) {
-	url := "https://example.com"
-	timestamp := time.Now()
+			link := entities.Link{URL: entities.URL(url), Tags: []string{"Sale", " ads", "sale"}, Metadata: []byte(`{ "channel": "email" }`)}
+			postgresMock.On("insert", mock.Anything, mock.MatchedBy(func(link entities.Link) bool {
+				return slices.Equal(link.Tags, []string{"ads", "sale"}) && string(link.Metadata) == `{"channel":"email"}`
+			}), mock.Anything).Return(nil).Once()
+			redisMock.On("insert", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 
-	b.ResetTimer()
+			if _, err := serviceInstance.Shorten(context.TODO(), link); err != nil {
+				t.Errorf("expect no errors %v", err)
+			}
+			postgresMock.AssertExpectations(t)
+		})
 
-	for i := 0; i < b.N; i++ {
-		_ = serviceInstance.generateKey(url, timestamp)
-	}
+		t.Run("invalid", func(t *testing.T) {
+			initializeServiceInstance()
+
+			for expected, link := range map[error]entities.Link{
+				ErrTagInvalid:      {URL: entities.URL(url), Tags: []string{"spring sale"}},
+				ErrMetadataInvalid: {URL: entities.URL(url), Metadata: []byte(`["email"]`)},
+			} {
+				_, err := serviceInstance.Shorten(context.TODO(), link)
+				if !errors.Is(err, expected) {
+					t.Errorf("expect %v error %v", expected, err)
+				}
+			}
+			postgresMock.AssertExpectations(t)
+		})
+	})
+
+	t.Run("custom alias", func(t *testing.T) {
+		alias := "springSale"
+
+		t.Run("success", func(t *testing.T) {
+			initializeServiceInstance()
+
+			{ // prepare the mocks
+				postgresMock.
+					On("insert", mock.Anything, linkMatcher(alias, url), mock.Anything).
+					Return(nil).Once()
+
+				redisMock.
+					On("insert", mock.Anything, linkMatcher(alias, url), serviceInstance.config.CacheExpiration).
+					Return(nil).Once()
+			}
+
+			id, err := serviceInstance.Shorten(context.TODO(), entities.Link{ID: alias, URL: entities.URL(url)})
+			assert.NoError(t, err)
+			assert.Equal(t, alias, id)
+			postgresMock.AssertExpectations(t)
+			redisMock.AssertExpectations(t)
+		})
+
+		t.Run("already exists", func(t *testing.T) {
+			initializeServiceInstance()
+
+			{ // prepare the mocks
+				postgresMock.
+					On("insert", mock.Anything, linkMatcher(alias, url), mock.Anything).
+					Return(errUniqueConstraintViolated).Once()
+			}
+
+			_, err := serviceInstance.Shorten(context.TODO(), entities.Link{ID: alias, URL: entities.URL(url)})
+			if !errors.Is(err, ErrAliasAlreadyExists) {
+				t.Errorf("expect ErrAliasAlreadyExists error %v", err)
+			}
+			postgresMock.AssertExpectations(t)
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			initializeServiceInstance()
+
+			for _, alias := range []string{"ab", "spring-sale", "a-very-long-alias", "سلام"} {
+				_, err := serviceInstance.Shorten(context.TODO(), entities.Link{ID: alias, URL: entities.URL(url)})
+				if !errors.Is(err, ErrAliasInvalid) {
+					t.Errorf("expect ErrAliasInvalid error for %q: %v", alias, err)
+				}
+			}
+			postgresMock.AssertExpectations(t)
+		})
+
+		t.Run("reserved", func(t *testing.T) {
+			initializeServiceInstance()
+
+			for _, alias := range []string{"api", "healthz", "Metrics"} {
+				_, err := serviceInstance.Shorten(context.TODO(), entities.Link{ID: alias, URL: entities.URL(url)})
+				if !errors.Is(err, ErrAliasReserved) {
+					t.Errorf("expect ErrAliasReserved error for %q: %v", alias, err)
+				}
+			}
+			postgresMock.AssertExpectations(t)
+		})
+	})
 }
 
 func TestServiceRetrieve(t *testing.T) {
@@ -135,53 +314,114 @@ func TestServiceRetrieve(t *testing.T) {
 
 		{ // prepare the mocks
 			redisMock.
-				On("retrieve", mock.Anything, sampleID).
-				Return("", errIDNotFound).Once()
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{}, time.Time{}, errIDNotFound).Once()
 
 			postgresMock.
-				On("retrieve", mock.Anything, sampleID).
-				Return("", ErrIDNotExists).Once()
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{}, ErrIDNotExists).Once()
 		}
 
-		_, err := serviceInstance.Retrieve(context.TODO(), sampleID)
+		_, err := serviceInstance.Retrieve(context.TODO(), "", sampleID)
 		if !errors.Is(err, ErrShortenIDNotExists) {
 			t.Errorf("expect ErrShortenIDNotExists error %v", err)
 		}
 		postgresMock.AssertExpectations(t)
 	})
 
+	t.Run("no cache and no postgres caches the missing id", func(t *testing.T) {
+		initializeServiceInstance()
+		serviceInstance.config.NegativeCacheExpiration = time.Second
+		defer func() { serviceInstance.config.NegativeCacheExpiration = 0 }()
+
+		{ // prepare the mocks
+			redisMock.
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{}, time.Time{}, errIDNotFound).Once()
+
+			postgresMock.
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{}, ErrIDNotExists).Once()
+
+			redisMock.
+				On("insertMissing", mock.Anything, "", sampleID, time.Second).
+				Return(nil).Once()
+		}
+
+		_, err := serviceInstance.Retrieve(context.TODO(), "", sampleID)
+		assert.ErrorIs(t, err, ErrShortenIDNotExists)
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
+	t.Run("missing id cached", func(t *testing.T) {
+		initializeServiceInstance()
+
+		redisMock.
+			On("retrieve", mock.Anything, "", sampleID).
+			Return(entities.Link{}, time.Time{}, errIDMissing).Once()
+
+		_, err := serviceInstance.Retrieve(context.TODO(), "", sampleID)
+		assert.ErrorIs(t, err, ErrShortenIDNotExists)
+		postgresMock.AssertNotCalled(t, "retrieve", mock.Anything, mock.Anything, mock.Anything)
+		redisMock.AssertExpectations(t)
+	})
+
 	t.Run("no cache (error) and postgres error", func(t *testing.T) {
 		initializeServiceInstance()
 
 		{ // prepare the mocks
 			redisMock.
-				On("retrieve", mock.Anything, sampleID).
-				Return("", errIDNotFound).Once()
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{}, time.Time{}, errIDNotFound).Once()
 
 			postgresMock.
-				On("retrieve", mock.Anything, sampleID).
-				Return("", ErrRetreivingValue).Once()
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{}, ErrRetreivingValue).Once()
 		}
 
-		_, err := serviceInstance.Retrieve(context.TODO(), sampleID)
+		_, err := serviceInstance.Retrieve(context.TODO(), "", sampleID)
 		if !errors.Is(err, ErrRetreivingDataFromDatabase) {
 			t.Errorf("expect ErrRetreivingDataFromDatabase error %v", err)
 		}
 		postgresMock.AssertExpectations(t)
 	})
 
+	t.Run("expired link", func(t *testing.T) {
+		initializeServiceInstance()
+
+		expiresAt := time.Now().Add(-time.Minute)
+
+		{ // prepare the mocks
+			redisMock.
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{}, time.Time{}, errIDNotFound).Once()
+
+			postgresMock.
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{ID: sampleID, URL: entities.URL(sampleURL), ExpiresAt: &expiresAt}, nil).Once()
+		}
+
+		_, err := serviceInstance.Retrieve(context.TODO(), "", sampleID)
+		if !errors.Is(err, ErrShortenIDExpired) {
+			t.Errorf("expect ErrShortenIDExpired error %v", err)
+		}
+		postgresMock.AssertExpectations(t)
+		redisMock.AssertExpectations(t)
+	})
+
 	t.Run("success with cache", func(t *testing.T) {
 		initializeServiceInstance()
 
 		{ // prepare the mocks
 			redisMock.
-				On("retrieve", mock.Anything, sampleID).
-				Return(sampleURL, nil).Once()
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{ID: sampleID, URL: entities.URL(sampleURL)}, time.Time{}, nil).Once()
 		}
 
-		url, err := serviceInstance.Retrieve(context.TODO(), sampleID)
+		link, err := serviceInstance.Retrieve(context.TODO(), "", sampleID)
 		assert.NoError(t, err)
-		assert.Equal(t, sampleURL, string(url))
+		assert.Equal(t, sampleURL, string(link.URL))
 		postgresMock.AssertExpectations(t)
 		redisMock.AssertExpectations(t)
 	})
@@ -191,22 +431,37 @@ func TestServiceRetrieve(t *testing.T) {
 
 		{ // prepare the mocks
 			redisMock.
-				On("retrieve", mock.Anything, sampleID).
-				Return("", errIDNotFound).Once()
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{}, time.Time{}, errIDNotFound).Once()
 
 			postgresMock.
-				On("retrieve", mock.Anything, sampleID).
-				Return(sampleURL, nil).Once()
+				On("retrieve", mock.Anything, "", sampleID).
+				Return(entities.Link{ID: sampleID, URL: entities.URL(sampleURL)}, nil).Once()
 
 			redisMock.
-				On("insert", mock.Anything, mock.Anything, sampleURL, serviceInstance.config.CacheExpiration).
+				On("insert", mock.Anything, linkMatcher(sampleID, sampleURL), serviceInstance.config.CacheExpiration).
 				Return(nil).Once()
 		}
 
-		url, err := serviceInstance.Retrieve(context.TODO(), sampleID)
+		link, err := serviceInstance.Retrieve(context.TODO(), "", sampleID)
 		assert.NoError(t, err)
-		assert.Equal(t, entities.URL(sampleURL), url)
+		assert.Equal(t, entities.URL(sampleURL), link.URL)
 		postgresMock.AssertExpectations(t)
 		redisMock.AssertExpectations(t)
 	})
 }
+
+func TestHashURL(t *testing.T) {
+	normalized, err := entities.URL("HTTPS://Example.COM:443/Path").Normalize(serviceInstance.config.URL.policy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hashURL(normalized) != hashURL("https://example.com/Path") {
+		t.Error("expect the same hash for the urls normalized the same")
+	}
+
+	if hashURL("https://example.com/Path") == hashURL("https://example.com/path") {
+		t.Error("expect different hashes for the urls differing in path case")
+	}
+}
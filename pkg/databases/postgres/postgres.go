@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -46,24 +47,49 @@ func Open(cfg *Config, namespace, subsystem string) (*Postgres, error) {
 		return nil, fmt.Errorf("error while pinging database: %v", err)
 	}
 
-	var vectors Vectors
-	{
-		counterName := vectorNamePrefix + "_counter"
-		counterLabels := []string{"table", "method", "status"}
-		vectors.Counter, err = metrics.RegisterCounter(counterName, namespace, subsystem, counterLabels)
-		if err != nil {
-			return nil, fmt.Errorf("error while registering counter vector: %v", err)
-		}
-
-		histogramName := vectorNamePrefix + "_histogram"
-		histogramLabels := []string{"table", "method"}
-		vectors.Histogram, err = metrics.RegisterHistogram(histogramName, namespace, subsystem, histogramLabels)
-		if err != nil {
-			return nil, fmt.Errorf("error while registering histogram vector: %v", err)
-		}
+	vectors, err := registerVectors(namespace, subsystem)
+	if err != nil {
+		return nil, err
 	}
 
-	r := &Postgres{DB: database, Vectors: &vectors}
+	r := &Postgres{DB: database, Vectors: vectors}
 
 	return r, nil
 }
+
+var (
+	registeredMutex   sync.Mutex
+	registeredVectors = map[string]*Vectors{} // keyed by the namespace and subsystem
+)
+
+// registerVectors registers the vectors of the namespace and subsystem once, since several databases may be opened
+// by them (e.g. the urls and the analytics) and the same vectors can't be registered twice.
+func registerVectors(namespace, subsystem string) (*Vectors, error) {
+	registeredMutex.Lock()
+	defer registeredMutex.Unlock()
+
+	key := namespace + "/" + subsystem
+	if vectors, ok := registeredVectors[key]; ok {
+		return vectors, nil
+	}
+
+	var vectors Vectors
+	var err error
+
+	counterName := vectorNamePrefix + "_counter"
+	counterLabels := []string{"table", "method", "status"}
+	vectors.Counter, err = metrics.RegisterCounter(counterName, namespace, subsystem, counterLabels)
+	if err != nil {
+		return nil, fmt.Errorf("error while registering counter vector: %v", err)
+	}
+
+	histogramName := vectorNamePrefix + "_histogram"
+	histogramLabels := []string{"table", "method"}
+	vectors.Histogram, err = metrics.RegisterHistogram(histogramName, namespace, subsystem, histogramLabels)
+	if err != nil {
+		return nil, fmt.Errorf("error while registering histogram vector: %v", err)
+	}
+
+	registeredVectors[key] = &vectors
+	return &vectors, nil
+}
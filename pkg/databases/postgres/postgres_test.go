@@ -0,0 +1,20 @@
+package postgres
+
+import "testing"
+
+func TestRegisterVectors(t *testing.T) {
+	first, err := registerVectors("fesghel", "test")
+	if err != nil {
+		t.Fatalf("expect no errors %v", err)
+	}
+
+	// e.g. the analytics database is opened alongside the urls one
+	second, err := registerVectors("fesghel", "test")
+	if err != nil {
+		t.Fatalf("expect the vectors to be registered once, got %v", err)
+	}
+
+	if first != second {
+		t.Error("expect the databases of the same subsystem to share the vectors")
+	}
+}
@@ -1,14 +1,81 @@
 package redis
 
 import (
+	"errors"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Address  string        `required:"true"`
-	Username string        `required:"true"`
-	Password string        `required:"true"`
-	DB       int           `required:"true"`
-	Timeout  time.Duration `default:"5s" required:"false"`
-	PoolSize int           `default:"10" required:"false"`
+	// Mode is the deployment of redis, one of standalone, sentinel or cluster
+	Mode Mode `default:"standalone" required:"false"`
+	// Address is the address of the standalone redis, it's used when the addresses are not given
+	Address string `required:"false"`
+	// Addresses are the seed nodes of the cluster or the sentinels
+	Addresses []string `required:"false"`
+	// MasterName is the name of the master monitored by the sentinels, only used by the sentinel mode
+	MasterName string `required:"false" split_words:"true"`
+	// SentinelPassword authenticates to the sentinels, only used by the sentinel mode
+	SentinelPassword string        `required:"false" split_words:"true"`
+	Username         string        `required:"true"`
+	Password         string        `required:"true"`
+	DB               int           `required:"true"` // ignored by the cluster mode, the clusters only have the database 0
+	Timeout          time.Duration `default:"5s" required:"false"`
+	PoolSize         int           `default:"10" required:"false" split_words:"true"`
+	// ReadFromReplica routes the read-only commands to the replicas, only used by the sentinel and cluster modes
+	ReadFromReplica bool `default:"false" required:"false" split_words:"true"`
+	// TLS encrypts the connections, they're not encrypted when it's not given
+	TLS *TLSConfig `required:"false"`
+}
+
+type TLSConfig struct {
+	Enabled bool `default:"false"`
+	// CAFile is the certificate authority of the servers, the system pool is used when it's not given
+	CAFile string `split_words:"true"`
+	// CertFile and KeyFile are the certificate of the client, only needed when the servers verify the clients
+	CertFile string `split_words:"true"`
+	KeyFile  string `split_words:"true"`
+	// ServerName is the name verified on the certificates of the servers, the host of the address is used when it's empty
+	ServerName         string `split_words:"true"`
+	InsecureSkipVerify bool   `default:"false" split_words:"true"`
+}
+
+// Mode is the deployment of redis
+type Mode string
+
+const (
+	ModeStandalone Mode = "standalone"
+	ModeSentinel   Mode = "sentinel"
+	ModeCluster    Mode = "cluster"
+)
+
+var ErrModeInvalid = errors.New("error redis mode should be one of standalone, sentinel or cluster")
+
+// Decode implements envconfig.Decoder
+func (m *Mode) Decode(value string) error {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case ModeStandalone, ModeSentinel, ModeCluster:
+		*m = mode
+		return nil
+	case "":
+		*m = ModeStandalone
+		return nil
+	default:
+		return ErrModeInvalid
+	}
+}
+
+// addresses returns the addresses of the nodes, the address is used when they're not given
+func (c *Config) addresses() []string {
+	addresses := make([]string, 0, len(c.Addresses))
+	for _, address := range c.Addresses {
+		if address = strings.TrimSpace(address); len(address) != 0 {
+			addresses = append(addresses, address)
+		}
+	}
+
+	if len(addresses) == 0 && len(c.Address) != 0 {
+		addresses = append(addresses, c.Address)
+	}
+	return addresses
 }
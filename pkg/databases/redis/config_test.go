@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestModeDecode(t *testing.T) {
+	for value, expected := range map[string]Mode{
+		"standalone":  ModeStandalone,
+		" Sentinel ":  ModeSentinel,
+		"CLUSTER":     ModeCluster,
+		"":            ModeStandalone,
+		"  ":          ModeStandalone,
+		"standalone ": ModeStandalone,
+	} {
+		var mode Mode
+		if err := mode.Decode(value); err != nil || mode != expected {
+			t.Errorf("expect %q to be decoded as %s, got %s and %v", value, expected, mode, err)
+		}
+	}
+
+	for _, value := range []string{"replica", "sentinels", "cluster-mode"} {
+		var mode Mode
+		if err := mode.Decode(value); !errors.Is(err, ErrModeInvalid) {
+			t.Errorf("expect ErrModeInvalid error for %q: %v", value, err)
+		}
+	}
+}
+
+func TestConfigAddresses(t *testing.T) {
+	cases := map[string]struct {
+		config   Config
+		expected []string
+	}{
+		"address":           {config: Config{Address: "127.0.0.1:6379"}, expected: []string{"127.0.0.1:6379"}},
+		"addresses":         {config: Config{Addresses: []string{"a:6379", "b:6379"}}, expected: []string{"a:6379", "b:6379"}},
+		"addresses first":   {config: Config{Address: "127.0.0.1:6379", Addresses: []string{"a:6379"}}, expected: []string{"a:6379"}},
+		"blank addresses":   {config: Config{Address: "127.0.0.1:6379", Addresses: []string{" ", ""}}, expected: []string{"127.0.0.1:6379"}},
+		"trimmed addresses": {config: Config{Addresses: []string{" a:6379 ", "", "b:6379"}}, expected: []string{"a:6379", "b:6379"}},
+		"nothing given":     {config: Config{}, expected: []string{}},
+	}
+
+	for name, testCase := range cases {
+		if addresses := testCase.config.addresses(); !slices.Equal(addresses, testCase.expected) {
+			t.Errorf("expect the addresses of %s to be %v, got %v", name, testCase.expected, addresses)
+		}
+	}
+}
@@ -2,38 +2,137 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/redis/go-redis/v9"
 )
 
 const Nil = redis.Nil
 
+type Pipeliner = redis.Pipeliner
+
+type StringCmd = redis.StringCmd
+
+type DurationCmd = redis.DurationCmd
+
+type IntCmd = redis.IntCmd
+
+type Script = redis.Script
+
+type Subscription = redis.Subscription
+
+type Message = redis.Message
+
+func NewScript(src string) *Script {
+	return redis.NewScript(src)
+}
+
+// Redis wraps the client of the configured mode, the commands are routed to the right nodes by it
 type Redis struct {
-	*redis.Client
+	redis.UniversalClient
 }
 
 func Open(config *Config) (*Redis, error) {
-	r := Redis{}
-
-	r.Client = redis.NewClient(&redis.Options{
-		Addr:         config.Address,
-		DB:           config.DB,
-		Username:     config.Username,
-		Password:     config.Password,
-		PoolSize:     config.PoolSize,
-		DialTimeout:  config.Timeout,
-		ReadTimeout:  config.Timeout,
-		WriteTimeout: config.Timeout,
-	})
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	r := Redis{UniversalClient: client}
 
 	pingCtx, cf := context.WithTimeout(context.Background(), config.Timeout)
 	defer cf()
 
-	err := r.Client.Ping(pingCtx).Err()
+	err = r.UniversalClient.Ping(pingCtx).Err()
 	if err != nil {
+		r.UniversalClient.Close()
 		return nil, fmt.Errorf("error pinging the redis client: %v", err)
 	}
 
 	return &r, nil
 }
+
+// newClient creates the client of the configured mode, it doesn't connect until the first command
+func newClient(config *Config) (redis.UniversalClient, error) {
+	addresses := config.addresses()
+	if len(addresses) == 0 {
+		return nil, errors.New("error no address of redis has been given")
+	}
+
+	tlsConfig, err := config.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+
+	options := redis.UniversalOptions{
+		Addrs:            addresses,
+		DB:               config.DB,
+		Username:         config.Username,
+		Password:         config.Password,
+		SentinelPassword: config.SentinelPassword,
+		MasterName:       config.MasterName,
+		PoolSize:         config.PoolSize,
+		DialTimeout:      config.Timeout,
+		ReadTimeout:      config.Timeout,
+		WriteTimeout:     config.Timeout,
+		TLSConfig:        tlsConfig,
+	}
+
+	switch config.Mode {
+	case ModeSentinel:
+		if len(config.MasterName) == 0 {
+			return nil, errors.New("error the master name is required by the sentinel mode")
+		}
+
+		if config.ReadFromReplica {
+			failover := options.Failover()
+			failover.RouteRandomly = true // the read-only commands are spread over the master and the replicas
+			return redis.NewFailoverClusterClient(failover), nil
+		}
+		return redis.NewFailoverClient(options.Failover()), nil
+	case ModeCluster:
+		options.ReadOnly = config.ReadFromReplica
+		return redis.NewClusterClient(options.Cluster()), nil
+	default:
+		options.Addrs = addresses[:1]
+		return redis.NewClient(options.Simple()), nil
+	}
+}
+
+// config builds the tls config, nil is returned when the tls is not enabled
+func (c *TLSConfig) config() (*tls.Config, error) {
+	if c == nil || !c.Enabled {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if len(c.CAFile) != 0 {
+		ca, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the ca file of redis: %v", err)
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("error no certificate has been found in the ca file of redis")
+		}
+	}
+
+	if len(c.CertFile) != 0 || len(c.KeyFile) != 0 {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading the certificate of redis: %v", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,251 @@
+package redis
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// writeCertificate writes a self-signed certificate and its key as pem files
+func writeCertificate(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.example"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directory := t.TempDir()
+	certFile, keyFile = filepath.Join(directory, "cert.pem"), filepath.Join(directory, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateKey}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+
+	emptyFile := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(emptyFile, []byte("no certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("not enabled", func(t *testing.T) {
+		for _, config := range []*TLSConfig{nil, {Enabled: false, CAFile: certFile}} {
+			if tlsConfig, err := config.config(); err != nil || tlsConfig != nil {
+				t.Errorf("expect no tls config for %+v, got %+v and %v", config, tlsConfig, err)
+			}
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		config := &TLSConfig{Enabled: true, ServerName: "redis.example", InsecureSkipVerify: true}
+		tlsConfig, err := config.config()
+		if err != nil {
+			t.Fatalf("expect no errors %v", err)
+		}
+
+		if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.ServerName != "redis.example" || !tlsConfig.InsecureSkipVerify {
+			t.Errorf("invalid tls config has been built %+v", tlsConfig)
+		}
+		if tlsConfig.RootCAs != nil || len(tlsConfig.Certificates) != 0 {
+			t.Error("expect the system pool and no client certificate to be used")
+		}
+	})
+
+	t.Run("certificates", func(t *testing.T) {
+		config := &TLSConfig{Enabled: true, CAFile: certFile, CertFile: certFile, KeyFile: keyFile}
+		tlsConfig, err := config.config()
+		if err != nil {
+			t.Fatalf("expect no errors %v", err)
+		}
+
+		if tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 {
+			t.Errorf("expect the ca and the client certificate to be loaded %+v", tlsConfig)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cases := map[string]*TLSConfig{
+			"missing ca":          {Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+			"ca without any cert": {Enabled: true, CAFile: emptyFile},
+			"certificate only":    {Enabled: true, CertFile: certFile},
+			"invalid key file":    {Enabled: true, CertFile: certFile, KeyFile: emptyFile},
+		}
+
+		for name, config := range cases {
+			if _, err := config.config(); err == nil {
+				t.Errorf("expect an error for %s", name)
+			}
+		}
+	})
+}
+
+func TestNewClient(t *testing.T) {
+	base := Config{
+		Addresses: []string{"a:6379", "b:6379"}, MasterName: "primary", Username: "fesghel", Password: "secret", DB: 2,
+		Timeout: 3 * time.Second, PoolSize: 7,
+	}
+
+	t.Run("standalone", func(t *testing.T) {
+		config := base
+		client, err := newClient(&config)
+		if err != nil {
+			t.Fatalf("expect no errors %v", err)
+		}
+		defer client.Close()
+
+		simple, ok := client.(*redis.Client)
+		if !ok {
+			t.Fatalf("expect a simple client, got %T", client)
+		}
+
+		options := simple.Options()
+		if options.Addr != "a:6379" || options.DB != 2 || options.Username != "fesghel" || options.Password != "secret" ||
+			options.PoolSize != 7 || options.DialTimeout != 3*time.Second || options.ReadTimeout != 3*time.Second {
+			t.Errorf("expect the options to be mapped from the config, got %+v", options)
+		}
+	})
+
+	t.Run("sentinel", func(t *testing.T) {
+		config := base
+		config.Mode = ModeSentinel
+
+		client, err := newClient(&config)
+		if err != nil {
+			t.Fatalf("expect no errors %v", err)
+		}
+		defer client.Close()
+
+		failover, ok := client.(*redis.Client)
+		if !ok {
+			t.Fatalf("expect a failover client, got %T", client)
+		}
+		if options := failover.Options(); options.Addr != "FailoverClient" || options.DB != 2 {
+			t.Errorf("expect the master to be resolved via the sentinels, got %+v", options)
+		}
+	})
+
+	t.Run("sentinel reading from replicas", func(t *testing.T) {
+		config := base
+		config.Mode, config.ReadFromReplica = ModeSentinel, true
+
+		client, err := newClient(&config)
+		if err != nil {
+			t.Fatalf("expect no errors %v", err)
+		}
+		defer client.Close()
+
+		cluster, ok := client.(*redis.ClusterClient)
+		if !ok {
+			t.Fatalf("expect a failover cluster client, got %T", client)
+		}
+		if !cluster.Options().RouteRandomly {
+			t.Error("expect the read-only commands to be spread over the master and the replicas")
+		}
+	})
+
+	t.Run("sentinel without master name", func(t *testing.T) {
+		config := base
+		config.Mode, config.MasterName = ModeSentinel, ""
+
+		if _, err := newClient(&config); err == nil {
+			t.Error("expect the master name to be required")
+		}
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		for _, readFromReplica := range []bool{false, true} {
+			config := base
+			config.Mode, config.ReadFromReplica = ModeCluster, readFromReplica
+
+			client, err := newClient(&config)
+			if err != nil {
+				t.Fatalf("expect no errors %v", err)
+			}
+
+			cluster, ok := client.(*redis.ClusterClient)
+			if !ok {
+				t.Fatalf("expect a cluster client, got %T", client)
+			}
+
+			options := cluster.Options()
+			if !slices.Equal(options.Addrs, base.Addresses) || options.ReadOnly != readFromReplica || options.Password != "secret" {
+				t.Errorf("expect the options to be mapped from the config, got %+v", options)
+			}
+			client.Close()
+		}
+	})
+
+	t.Run("no address", func(t *testing.T) {
+		if _, err := newClient(&Config{}); err == nil {
+			t.Error("expect an address to be required")
+		}
+	})
+}
+
+func TestOpen(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	t.Run("standalone", func(t *testing.T) {
+		r, err := Open(&Config{Address: server.Addr(), Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("expect no errors %v", err)
+		}
+		defer r.Close()
+
+		if err := r.Set(context.TODO(), "key", "value", 0).Err(); err != nil {
+			t.Fatalf("expect no errors %v", err)
+		}
+		if value, _ := server.Get("key"); value != "value" {
+			t.Errorf("expect the command to reach the server, got %q", value)
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		if _, err := Open(&Config{Address: "127.0.0.1:1", Timeout: 100 * time.Millisecond}); err == nil {
+			t.Error("expect an error pinging the unreachable server")
+		}
+	})
+}
@@ -0,0 +1,23 @@
+package jwt
+
+import "time"
+
+type Config struct {
+	// JWKSFile is the path of the json web key set, it's loaded on startup and on each refresh
+	JWKSFile string `envconfig:"JWKS_FILE"`
+	// JWKSURL is the endpoint serving the json web key set (e.g. the jwks_uri of an OIDC provider)
+	JWKSURL string `envconfig:"JWKS_URL"`
+	// Issuer and Audience are checked against the iss and aud claims when they're given
+	Issuer   string `required:"false"`
+	Audience string `required:"false"`
+	// RefreshInterval is the interval of reloading the key set, the unknown key ids trigger a reload as well
+	RefreshInterval time.Duration `default:"10m" split_words:"true"`
+	// Leeway is the tolerated clock skew while checking the exp and nbf claims
+	Leeway  time.Duration `default:"30s"`
+	Timeout time.Duration `default:"5s"`
+}
+
+// Enabled reports whether a key set has been configured
+func (cfg *Config) Enabled() bool {
+	return cfg != nil && (len(cfg.JWKSFile) != 0 || len(cfg.JWKSURL) != 0)
+}
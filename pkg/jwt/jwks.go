@@ -0,0 +1,271 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyNotFound   = errors.New("error key not found in the key set")
+	ErrKeySetInvalid = errors.New("error key set is invalid")
+	ErrLoadingKeySet = errors.New("error loading the key set")
+)
+
+// minRefreshInterval throttles the reloads triggered by the unknown key ids
+const minRefreshInterval = 30 * time.Second
+
+// jsonWebKey is a public key of the key set (RFC 7517), only the RSA and EC keys are supported
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	N string `json:"n"` // RSA
+	E string `json:"e"`
+
+	Crv string `json:"crv"` // EC
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// publicKey is a parsed key of the key set, alg is empty when the key doesn't restrict its algorithm
+type publicKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// KeySet holds the keys of a json web key set and reloads them from their source
+type KeySet struct {
+	load            func(ctx context.Context) ([]byte, error)
+	refreshInterval time.Duration
+
+	mutex     sync.RWMutex
+	keys      map[string]publicKey
+	loadedAt  time.Time
+	failedAt  time.Time  // the last failed reload
+	reloading sync.Mutex // serializes the reloads
+}
+
+// NewKeySet loads the key set from the configured file or url
+func NewKeySet(ctx context.Context, cfg *Config) (*KeySet, error) {
+	keySet := &KeySet{refreshInterval: cfg.RefreshInterval}
+
+	switch {
+	case len(cfg.JWKSFile) != 0:
+		keySet.load = func(context.Context) ([]byte, error) { return os.ReadFile(cfg.JWKSFile) }
+	case len(cfg.JWKSURL) != 0:
+		client := &http.Client{Timeout: cfg.Timeout}
+		keySet.load = func(ctx context.Context) ([]byte, error) { return fetch(ctx, client, cfg.JWKSURL) }
+	default:
+		return nil, fmt.Errorf("%w: either the file or url should be given", ErrLoadingKeySet)
+	}
+
+	if err := keySet.reload(ctx); err != nil {
+		return nil, err
+	}
+	return keySet, nil
+}
+
+// lookup returns the key having the id, the key set is reloaded when it's stale or the id is unknown
+func (ks *KeySet) lookup(ctx context.Context, kid string) (publicKey, error) {
+	key, found, due := ks.check(kid)
+	if due {
+		ks.reloading.Lock()
+		// the set may have been reloaded (or failed to) by the others while waiting for the lock
+		if key, found, due = ks.check(kid); due {
+			// the current keys are kept when reloading fails
+			if err := ks.reload(ctx); err == nil {
+				key, found, _ = ks.check(kid)
+			}
+		}
+		ks.reloading.Unlock()
+	}
+
+	if !found {
+		return publicKey{}, fmt.Errorf("%w: %q", ErrKeyNotFound, kid)
+	}
+	return key, nil
+}
+
+// check returns the key having the id, and whether the key set should be reloaded. The failed reloads are
+// retried after the minimum interval, so the requests don't serialize on reaching an unavailable source.
+func (ks *KeySet) check(kid string) (publicKey, bool, bool) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	key, found := ks.find(kid)
+	if time.Since(ks.failedAt) <= minRefreshInterval {
+		return key, found, false
+	}
+
+	stale := ks.refreshInterval > 0 && time.Since(ks.loadedAt) > ks.refreshInterval
+	return key, found, stale || (!found && time.Since(ks.loadedAt) > minRefreshInterval)
+}
+
+// find returns the key having the id, the only key is used when no id is given
+func (ks *KeySet) find(kid string) (publicKey, bool) {
+	if len(kid) == 0 && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	key, found := ks.keys[kid]
+	return key, found
+}
+
+// reload loads the key set from its source, the failure is recorded so the retries are throttled
+func (ks *KeySet) reload(ctx context.Context) error {
+	keys, err := ks.fetchKeys(ctx)
+	if err != nil {
+		ks.mutex.Lock()
+		ks.failedAt = time.Now()
+		ks.mutex.Unlock()
+		return err
+	}
+
+	ks.mutex.Lock()
+	ks.keys, ks.loadedAt = keys, time.Now()
+	ks.mutex.Unlock()
+
+	return nil
+}
+
+func (ks *KeySet) fetchKeys(ctx context.Context) (map[string]publicKey, error) {
+	data, err := ks.load(ctx)
+	if err != nil {
+		return nil, errors.Join(ErrLoadingKeySet, err)
+	}
+	return parseKeySet(data)
+}
+
+func fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
+
+// parseKeySet parses the signing keys of the key set, the keys of the other usages are skipped
+func parseKeySet(data []byte) (map[string]publicKey, error) {
+	var set jsonWebKeySet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, errors.Join(ErrKeySetInvalid, err)
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if len(jwk.Use) != 0 && jwk.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+
+		switch jwk.Kty {
+		case "RSA":
+			key, err = parseRSA(jwk)
+		case "EC":
+			key, err = parseEC(jwk)
+		default:
+			continue // the unsupported key types never verify a token
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q, %v", ErrKeySetInvalid, jwk.Kid, err)
+		}
+		keys[jwk.Kid] = publicKey{key: key, alg: jwk.Alg}
+	}
+
+	return keys, nil
+}
+
+func parseRSA(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	if key.N.BitLen() < 2048 {
+		return nil, errors.New("the modulus should be at least 2048 bits")
+	}
+	return key, nil
+}
+
+func parseEC(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var ecdhCurve ecdh.Curve
+
+	switch jwk.Crv {
+	case "P-256":
+		curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	if len(x) != size || len(y) != size {
+		return nil, errors.New("invalid coordinates length")
+	}
+
+	// the uncompressed point is validated to be on the curve
+	if _, err := ecdhCurve.NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+		return nil, err
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
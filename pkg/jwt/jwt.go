@@ -0,0 +1,221 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTokenMalformed       = errors.New("error token is malformed")
+	ErrAlgorithmUnsupported = errors.New("error token algorithm is not supported")
+	ErrSignatureInvalid     = errors.New("error token signature is invalid")
+	ErrTokenExpired         = errors.New("error token is expired")
+	ErrTokenNotYetValid     = errors.New("error token is not valid yet")
+	ErrIssuerInvalid        = errors.New("error token issuer is invalid")
+	ErrAudienceInvalid      = errors.New("error token audience is invalid")
+)
+
+// Claims are the registered claims of the token alongside its scopes
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+
+	// the scopes are given either space separated (scope) or as an array (scp)
+	Scope string   `json:"scope"`
+	Scp   []string `json:"scp"`
+}
+
+// Scopes returns all of the scopes of the claims
+func (c *Claims) Scopes() []string {
+	scopes := strings.Fields(c.Scope)
+	for _, scope := range c.Scp {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// audience is either a single string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// algorithm is a supported signing algorithm, the symmetric ones (and none) are never accepted
+type algorithm struct {
+	hash  crypto.Hash
+	kind  string // RSA, PSS or EC
+	curve int    // the bit size of the curve, only used by EC
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {hash: crypto.SHA256, kind: "RSA"},
+	"RS384": {hash: crypto.SHA384, kind: "RSA"},
+	"RS512": {hash: crypto.SHA512, kind: "RSA"},
+	"PS256": {hash: crypto.SHA256, kind: "PSS"},
+	"PS384": {hash: crypto.SHA384, kind: "PSS"},
+	"PS512": {hash: crypto.SHA512, kind: "PSS"},
+	"ES256": {hash: crypto.SHA256, kind: "EC", curve: 256},
+	"ES384": {hash: crypto.SHA384, kind: "EC", curve: 384},
+	"ES512": {hash: crypto.SHA512, kind: "EC", curve: 521},
+}
+
+// Verifier verifies the signed tokens (JWS compact serialization) against the key set
+type Verifier struct {
+	config *Config
+	keys   *KeySet
+	now    func() time.Time
+}
+
+func NewVerifier(cfg *Config) (*Verifier, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	keys, err := NewKeySet(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{config: cfg, keys: keys, now: time.Now}, nil
+}
+
+// Verify checks the signature and the registered claims of the token, then returns its claims
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var header header
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.Join(ErrTokenMalformed, err)
+	}
+
+	algorithm, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, ErrAlgorithmUnsupported
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Join(ErrTokenMalformed, err)
+	}
+
+	key, err := v.keys.lookup(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key.alg) != 0 && key.alg != header.Alg {
+		return nil, ErrAlgorithmUnsupported
+	}
+
+	hasher := algorithm.hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(algorithm, key.key, hasher.Sum(nil), signature) {
+		return nil, ErrSignatureInvalid
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, errors.Join(ErrTokenMalformed, err)
+	}
+
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// validate checks the time based claims (exp is required) and the issuer and audience when they're configured
+func (v *Verifier) validate(claims *Claims) error {
+	now := v.now()
+
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.config.Leeway)) {
+		return ErrTokenExpired
+	}
+
+	if claims.NotBefore != 0 && now.Add(v.config.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+
+	if len(v.config.Issuer) != 0 && claims.Issuer != v.config.Issuer {
+		return ErrIssuerInvalid
+	}
+
+	if len(v.config.Audience) != 0 && !slices.Contains(claims.Audience, v.config.Audience) {
+		return ErrAudienceInvalid
+	}
+
+	return nil
+}
+
+func verifySignature(algorithm algorithm, key crypto.PublicKey, digest, signature []byte) bool {
+	switch algorithm.kind {
+	case "RSA":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, algorithm.hash, digest, signature) == nil
+	case "PSS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		options := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: algorithm.hash}
+		return ok && rsa.VerifyPSS(rsaKey, algorithm.hash, digest, signature, options) == nil
+	case "EC":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve.Params().BitSize != algorithm.curve {
+			return false
+		}
+
+		// the signature is the concatenation of r and s (RFC 7518), not the asn.1 encoding
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(ecKey, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
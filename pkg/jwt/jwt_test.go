@@ -0,0 +1,231 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+)
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func keySetJSON(t *testing.T) []byte {
+	t.Helper()
+
+	size := (ecKey.Curve.Params().BitSize + 7) / 8
+	data, err := json.Marshal(jsonWebKeySet{Keys: []jsonWebKey{
+		{Kty: "RSA", Kid: "rsa", Use: "sig", Alg: "RS256", N: encode(rsaKey.N.Bytes()), E: encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: encode(ecKey.X.FillBytes(make([]byte, size))), Y: encode(ecKey.Y.FillBytes(make([]byte, size)))},
+		{Kty: "oct", Kid: "symmetric"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// sign creates a token signed by the rsa (RS256) or ec (ES256) key
+func sign(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+
+	alg := map[string]string{"rsa": "RS256", "ec": "ES256"}[kid]
+	headerJSON, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	claimsJSON, _ := json.Marshal(claims)
+	signingInput := encode(headerJSON) + "." + encode(claimsJSON)
+
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signingInput))
+
+	var signature []byte
+	var err error
+	switch kid {
+	case "rsa":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest.Sum(nil))
+	case "ec":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, ecKey, digest.Sum(nil))
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + encode(signature)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   "https://issuer.example",
+		"sub":   "billing-service",
+		"aud":   []string{"fesghel", "others"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "links:read links:write",
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(keySetJSON(t))
+	}))
+	defer server.Close()
+
+	cfg := &Config{JWKSURL: server.URL, Issuer: "https://issuer.example", Audience: "fesghel", Timeout: time.Second}
+	verifier, err := NewVerifier(cfg)
+	if err != nil {
+		t.Fatalf("expect no errors %v", err)
+	}
+
+	t.Run("valid tokens", func(t *testing.T) {
+		for _, kid := range []string{"rsa", "ec"} {
+			claims, err := verifier.Verify(context.TODO(), sign(t, kid, validClaims()))
+			if err != nil {
+				t.Errorf("expect no errors for %s %v", kid, err)
+				continue
+			}
+
+			if claims.Subject != "billing-service" || len(claims.Scopes()) != 2 {
+				t.Errorf("invalid claims have been returned %+v", claims)
+			}
+		}
+	})
+
+	t.Run("invalid tokens", func(t *testing.T) {
+		expired := validClaims()
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+		notBefore := validClaims()
+		notBefore["nbf"] = time.Now().Add(time.Hour).Unix()
+
+		issuer := validClaims()
+		issuer["iss"] = "https://attacker.example"
+
+		audience := validClaims()
+		audience["aud"] = "others"
+
+		valid := sign(t, "rsa", validClaims())
+		tampered := valid[:len(valid)-4] + "AAAA"
+
+		none := encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(`{"sub":"admin"}`)) + "."
+
+		cases := map[string]struct {
+			token    string
+			expected error
+		}{
+			"malformed":      {token: "not-a-token", expected: ErrTokenMalformed},
+			"none algorithm": {token: none, expected: ErrAlgorithmUnsupported},
+			"tampered":       {token: tampered, expected: ErrSignatureInvalid},
+			"expired":        {token: sign(t, "ec", expired), expected: ErrTokenExpired},
+			"not yet valid":  {token: sign(t, "ec", notBefore), expected: ErrTokenNotYetValid},
+			"issuer":         {token: sign(t, "rsa", issuer), expected: ErrIssuerInvalid},
+			"audience":       {token: sign(t, "rsa", audience), expected: ErrAudienceInvalid},
+		}
+
+		for name, testCase := range cases {
+			if _, err := verifier.Verify(context.TODO(), testCase.token); !errors.Is(err, testCase.expected) {
+				t.Errorf("expect %v error for %s, got %v", testCase.expected, name, err)
+			}
+		}
+	})
+
+	t.Run("unknown key reloads once", func(t *testing.T) {
+		verifier.keys.loadedAt = time.Now().Add(-time.Hour)
+		before := requests.Load()
+
+		token := sign(t, "rsa", validClaims())
+		token = encode([]byte(`{"alg":"RS256","kid":"rotated"}`)) + token[len(encode([]byte(`{"alg":"RS256","kid":"rsa","typ":"JWT"}`))):]
+
+		for range 3 {
+			if _, err := verifier.Verify(context.TODO(), token); !errors.Is(err, ErrKeyNotFound) {
+				t.Errorf("expect ErrKeyNotFound error %v", err)
+			}
+		}
+
+		if reloads := requests.Load() - before; reloads != 1 {
+			t.Errorf("expect the key set to be reloaded once, got %d", reloads)
+		}
+	})
+
+	t.Run("failed reloads are throttled", func(t *testing.T) {
+		failing.Store(true)
+		defer failing.Store(false)
+
+		verifier.keys.loadedAt = time.Now().Add(-time.Hour)
+		verifier.keys.failedAt = time.Time{}
+		before := requests.Load()
+
+		token := sign(t, "rsa", validClaims())
+		token = encode([]byte(`{"alg":"RS256","kid":"rotated"}`)) + token[len(encode([]byte(`{"alg":"RS256","kid":"rsa","typ":"JWT"}`))):]
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := verifier.Verify(context.TODO(), token); !errors.Is(err, ErrKeyNotFound) {
+					t.Errorf("expect ErrKeyNotFound error %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if reloads := requests.Load() - before; reloads != 1 {
+			t.Errorf("expect the failed reload to be attempted once, got %d", reloads)
+		}
+
+		if _, err := verifier.Verify(context.TODO(), sign(t, "rsa", validClaims())); err != nil {
+			t.Errorf("expect the current keys to be kept %v", err)
+		}
+	})
+}
+
+func TestKeySetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, keySetJSON(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keySet, err := NewKeySet(context.TODO(), &Config{JWKSFile: path})
+	if err != nil {
+		t.Fatalf("expect no errors %v", err)
+	}
+
+	if len(keySet.keys) != 2 {
+		t.Errorf("expect the rsa and ec keys to be loaded, got %d", len(keySet.keys))
+	}
+
+	if err := os.WriteFile(path, []byte(`{"keys": [{"kty": "EC", "kid": "bad", "crv": "P-256", "x": "AA", "y": "AA"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeySet(context.TODO(), &Config{JWKSFile: path}); !errors.Is(err, ErrKeySetInvalid) {
+		t.Errorf("expect ErrKeySetInvalid error %v", err)
+	}
+}
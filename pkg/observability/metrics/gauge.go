@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Gauge interface {
+	Set(value float64, values ...string)
+}
+
+type gauge struct {
+	vector *prometheus.GaugeVec
+}
+
+func MustRegisterGauge(name, namespace, subsystem string, labels []string) Gauge {
+	vector := gaugeVector(name, namespace, subsystem, labels)
+	prometheus.MustRegister(vector)
+	return &gauge{vector: vector}
+}
+
+func RegisterGauge(name, namespace, subsystem string, labels []string) (Gauge, error) {
+	vector := gaugeVector(name, namespace, subsystem, labels)
+	if err := prometheus.Register(vector); err != nil {
+		return nil, fmt.Errorf("error while registering gauge vector: %v", err)
+	}
+	return &gauge{vector: vector}, nil
+}
+
+func gaugeVector(name, namespace, subsystem string, labels []string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Help:      fmt.Sprintf("gauge vector for %s", name),
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+	}, labels)
+}
+
+func (g *gauge) Set(value float64, values ...string) {
+	g.vector.WithLabelValues(values...).Set(value)
+}
+
+// Noop implementation
+
+type gaugeNoop struct{}
+
+func (g *gaugeNoop) Set(value float64, values ...string) {}
+
+func RegisterGaugeNoop() Gauge {
+	return &gaugeNoop{}
+}
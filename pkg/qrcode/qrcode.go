@@ -0,0 +1,125 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Level is the error correction level, the higher levels tolerate more damage but need more modules
+type Level = qr.Level
+
+const (
+	LevelLow      = qr.L
+	LevelMedium   = qr.M
+	LevelQuartile = qr.Q
+	LevelHigh     = qr.H
+)
+
+func ToLevel(rawLevel string) (Level, bool) {
+	switch strings.ToUpper(rawLevel) {
+	case "L":
+		return LevelLow, true
+	case "M":
+		return LevelMedium, true
+	case "Q":
+		return LevelQuartile, true
+	case "H":
+		return LevelHigh, true
+	default:
+		return LevelMedium, false
+	}
+}
+
+var (
+	ErrEncoding     = errors.New("error encoding the content as qr code")
+	ErrSizeTooSmall = errors.New("error size is smaller than the modules of the qr code")
+)
+
+// Code is the grid of the modules (the black and white squares) of a qr code
+type Code struct {
+	code *qr.Code
+}
+
+func Encode(content string, level Level) (*Code, error) {
+	code, err := qr.Encode(content, level)
+	if err != nil {
+		return nil, errors.Join(ErrEncoding, err)
+	}
+	return &Code{code: code}, nil
+}
+
+// Modules returns the number of the modules on a side, excluding the margin
+func (c *Code) Modules() int {
+	return c.code.Size
+}
+
+var palette = color.Palette{color.White, color.Black}
+
+// PNG renders the code as a size×size image surrounded by margin white modules (the quiet zone).
+// The modules are scaled by a whole number of pixels to keep their edges sharp, the remainder widens the margin.
+func (c *Code) PNG(size, margin int) ([]byte, error) {
+	total := c.code.Size + 2*margin
+	if size < total {
+		return nil, ErrSizeTooSmall
+	}
+	scale := size / total
+	offset := (size-scale*total)/2 + margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), palette)
+	for y := 0; y < c.code.Size; y++ {
+		for x := 0; x < c.code.Size; x++ {
+			if !c.code.Black(x, y) {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				row := (offset+y*scale+dy)*img.Stride + offset + x*scale
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[row+dx] = 1
+				}
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buffer, img); err != nil {
+		return nil, fmt.Errorf("error encoding the png: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// SVG renders the code as a vector image of size×size pixels surrounded by margin white modules (the quiet zone),
+// each horizontal run of the black modules is drawn as a single rectangle of the path.
+func (c *Code) SVG(size, margin int) []byte {
+	total := c.code.Size + 2*margin
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, total, total)
+	fmt.Fprintf(&buffer, `<rect width="%d" height="%d" fill="#ffffff"/><path fill="#000000" d="`, total, total)
+
+	for y := 0; y < c.code.Size; y++ {
+		for x := 0; x < c.code.Size; {
+			if !c.code.Black(x, y) {
+				x++
+				continue
+			}
+			run := 1
+			for c.code.Black(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&buffer, "M%d %dh%dv1h-%dz", x+margin, y+margin, run, run)
+			x += run
+		}
+	}
+
+	buffer.WriteString(`"/></svg>`)
+	return buffer.Bytes()
+}
@@ -0,0 +1,68 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestPNG(t *testing.T) {
+	code, err := Encode("https://fesghel.com/abcdef", LevelMedium)
+	if err != nil {
+		t.Fatalf("expect no errors %v", err)
+	}
+
+	t.Run("exact size", func(t *testing.T) {
+		raw, err := code.PNG(300, 4)
+		if err != nil {
+			t.Fatalf("expect no errors %v", err)
+		}
+
+		img, err := png.Decode(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("expect a valid png %v", err)
+		}
+
+		if bounds := img.Bounds(); bounds.Dx() != 300 || bounds.Dy() != 300 {
+			t.Errorf("expect a 300x300 image, got %v", bounds)
+		}
+
+		// the top-left corner is the quiet zone and the finder pattern starts right after it
+		scale := 300 / (code.Modules() + 8)
+		offset := (300-scale*(code.Modules()+8))/2 + 4*scale
+		if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+			t.Error("expect the margin to be white")
+		}
+		if r, _, _, _ := img.At(offset, offset).RGBA(); r != 0 {
+			t.Error("expect the finder pattern to be black")
+		}
+	})
+
+	t.Run("size too small", func(t *testing.T) {
+		if _, err := code.PNG(code.Modules(), 4); !errors.Is(err, ErrSizeTooSmall) {
+			t.Errorf("expect ErrSizeTooSmall error %v", err)
+		}
+	})
+}
+
+func TestSVG(t *testing.T) {
+	code, err := Encode("https://fesghel.com/abcdef", LevelHigh)
+	if err != nil {
+		t.Fatalf("expect no errors %v", err)
+	}
+
+	svg := string(code.SVG(512, 2))
+	total := code.Modules() + 4
+
+	if !strings.Contains(svg, `width="512"`) || !strings.Contains(svg, fmt.Sprintf(`viewBox="0 0 %d %d"`, total, total)) {
+		t.Errorf("expect the size and the view box of the svg, got %s", svg[:120])
+	}
+
+	// the finder pattern starts at the margin with a run of seven modules
+	if !strings.Contains(svg, "M2 2h7v1h-7z") {
+		t.Error("expect the top row of the finder pattern")
+	}
+}
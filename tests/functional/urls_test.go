@@ -3,8 +3,10 @@ package functional
 import (
 	"context"
 	"testing"
+
+	"github.com/mohammadne/fesghel/internal/entities"
 )
 
 func TestURLsShorten(t *testing.T) {
-	urlsService.Shorten(context.TODO(), "https://example.com/a-very-long-url")
+	urlsService.Shorten(context.TODO(), entities.Link{URL: "https://example.com/a-very-long-url"})
 }